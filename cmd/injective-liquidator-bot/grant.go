@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/InjectiveLabs/sdk-go/client"
+	"github.com/InjectiveLabs/sdk-go/client/common"
+	cosmosclient "github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/types"
+	"github.com/pkg/errors"
+
+	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
+	authztypes "github.com/cosmos/cosmos-sdk/x/authz"
+	cli "github.com/jawher/mow.cli"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/grants"
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+)
+
+// grantCmd manages the authz grants given by the granter account to the grantee keys. The Cosmos key
+// options are the granter account ones.
+//
+// $ injective-liquidator-bot grant create|revoke|list|renew
+func grantCmd(cmd *cli.Cmd) {
+	cmd.Command("create", "Grants the grantee keys the messages executed by the bot.", grantCreateCmd)
+	cmd.Command("revoke", "Revokes the grants of the grantee keys.", grantRevokeCmd)
+	cmd.Command("list", "Lists the grants of the grantee keys and their expiration.", grantListCmd)
+	cmd.Command("renew", "Renews the existing grants of the grantee keys with a new expiration.", grantRenewCmd)
+}
+
+func grantCreateCmd(cmd *cli.Cmd) {
+	options := initClientOptions(cmd)
+	grantees, msgTypes, expireIn, dryRun := initGrantCmdOptions(cmd, true)
+
+	cmd.Action = func() {
+		ctx := options.granterContext(*grantees)
+		expiration := time.Now().Add(duration(*expireIn, 365*24*time.Hour))
+
+		var msgs []types.Msg
+		for _, grantee := range ctx.grantees {
+			for _, msgType := range splitList(*msgTypes) {
+				msg, err := authztypes.NewMsgGrant(ctx.granter, grantee, authztypes.NewGenericAuthorization(msgType), &expiration)
+				if err != nil {
+					log.WithError(err).Fatalf("failed to create the %s grant", msgType)
+				}
+				msgs = append(msgs, msg)
+			}
+		}
+
+		ctx.sendGrantMsgs(msgs, *dryRun)
+	}
+}
+
+func grantRevokeCmd(cmd *cli.Cmd) {
+	options := initClientOptions(cmd)
+	grantees, msgTypes, _, dryRun := initGrantCmdOptions(cmd, false)
+
+	cmd.Action = func() {
+		ctx := options.granterContext(*grantees)
+
+		// revoking a missing grant would fail the whole transaction
+		var msgs []types.Msg
+		for _, grant := range ctx.existingGrants(splitList(*msgTypes)) {
+			msg := authztypes.NewMsgRevoke(ctx.granter, types.MustAccAddressFromBech32(grant.Grantee), grant.MsgType)
+			msgs = append(msgs, &msg)
+		}
+		if len(msgs) == 0 {
+			log.Fatalln("no grant to revoke")
+		}
+
+		ctx.sendGrantMsgs(msgs, *dryRun)
+	}
+}
+
+func grantRenewCmd(cmd *cli.Cmd) {
+	options := initClientOptions(cmd)
+	grantees, msgTypes, expireIn, dryRun := initGrantCmdOptions(cmd, true)
+
+	cmd.Action = func() {
+		ctx := options.granterContext(*grantees)
+		expiration := time.Now().Add(duration(*expireIn, 365*24*time.Hour))
+
+		// a new grant of the same message type replaces the existing one
+		var msgs []types.Msg
+		for _, grant := range ctx.existingGrants(splitList(*msgTypes)) {
+			msg, err := authztypes.NewMsgGrant(
+				ctx.granter,
+				types.MustAccAddressFromBech32(grant.Grantee),
+				authztypes.NewGenericAuthorization(grant.MsgType),
+				&expiration,
+			)
+			if err != nil {
+				log.WithError(err).Fatalf("failed to create the %s grant", grant.MsgType)
+			}
+			msgs = append(msgs, msg)
+		}
+		if len(msgs) == 0 {
+			log.Fatalln("no grant to renew")
+		}
+
+		ctx.sendGrantMsgs(msgs, *dryRun)
+	}
+}
+
+func grantListCmd(cmd *cli.Cmd) {
+	options := initClientOptions(cmd)
+
+	granter := fileString(cmd, cli.StringOpt{
+		Name:   "granter",
+		Desc:   "Public address of the granter account, the Cosmos key address when empty",
+		EnvVar: "LIQUIDATOR_GRANTER_PUBLIC_ADDRESS",
+		Value:  "",
+	})
+	grantees := cmd.String(cli.StringOpt{
+		Name: "grantee",
+		Desc: "Comma separated public addresses of the grantee keys",
+	})
+
+	cmd.Action = func() {
+		var ctx *grantContext
+		if *granter != "" {
+			ctx = options.readOnlyContext(*granter, *grantees)
+		} else {
+			ctx = options.granterContext(*grantees)
+		}
+
+		chainClient := ctx.chainClient()
+		defer chainClient.Close()
+		now := time.Now()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GRANTEE\tMSG TYPE\tEXPIRATION")
+		for _, grantee := range ctx.grantees {
+			list, err := grants.List(context.Background(), chainClient, ctx.granter.String(), grantee.String())
+			if err != nil {
+				log.WithError(err).Fatalln("failed to list the grants")
+			}
+
+			for _, grant := range list {
+				expiration := "never"
+				if grant.Expiration != nil {
+					expiration = grant.Expiration.UTC().Format(time.RFC3339)
+					if !grant.Valid(now) {
+						expiration += " (expired)"
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", grant.Grantee, grant.MsgType, expiration)
+			}
+		}
+		_ = w.Flush()
+	}
+}
+
+// clientOptions are the network and Cosmos key options of the commands not running the bot.
+type clientOptions struct {
+	networkName             *string
+	chainID                 *string
+	lcdEndpoint             *string
+	tendermintEndpoint      *string
+	chainGrpcEndpoint       *string
+	chainStreamGrpcEndpoint *string
+	exchangeGrpcEndpoint    *string
+	explorerGrpcEndpoint    *string
+
+	cosmosKeyringDir     *string
+	cosmosKeyringAppName *string
+	cosmosKeyringBackend *string
+	cosmosKeyFrom        *string
+	cosmosKeyPassphrase  *string
+	cosmosPrivKey        *string
+	cosmosUseLedger      *bool
+}
+
+func initClientOptions(cmd *cli.Cmd) *clientOptions {
+	o := &clientOptions{}
+
+	initNetworkOptions(
+		cmd,
+		&o.networkName,
+		&o.chainID,
+		&o.lcdEndpoint,
+		&o.tendermintEndpoint,
+		&o.chainGrpcEndpoint,
+		&o.chainStreamGrpcEndpoint,
+		&o.exchangeGrpcEndpoint,
+		&o.explorerGrpcEndpoint,
+	)
+
+	initCosmosKeyOptions(
+		cmd,
+		&o.cosmosKeyringDir,
+		&o.cosmosKeyringAppName,
+		&o.cosmosKeyringBackend,
+		&o.cosmosKeyFrom,
+		&o.cosmosKeyPassphrase,
+		&o.cosmosPrivKey,
+		&o.cosmosUseLedger,
+	)
+
+	return o
+}
+
+func (o *clientOptions) createNetwork() (common.Network, error) {
+	return createNetwork(
+		*o.networkName,
+		*o.chainID,
+		*o.lcdEndpoint,
+		*o.tendermintEndpoint,
+		*o.chainGrpcEndpoint,
+		*o.chainStreamGrpcEndpoint,
+		*o.exchangeGrpcEndpoint,
+		*o.explorerGrpcEndpoint,
+	)
+}
+
+// initGrantCmdOptions sets the grantees, message types, expiration and dry run options of the grant commands.
+func initGrantCmdOptions(cmd *cli.Cmd, withExpiration bool) (grantees, msgTypes, expireIn *string, dryRun *bool) {
+	grantees = cmd.String(cli.StringOpt{
+		Name: "grantee",
+		Desc: "Comma separated public addresses of the grantee keys",
+	})
+
+	msgTypes = cmd.String(cli.StringOpt{
+		Name:  "msg-types",
+		Desc:  "Comma separated message types executed by the grantee keys",
+		Value: strings.Join(grants.BotMsgTypes, ","),
+	})
+
+	if withExpiration {
+		expireIn = cmd.String(cli.StringOpt{
+			Name:  "expire-in",
+			Desc:  "Time the grants remain valid",
+			Value: "8760h",
+		})
+	}
+
+	dryRun = cmd.Bool(cli.BoolOpt{
+		Name:  "dry-run",
+		Desc:  "Print the unsigned transaction instead of broadcasting it",
+		Value: false,
+	})
+
+	return grantees, msgTypes, expireIn, dryRun
+}
+
+// grantContext is the granter account and the grantee keys of a grant command.
+type grantContext struct {
+	granter   types.AccAddress
+	grantees  []types.AccAddress
+	clientCtx cosmosclient.Context
+	network   common.Network
+}
+
+// granterContext returns the context of a command signed with the granter Cosmos key.
+func (o *clientOptions) granterContext(grantees string) *grantContext {
+	granterAddress, cosmosKeyring, err := chainclient.InitCosmosKeyring(
+		*o.cosmosKeyringDir,
+		*o.cosmosKeyringAppName,
+		*o.cosmosKeyringBackend,
+		*o.cosmosKeyFrom,
+		*o.cosmosKeyPassphrase,
+		*o.cosmosPrivKey,
+		*o.cosmosUseLedger,
+	)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to init the granter Cosmos keyring")
+	}
+
+	ctx := o.newContext(granterAddress, cosmosKeyring, grantees)
+	for _, grantee := range ctx.grantees {
+		if grantee.Equals(granterAddress) {
+			log.Fatalf("the granter key %s is one of the grantees, the Cosmos key options must be the granter ones", granterAddress.String())
+		}
+	}
+
+	return ctx
+}
+
+// readOnlyContext returns the context of a command only querying the grants of the granter.
+func (o *clientOptions) readOnlyContext(granter, grantees string) *grantContext {
+	granterAddress, err := types.AccAddressFromBech32(granter)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to generate an address from the granter public address")
+	}
+
+	return o.newContext(granterAddress, nil, grantees)
+}
+
+func (o *clientOptions) newContext(granter types.AccAddress, cosmosKeyring keyring.Keyring, grantees string) *grantContext {
+	ctx := &grantContext{granter: granter}
+
+	for _, grantee := range splitList(grantees) {
+		granteeAddress, err := types.AccAddressFromBech32(grantee)
+		if err != nil {
+			log.WithError(err).Fatalf("invalid grantee address %s", grantee)
+		}
+		ctx.grantees = append(ctx.grantees, granteeAddress)
+	}
+	if len(ctx.grantees) == 0 {
+		log.Fatalln("no grantee address, set the --grantee option")
+	}
+
+	network, err := o.createNetwork()
+	if err != nil {
+		log.WithError(err).Fatalln("failed to configure the network")
+	}
+	ctx.network = network
+
+	fromSpec := ""
+	if cosmosKeyring != nil {
+		fromSpec = granter.String()
+	}
+	ctx.clientCtx, err = chainclient.NewClientContext(network.ChainId, fromSpec, cosmosKeyring)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to initialize cosmos client context")
+	}
+
+	return ctx
+}
+
+// chainClient connects the chain client of the command.
+func (c *grantContext) chainClient() chainclient.ChainClient {
+	tmClient, err := rpchttp.New(c.network.TmEndpoint, "/websocket")
+	if err != nil {
+		log.WithError(err).Fatalln("failed to connect to tendermint RPC")
+	}
+	clientCtx := c.clientCtx.WithNodeURI(c.network.TmEndpoint).WithClient(tmClient)
+	if c.clientCtx.Keyring != nil {
+		clientCtx = clientCtx.WithFromAddress(c.granter)
+	}
+
+	chainClient, err := chainclient.NewChainClient(
+		clientCtx,
+		c.network,
+		common.OptionGasPrices(client.DefaultGasPriceWithDenom),
+	)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to connect chain client, is injectived running?")
+	}
+
+	return chainClient
+}
+
+// existingGrants returns the grants of the grantees among the message types.
+func (c *grantContext) existingGrants(msgTypes []string) []grants.Grant {
+	chainClient := c.chainClient()
+	defer chainClient.Close()
+
+	var existing []grants.Grant
+	for _, grantee := range c.grantees {
+		for _, msgType := range msgTypes {
+			grant, err := grants.Fetch(context.Background(), chainClient, c.granter.String(), grantee.String(), msgType)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to fetch the grants")
+			}
+			if grant.Found {
+				existing = append(existing, grant)
+			}
+		}
+	}
+
+	return existing
+}
+
+// sendGrantMsgs broadcasts the messages signed by the granter key, or prints the unsigned transaction
+// in dry run mode.
+func (c *grantContext) sendGrantMsgs(msgs []types.Msg, dryRun bool) {
+	if dryRun {
+		txJSON, err := unsignedTxJSON(c.clientCtx, msgs)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to encode the unsigned transaction")
+		}
+		fmt.Println(string(txJSON))
+		return
+	}
+
+	chainClient := c.chainClient()
+	defer chainClient.Close()
+
+	res, err := chainClient.SyncBroadcastMsg(msgs...)
+	if err != nil {
+		log.WithError(err).Fatalln("failed to broadcast the grants transaction")
+	}
+	if res.TxResponse.Code != 0 {
+		log.Fatalf("grants transaction %s failed with code %d: %s", res.TxResponse.TxHash, res.TxResponse.Code, res.TxResponse.RawLog)
+	}
+
+	fmt.Printf("Grants transaction %s included at height %d\n", res.TxResponse.TxHash, res.TxResponse.Height)
+}
+
+func unsignedTxJSON(clientCtx cosmosclient.Context, msgs []types.Msg) ([]byte, error) {
+	txBuilder := clientCtx.TxConfig.NewTxBuilder()
+	if err := txBuilder.SetMsgs(msgs...); err != nil {
+		return nil, errors.Wrap(err, "failed to set the transaction messages")
+	}
+
+	return clientCtx.TxConfig.TxJSONEncoder()(txBuilder.GetTx())
+}
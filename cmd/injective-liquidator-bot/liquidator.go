@@ -4,15 +4,29 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"cosmossdk.io/math"
 
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/config"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/dedup"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/grants"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/health"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/inventory"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/pricing"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/scoring"
 	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/service"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/txtracker"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/unwind"
 	"github.com/InjectiveLabs/sdk-go/client"
 	"github.com/InjectiveLabs/sdk-go/client/common"
 	"github.com/cosmos/cosmos-sdk/types"
 	eth "github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
 
 	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
 	cli "github.com/jawher/mow.cli"
@@ -24,6 +38,9 @@ import (
 	exchangeclient "github.com/InjectiveLabs/sdk-go/client/exchange"
 )
 
+// txConfirmationPollInterval is the time between two requests of a liquidation transaction waiting for its confirmation.
+const txConfirmationPollInterval = time.Second
+
 // liquidatorCmd action runs the service
 //
 // $ injective-liquidator-bot start
@@ -51,12 +68,13 @@ func liquidatorCmd(cmd *cli.Cmd) {
 		cosmosUseLedger     *bool
 
 		// Metrics
-		statsdAgent    *string
-		statsdPrefix   *string
-		statsdAddr     *string
-		statsdStuckDur *string
-		statsdMocking  *string
-		statsdDisabled *string
+		statsdAgent          *string
+		statsdPrefix         *string
+		statsdAddr           *string
+		statsdStuckDur       *string
+		statsdMocking        *string
+		statsdDisabled       *string
+		prometheusListenAddr *string
 
 		//Liquidation
 		subaccountIndex        *int
@@ -65,6 +83,74 @@ func liquidatorCmd(cmd *cli.Cmd) {
 		granterSubaccountIndex *int
 		maxOrderAmount         *string
 		maxOrderNotional       *string
+		marketLimits           *string
+		pricingPolicy          *string
+		marketPricingPolicies  *string
+
+		// Detection
+		detectionMode    *string
+		pollInterval     *string
+		streamRetryDelay *string
+
+		// Profitability
+		minProfit        *string
+		estimatedGas     *int
+		feeTokenMarketID *string
+
+		// Batching
+		batchMaxMessages      *int
+		batchMaxGas           *int
+		maxInFlightTxs        *int
+		txConfirmationTimeout *string
+
+		// Shutdown
+		drainTimeout *string
+
+		// Simulation
+		simulate                *string
+		simulationGasMultiplier *string
+
+		// Dry run
+		dryRun       *bool
+		dryRunOutput *string
+
+		// Inventory
+		maxMarketExposure *string
+		maxTotalExposure  *string
+
+		// Unwind
+		unwindPolicy      *string
+		unwindInterval    *string
+		unwindMaxSlippage *string
+
+		// Hedge
+		hedgeMarkets     *string
+		hedgeMaxSlippage *string
+
+		// Scoring
+		scoringWeights *string
+
+		// Deduplication
+		liquidationCoolDown   *string
+		pendingLiquidationTTL *string
+
+		// Grantee keys
+		granteeKeys                 *string
+		granteePrivKeys             *string
+		granteeSelection            *string
+		granteeMinBalance           *string
+		granteeBalanceCheckInterval *string
+
+		// Grants
+		grantCheckInterval  *string
+		grantExpiryWarnings *string
+
+		// Reload
+		configWatchInterval *string
+
+		// Health
+		healthListenAddr *string
+		readyMaxCheckAge *string
 	)
 
 	initNetworkOptions(
@@ -100,6 +186,11 @@ func liquidatorCmd(cmd *cli.Cmd) {
 		&statsdDisabled,
 	)
 
+	initPrometheusOptions(
+		cmd,
+		&prometheusListenAddr,
+	)
+
 	initLiquidationOptions(
 		cmd,
 		&subaccountIndex,
@@ -108,12 +199,121 @@ func liquidatorCmd(cmd *cli.Cmd) {
 		&granterSubaccountIndex,
 		&maxOrderAmount,
 		&maxOrderNotional,
+		&marketLimits,
+		&pricingPolicy,
+		&marketPricingPolicies,
+	)
+
+	initDetectionOptions(
+		cmd,
+		&detectionMode,
+		&pollInterval,
+		&streamRetryDelay,
+	)
+
+	initProfitabilityOptions(
+		cmd,
+		&minProfit,
+		&estimatedGas,
+		&feeTokenMarketID,
+	)
+
+	initBatchOptions(
+		cmd,
+		&batchMaxMessages,
+		&batchMaxGas,
+		&maxInFlightTxs,
+		&txConfirmationTimeout,
+	)
+
+	initShutdownOptions(
+		cmd,
+		&drainTimeout,
+	)
+
+	initSimulationOptions(
+		cmd,
+		&simulate,
+		&simulationGasMultiplier,
+	)
+
+	initDryRunOptions(
+		cmd,
+		&dryRun,
+		&dryRunOutput,
+	)
+
+	initInventoryOptions(
+		cmd,
+		&maxMarketExposure,
+		&maxTotalExposure,
+	)
+
+	initUnwindOptions(
+		cmd,
+		&unwindPolicy,
+		&unwindInterval,
+		&unwindMaxSlippage,
+	)
+
+	initHedgeOptions(
+		cmd,
+		&hedgeMarkets,
+		&hedgeMaxSlippage,
+	)
+
+	initScoringOptions(
+		cmd,
+		&scoringWeights,
+	)
+
+	initDedupOptions(
+		cmd,
+		&liquidationCoolDown,
+		&pendingLiquidationTTL,
+	)
+
+	initGranteeOptions(
+		cmd,
+		&granteeKeys,
+		&granteePrivKeys,
+		&granteeSelection,
+		&granteeMinBalance,
+		&granteeBalanceCheckInterval,
+	)
+
+	initGrantOptions(
+		cmd,
+		&grantCheckInterval,
+		&grantExpiryWarnings,
+	)
+
+	initReloadOptions(
+		cmd,
+		&configWatchInterval,
+	)
+
+	initHealthOptions(
+		cmd,
+		&healthListenAddr,
+		&readyMaxCheckAge,
 	)
 
 	cmd.Action = func() {
+		// SIGHUP reloads the configuration
+		closer.Init(closer.Config{
+			ExitCodeOK:  closer.ExitCodeOK,
+			ExitCodeErr: closer.ExitCodeErr,
+			ExitSignals: []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGABRT},
+		})
+
 		// ensure a clean exit
 		defer closer.Close()
 
+		// cancelled on shutdown to stop every pending request of the service
+		ctx, cancelFn := context.WithCancel(context.Background())
+		closer.Bind(cancelFn)
+
 		startMetricsGathering(
 			statsdAgent,
 			statsdPrefix,
@@ -121,6 +321,7 @@ func liquidatorCmd(cmd *cli.Cmd) {
 			statsdStuckDur,
 			statsdMocking,
 			statsdDisabled,
+			prometheusListenAddr,
 		)
 
 		if *cosmosUseLedger {
@@ -179,6 +380,30 @@ func liquidatorCmd(cmd *cli.Cmd) {
 			daemonClient.Close()
 		})
 
+		granteeClients, err := createGranteeClients(
+			network,
+			tmClient,
+			*cosmosKeyringDir,
+			*cosmosKeyringAppName,
+			*cosmosKeyringBackend,
+			*cosmosKeyPassphrase,
+			splitList(*granteeKeys),
+			splitList(*granteePrivKeys),
+		)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to init the grantee keys")
+		}
+		for _, granteeClient := range granteeClients {
+			granteeClient := granteeClient
+			closer.Bind(func() {
+				granteeClient.Close()
+			})
+		}
+		signerSettings, err := parseSignerSettings(*granteeSelection, *granteeMinBalance, duration(*granteeBalanceCheckInterval, time.Minute))
+		if err != nil {
+			log.WithError(err).Fatalln("failed to parse the grantee options")
+		}
+
 		exchangeClient, err := exchangeclient.NewExchangeClient(network)
 		if err != nil {
 			log.WithError(err).Fatalln("failed to connect exchange client, is indexer running?")
@@ -190,7 +415,7 @@ func liquidatorCmd(cmd *cli.Cmd) {
 		log.Infoln("Waiting for GRPC services")
 		time.Sleep(1 * time.Second)
 
-		daemonWaitCtx, cancelWait := context.WithTimeout(context.Background(), time.Minute)
+		daemonWaitCtx, cancelWait := context.WithTimeout(ctx, time.Minute)
 		daemonConn := daemonClient.QueryClient()
 		err = waitForService(daemonWaitCtx, daemonConn)
 		if err != nil {
@@ -198,7 +423,7 @@ func liquidatorCmd(cmd *cli.Cmd) {
 		}
 		cancelWait()
 
-		exchangeWaitCtx, cancelWait := context.WithTimeout(context.Background(), time.Minute)
+		exchangeWaitCtx, cancelWait := context.WithTimeout(ctx, time.Minute)
 		exchangeConn := exchangeClient.QueryClient()
 		err = waitForService(exchangeWaitCtx, exchangeConn)
 		if err != nil {
@@ -206,63 +431,248 @@ func liquidatorCmd(cmd *cli.Cmd) {
 		}
 		cancelWait()
 
-		marketsAssistant, err := chainclient.NewMarketsAssistantInitializedFromChain(context.Background(), exchangeClient)
+		marketsAssistant, err := chainclient.NewMarketsAssistantInitializedFromChain(ctx, exchangeClient)
 		if err != nil {
 			log.WithError(err).Fatalln("failed to initialize the markets assistant")
 		}
 
-		subaccountID := daemonClient.Subaccount(senderAddress, *subaccountIndex)
-		granterSubaccountID := eth.HexToHash("")
+		var profiles map[string]config.Profile
+		if configFile != nil {
+			profiles = configFile.Markets
+		}
+		startupOptions := parameterOptions{
+			marketID:              *marketID,
+			maxOrderAmount:        *maxOrderAmount,
+			maxOrderNotional:      *maxOrderNotional,
+			marketLimits:          *marketLimits,
+			pricingPolicy:         *pricingPolicy,
+			marketPricingPolicies: *marketPricingPolicies,
+			minProfit:             *minProfit,
+			maxMarketExposure:     *maxMarketExposure,
+			maxTotalExposure:      *maxTotalExposure,
+		}
+		params, err := parseParameters(startupOptions, profiles, exchangeClient)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to parse the liquidation parameters")
+		}
+
+		profitSettings, err := parseProfitSettings(*estimatedGas, *feeTokenMarketID)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to parse the profitability options")
+		}
+
+		if *batchMaxMessages < 1 || *batchMaxGas < 0 {
+			log.Fatalf("invalid batch limits: max messages %d, max gas %d", *batchMaxMessages, *batchMaxGas)
+		}
+		if *maxInFlightTxs < 1 {
+			log.Fatalf("invalid max in flight transactions %d", *maxInFlightTxs)
+		}
+		batchSettings := service.BatchSettings{
+			MaxMessages:   *batchMaxMessages,
+			MaxGas:        uint64(*batchMaxGas),
+			GasPerMessage: profitSettings.EstimatedGas,
+			MaxInFlight:   *maxInFlightTxs,
+		}
+
+		gasMultiplier, err := strconv.ParseFloat(*simulationGasMultiplier, 64)
+		if err != nil || gasMultiplier < 1 {
+			log.Fatalf("invalid simulation gas multiplier %s", *simulationGasMultiplier)
+		}
+		simulationSettings := service.SimulationSettings{
+			Enabled:       toBool(*simulate),
+			GasMultiplier: gasMultiplier,
+		}
 
-		if *granterPublicAddress != "" {
-			granterAddress, err := types.AccAddressFromBech32(*granterPublicAddress)
+		var dryRunRecorder *service.DryRunRecorder
+		if *dryRun {
+			dryRunFile, err := os.OpenFile(*dryRunOutput, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 			if err != nil {
-				log.WithError(err).Fatalln("failed to generate an address from the granter public address")
+				log.WithError(err).Fatalln("failed to open the dry run output file")
 			}
+			closer.Bind(func() {
+				dryRunFile.Close()
+			})
 
-			granterSubaccountID = daemonClient.Subaccount(granterAddress, *granterSubaccountIndex)
+			dryRunRecorder = service.NewDryRunRecorder(dryRunFile)
+			log.Warningf("Running in dry run mode, liquidations are written to %s and not broadcast", *dryRunOutput)
 		}
 
-		parsedMaxOrderAmount := math.LegacyMaxSortableDec
-		if *maxOrderAmount != "" {
-			parsedMaxOrderAmount, err = math.LegacyNewDecFromStr(*maxOrderAmount)
-			if err != nil {
-				log.WithError(err).Fatalf("failed to parse max order amount %s", *maxOrderAmount)
-				parsedMaxOrderAmount = math.LegacyMaxSortableDec
+		unwindSettings, err := parseUnwindSettings(*unwindPolicy, *unwindMaxSlippage, duration(*unwindInterval, time.Minute))
+		if err != nil {
+			log.WithError(err).Fatalln("failed to parse the unwind options")
+		}
+
+		hedgeSettings, err := parseHedgeSettings(*hedgeMarkets, *hedgeMaxSlippage)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to parse the hedge options")
+		}
+		for _, hedge := range hedgeSettings.Markets {
+			_, isDerivative := marketsAssistant.AllDerivativeMarkets()[hedge.MarketID]
+			_, isSpot := marketsAssistant.AllSpotMarkets()[hedge.MarketID]
+			if !isDerivative && !isSpot {
+				log.Fatalf("hedge market %s not found", hedge.MarketID)
 			}
 		}
-		parsedMaxOrderNotional := math.LegacyMaxSortableDec
-		if *maxOrderNotional != "" {
-			parsedMaxOrderNotional, err = math.LegacyNewDecFromStr(*maxOrderNotional)
-			if err != nil {
-				log.WithError(err).Fatalf("failed to parse max order notional %s", *maxOrderNotional)
-				parsedMaxOrderNotional = math.LegacyMaxSortableDec
+
+		weights, err := scoring.ParseWeights(*scoringWeights)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to parse the scoring weights")
+		}
+
+		expiryWarnings, err := grants.ParseThresholds(*grantExpiryWarnings)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to parse the grant expiry warnings")
+		}
+
+		newDetector := func() service.Detector {
+			pollDetector := service.NewPollDetector(exchangeClient, duration(*pollInterval, 10*time.Second))
+			switch *detectionMode {
+			case service.DetectionModePoll:
+				return pollDetector
+			case service.DetectionModeStream:
+				return service.NewFallbackDetector(
+					service.NewStreamDetector(daemonClient, exchangeClient),
+					pollDetector,
+					duration(*streamRetryDelay, time.Minute),
+				)
+			default:
+				log.Fatalf("invalid detection mode %s", *detectionMode)
+				return nil
 			}
 		}
 
-		svc := service.NewService(
-			daemonClient,
-			exchangeClient,
-			marketsAssistant,
-			*marketID,
-			subaccountID,
-			*granterPublicAddress,
-			granterSubaccountID,
-			parsedMaxOrderAmount,
-			parsedMaxOrderNotional,
-		)
+		defaultAccount := liquidationAccount{
+			subaccountIndex:        *subaccountIndex,
+			granterPublicAddress:   *granterPublicAddress,
+			granterSubaccountIndex: *granterSubaccountIndex,
+		}
+		accounts, err := groupMarketsByAccount(marketsAssistant, params.MarketIDs, profiles, defaultAccount)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to assign the markets to their account")
+		}
+
+		hasGranter := false
+		for _, account := range accounts {
+			hasGranter = hasGranter || account.granterPublicAddress != ""
+		}
+		if len(granteeClients) > 0 && !hasGranter {
+			log.Fatalln("grantee keys require a granter public address")
+		}
+
+		// the services share the signing keys so each key keeps a single account sequence
+		signers := service.NewSignerPool(append([]chainclient.ChainClient{daemonClient}, granteeClients...)...)
+		txTracker := txtracker.NewTracker(daemonClient, duration(*txConfirmationTimeout, time.Minute), txConfirmationPollInterval)
+
+		// a service liquidates the markets of each account
+		// the exposure limits apply to the positions of every account together
+		sharedInventory := inventory.New(params.ExposureLimits)
+
+		services := make([]service.Service, 0, len(accounts))
+		for _, account := range accounts {
+			subaccountID := daemonClient.Subaccount(senderAddress, account.subaccountIndex)
+			granterSubaccountID := eth.HexToHash("")
+			accountSigners := signers.Primary()
+
+			if account.granterPublicAddress != "" {
+				granterAddress, err := types.AccAddressFromBech32(account.granterPublicAddress)
+				if err != nil {
+					log.WithError(err).Fatalln("failed to generate an address from the granter public address")
+				}
+
+				granterSubaccountID = daemonClient.Subaccount(granterAddress, account.granterSubaccountIndex)
+				accountSigners = signers
+			}
+
+			if len(accounts) > 1 {
+				log.WithFields(log.Fields{
+					"subaccount":         subaccountID.Hex(),
+					"granter":            account.granterPublicAddress,
+					"granter_subaccount": granterSubaccountID.Hex(),
+				}).Infof("Liquidating %d markets from the account", len(account.marketIDs))
+			}
+
+			accountParams := params
+			accountParams.MarketIDs = account.marketIDs
+
+			services = append(services, service.NewService(
+				daemonClient,
+				exchangeClient,
+				marketsAssistant,
+				newDetector(),
+				txTracker,
+				dryRunRecorder,
+				sharedInventory,
+				dedup.New(duration(*liquidationCoolDown, 30*time.Second), duration(*pendingLiquidationTTL, 5*time.Minute)),
+				accountSigners,
+				service.Config{
+					Parameters:           accountParams,
+					SubaccountID:         subaccountID,
+					GranterPublicAddress: account.granterPublicAddress,
+					GranterSubaccountID:  granterSubaccountID,
+					Profit:               profitSettings,
+					Batch:                batchSettings,
+					DrainTimeout:         duration(*drainTimeout, 30*time.Second),
+					Simulation:           simulationSettings,
+					Unwind:               unwindSettings,
+					Hedge:                hedgeSettings,
+					ScoringWeights:       weights,
+					Signer:               signerSettings,
+					Grant: service.GrantSettings{
+						CheckInterval: duration(*grantCheckInterval, time.Hour),
+						Monitor:       grants.NewMonitor(expiryWarnings),
+					},
+				},
+			))
+		}
 		closer.Bind(func() {
-			svc.Close()
+			// stop the services before waiting for the in-flight liquidations
+			cancelFn()
+			for _, svc := range services {
+				svc.Close()
+			}
 		})
 
-		go func() {
-			if err := svc.Start(); err != nil {
-				log.Errorln(err)
+		for _, svc := range services {
+			svc := svc
+			go func() {
+				if err := svc.Start(ctx); err != nil {
+					log.Errorln(err)
 
-				// signal there that the app failed
-				os.Exit(1)
+					// signal there that the app failed
+					os.Exit(1)
+				}
+			}()
+		}
+
+		reloader := &configReloader{
+			path:             *configFilePath,
+			envPath:          *envFileName,
+			startup:          startupOptions,
+			file:             configFile,
+			env:              envFileValues,
+			orderbooks:       exchangeClient,
+			marketsAssistant: marketsAssistant,
+			defaultAccount:   defaultAccount,
+			accounts:         accounts,
+			services:         services,
+		}
+		go reloader.run(ctx, duration(*configWatchInterval, 5*time.Second))
+
+		if *healthListenAddr != "" {
+			statusSources := make([]health.StatusSource, 0, len(services))
+			for _, svc := range services {
+				statusSources = append(statusSources, svc)
 			}
-		}()
+
+			healthServer := health.NewServer(*healthListenAddr, duration(*readyMaxCheckAge, time.Minute), []health.NamedConn{
+				{Name: "chain gRPC", Conn: daemonConn},
+				{Name: "exchange gRPC", Conn: exchangeConn},
+			}, statusSources)
+			if err := healthServer.Start(); err != nil {
+				log.WithError(err).Fatalln("failed to start the health server")
+			}
+			closer.Bind(healthServer.Close)
+		}
 
 		closer.Hold()
 	}
@@ -299,3 +709,467 @@ func createNetwork(
 
 	return network, err
 }
+
+// parseMarketIDs splits the comma separated market IDs option.
+func parseMarketIDs(marketIDs string) []string {
+	var ids []string
+	for _, marketID := range strings.Split(marketIDs, ",") {
+		marketID = strings.TrimSpace(marketID)
+		if marketID != "" {
+			ids = append(ids, marketID)
+		}
+	}
+
+	return ids
+}
+
+// parseMarketLimits parses the max order amount and notional values,
+// using the provided defaults for the empty ones.
+func parseMarketLimits(maxOrderAmount, maxOrderNotional string, defaults service.MarketLimits) (service.MarketLimits, error) {
+	limits := defaults
+
+	if maxOrderAmount != "" {
+		parsed, err := math.LegacyNewDecFromStr(maxOrderAmount)
+		if err != nil {
+			return limits, errors.Wrapf(err, "failed to parse max order amount %s", maxOrderAmount)
+		}
+		limits.MaxOrderAmount = parsed
+	}
+
+	if maxOrderNotional != "" {
+		parsed, err := math.LegacyNewDecFromStr(maxOrderNotional)
+		if err != nil {
+			return limits, errors.Wrapf(err, "failed to parse max order notional %s", maxOrderNotional)
+		}
+		limits.MaxOrderNotional = parsed
+	}
+
+	return limits, nil
+}
+
+// parsePerMarketLimits parses the per market limits option
+// (marketID:maxOrderAmount:maxOrderNotional entries separated by commas).
+func parsePerMarketLimits(marketLimits string, defaults service.MarketLimits) (map[string]service.MarketLimits, error) {
+	limitsByMarket := make(map[string]service.MarketLimits)
+
+	for _, entry := range strings.Split(marketLimits, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, errors.Errorf("invalid market limits entry %s", entry)
+		}
+
+		limits, err := parseMarketLimits(parts[1], parts[2], defaults)
+		if err != nil {
+			return nil, err
+		}
+		limitsByMarket[parts[0]] = limits
+	}
+
+	return limitsByMarket, nil
+}
+
+// parsePerMarketPricingPolicies parses the per market pricing policies option
+// (marketID=policy entries separated by commas).
+func parsePerMarketPricingPolicies(marketPolicies string, orderbooks pricing.OrderbookSource) (map[string]pricing.Policy, error) {
+	policiesByMarket := make(map[string]pricing.Policy)
+
+	for _, entry := range strings.Split(marketPolicies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		marketID, spec, found := strings.Cut(entry, "=")
+		if !found || marketID == "" {
+			return nil, errors.Errorf("invalid market pricing policy entry %s", entry)
+		}
+
+		policy, err := pricing.ParsePolicy(spec, orderbooks)
+		if err != nil {
+			return nil, err
+		}
+		policiesByMarket[marketID] = policy
+	}
+
+	return policiesByMarket, nil
+}
+
+// parseProfitSettings parses the profit estimate options.
+func parseProfitSettings(estimatedGas int, feeTokenMarketID string) (service.ProfitSettings, error) {
+	settings := service.ProfitSettings{
+		FeeTokenMarketID: feeTokenMarketID,
+	}
+
+	if estimatedGas < 0 {
+		return settings, errors.Errorf("invalid estimated gas %d", estimatedGas)
+	}
+	settings.EstimatedGas = uint64(estimatedGas)
+
+	return settings, nil
+}
+
+// parameterOptions are the values of the options of the liquidation parameters, reloadable while running.
+type parameterOptions struct {
+	marketID              string
+	maxOrderAmount        string
+	maxOrderNotional      string
+	marketLimits          string
+	pricingPolicy         string
+	marketPricingPolicies string
+	minProfit             string
+	maxMarketExposure     string
+	maxTotalExposure      string
+}
+
+// parseParameters parses the liquidation parameters and applies the market profiles to them. The profitability
+// gate is disabled when no minimum profit is set.
+func parseParameters(
+	options parameterOptions,
+	profiles map[string]config.Profile,
+	orderbooks pricing.OrderbookSource,
+) (service.Parameters, error) {
+	params := service.Parameters{
+		MarketIDs: parseMarketIDs(options.marketID),
+	}
+
+	var err error
+	params.DefaultLimits, err = parseMarketLimits(options.maxOrderAmount, options.maxOrderNotional, service.MarketLimits{
+		MaxOrderAmount:   math.LegacyMaxSortableDec,
+		MaxOrderNotional: math.LegacyMaxSortableDec,
+	})
+	if err != nil {
+		return params, errors.Wrap(err, "failed to parse the liquidation limits")
+	}
+
+	params.MarketLimits, err = parsePerMarketLimits(options.marketLimits, params.DefaultLimits)
+	if err != nil {
+		return params, errors.Wrap(err, "failed to parse the per market liquidation limits")
+	}
+
+	params.DefaultPricing, err = pricing.ParsePolicy(options.pricingPolicy, orderbooks)
+	if err != nil {
+		return params, errors.Wrap(err, "failed to parse the pricing policy")
+	}
+
+	params.MarketPricing, err = parsePerMarketPricingPolicies(options.marketPricingPolicies, orderbooks)
+	if err != nil {
+		return params, errors.Wrap(err, "failed to parse the per market pricing policies")
+	}
+
+	if options.minProfit != "" {
+		params.MinProfit.Decimal, err = decimal.NewFromString(options.minProfit)
+		if err != nil {
+			return params, errors.Wrapf(err, "failed to parse min profit %s", options.minProfit)
+		}
+		params.MinProfit.Valid = true
+	}
+
+	params.ExposureLimits, err = parseInventoryLimits(options.maxMarketExposure, options.maxTotalExposure)
+	if err != nil {
+		return params, errors.Wrap(err, "failed to parse the inventory limits")
+	}
+
+	params.MarketMinProfit, err = applyMarketProfiles(profiles, params.DefaultLimits, params.MarketLimits, params.MarketPricing, orderbooks)
+	if err != nil {
+		return params, errors.Wrap(err, "failed to apply the market profiles of the configuration file")
+	}
+
+	return params, nil
+}
+
+// applyMarketProfiles applies the limits and pricing policies of the configuration file market profiles to the
+// per market settings, the entries of the per market options taking precedence. It returns the minimum profit
+// by market.
+func applyMarketProfiles(
+	profiles map[string]config.Profile,
+	defaultLimits service.MarketLimits,
+	limitsByMarket map[string]service.MarketLimits,
+	policiesByMarket map[string]pricing.Policy,
+	orderbooks pricing.OrderbookSource,
+) (map[string]decimal.Decimal, error) {
+	minProfitByMarket := make(map[string]decimal.Decimal)
+
+	for marketID, profile := range profiles {
+		if _, found := limitsByMarket[marketID]; !found && (profile.MaxOrderAmount != nil || profile.MaxOrderNotional != nil) {
+			limits, err := parseMarketLimits(stringValue(profile.MaxOrderAmount), stringValue(profile.MaxOrderNotional), defaultLimits)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid limits of market %s", marketID)
+			}
+			limitsByMarket[marketID] = limits
+		}
+
+		if _, found := policiesByMarket[marketID]; !found && profile.PricingPolicy != nil {
+			policy, err := pricing.ParsePolicy(*profile.PricingPolicy, orderbooks)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid pricing policy of market %s", marketID)
+			}
+			policiesByMarket[marketID] = policy
+		}
+
+		if profile.MinProfit != nil {
+			minProfit, err := decimal.NewFromString(*profile.MinProfit)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse min profit %s of market %s", *profile.MinProfit, marketID)
+			}
+			minProfitByMarket[marketID] = minProfit
+		}
+	}
+
+	return minProfitByMarket, nil
+}
+
+// liquidationAccount is the subaccount, and the granter when set, liquidating a group of markets.
+type liquidationAccount struct {
+	subaccountIndex        int
+	granterPublicAddress   string
+	granterSubaccountIndex int
+
+	marketIDs []string
+}
+
+// groupMarketsByAccount groups the markets by the account liquidating them, the market profiles overriding
+// the default account. The wildcard market ID is expanded when a profile moves a market to another account.
+func groupMarketsByAccount(
+	marketsAssistant chainclient.MarketsAssistant,
+	marketIDs []string,
+	profiles map[string]config.Profile,
+	defaultAccount liquidationAccount,
+) ([]liquidationAccount, error) {
+	overridden := false
+	for marketID, profile := range profiles {
+		if _, found := marketsAssistant.AllDerivativeMarkets()[marketID]; !found {
+			return nil, errors.Errorf("derivative market %s of the configuration file not found", marketID)
+		}
+		overridden = overridden ||
+			profile.SubaccountIndex != nil || profile.GranterPublicAddress != nil || profile.GranterSubaccountIndex != nil
+	}
+	if !overridden {
+		defaultAccount.marketIDs = marketIDs
+		return []liquidationAccount{defaultAccount}, nil
+	}
+
+	markets, err := service.ResolveMarkets(marketsAssistant, marketIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []liquidationAccount
+	for _, market := range markets {
+		account := defaultAccount
+		if profile, found := profiles[market.Id]; found {
+			if profile.SubaccountIndex != nil {
+				account.subaccountIndex = *profile.SubaccountIndex
+			}
+			if profile.GranterPublicAddress != nil {
+				account.granterPublicAddress = *profile.GranterPublicAddress
+			}
+			if profile.GranterSubaccountIndex != nil {
+				account.granterSubaccountIndex = *profile.GranterSubaccountIndex
+			}
+		}
+
+		i := 0
+		for i < len(accounts) && !accounts[i].sameAccount(account) {
+			i++
+		}
+		if i == len(accounts) {
+			accounts = append(accounts, account)
+		}
+		accounts[i].marketIDs = append(accounts[i].marketIDs, market.Id)
+	}
+
+	return accounts, nil
+}
+
+func (a liquidationAccount) sameAccount(other liquidationAccount) bool {
+	return a.subaccountIndex == other.subaccountIndex &&
+		a.granterPublicAddress == other.granterPublicAddress &&
+		a.granterSubaccountIndex == other.granterSubaccountIndex
+}
+
+// parseInventoryLimits parses the market and total exposure limits, empty values are not enforced.
+func parseInventoryLimits(maxMarketExposure, maxTotalExposure string) (inventory.Limits, error) {
+	limits := inventory.Limits{
+		MaxMarketExposure: math.LegacyZeroDec(),
+		MaxTotalExposure:  math.LegacyZeroDec(),
+	}
+
+	if maxMarketExposure != "" {
+		parsed, err := math.LegacyNewDecFromStr(maxMarketExposure)
+		if err != nil {
+			return limits, errors.Wrapf(err, "failed to parse max market exposure %s", maxMarketExposure)
+		}
+		limits.MaxMarketExposure = parsed
+	}
+
+	if maxTotalExposure != "" {
+		parsed, err := math.LegacyNewDecFromStr(maxTotalExposure)
+		if err != nil {
+			return limits, errors.Wrapf(err, "failed to parse max total exposure %s", maxTotalExposure)
+		}
+		limits.MaxTotalExposure = parsed
+	}
+
+	return limits, nil
+}
+
+// parseUnwindSettings parses the unwind options. The unwind is disabled when no policy is set.
+func parseUnwindSettings(policy string, maxSlippage string, interval time.Duration) (service.UnwindSettings, error) {
+	settings := service.UnwindSettings{
+		Interval: interval,
+	}
+	if policy == "" {
+		return settings, nil
+	}
+	if interval <= 0 {
+		return settings, errors.Errorf("invalid unwind interval %s", interval)
+	}
+
+	slippage, err := math.LegacyNewDecFromStr(maxSlippage)
+	if err != nil || slippage.IsNegative() {
+		return settings, errors.Errorf("invalid unwind max slippage %s", maxSlippage)
+	}
+
+	settings.Policy, err = unwind.ParsePolicy(policy, slippage)
+	if err != nil {
+		return settings, err
+	}
+
+	return settings, nil
+}
+
+// parseHedgeSettings parses the hedge markets option (marketID=hedgeMarketID:ratio entries separated
+// by commas, the ratio is 1 when omitted) and the hedge orders max slippage.
+func parseHedgeSettings(hedgeMarkets string, maxSlippage string) (service.HedgeSettings, error) {
+	settings := service.HedgeSettings{
+		Markets: make(map[string]service.Hedge),
+	}
+
+	slippage, err := math.LegacyNewDecFromStr(maxSlippage)
+	if err != nil || slippage.IsNegative() {
+		return settings, errors.Errorf("invalid hedge max slippage %s", maxSlippage)
+	}
+	settings.MaxSlippageBps = slippage
+
+	for _, entry := range strings.Split(hedgeMarkets, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		marketID, spec, found := strings.Cut(entry, "=")
+		hedgeMarketID, ratioSpec, hasRatio := strings.Cut(spec, ":")
+		if !found || marketID == "" || hedgeMarketID == "" {
+			return settings, errors.Errorf("invalid hedge market entry %s", entry)
+		}
+
+		ratio := math.LegacyOneDec()
+		if hasRatio {
+			ratio, err = math.LegacyNewDecFromStr(ratioSpec)
+			if err != nil || !ratio.IsPositive() {
+				return settings, errors.Errorf("invalid hedge ratio in entry %s", entry)
+			}
+		}
+
+		settings.Markets[marketID] = service.Hedge{
+			MarketID: hedgeMarketID,
+			Ratio:    ratio,
+		}
+	}
+
+	return settings, nil
+}
+
+// createGranteeClients creates a chain client for each additional grantee key, the keys are either names in
+// the Cosmos keyring or private keys in hex.
+func createGranteeClients(
+	network sdkCommon.Network,
+	tmClient *rpchttp.HTTP,
+	keyringDir string,
+	keyringAppName string,
+	keyringBackend string,
+	keyPassphrase string,
+	keyNames []string,
+	privKeys []string,
+) ([]chainclient.ChainClient, error) {
+	var clients []chainclient.ChainClient
+	createClient := func(keyFrom, privKey string) error {
+		granteeAddress, granteeKeyring, err := chainclient.InitCosmosKeyring(
+			keyringDir,
+			keyringAppName,
+			keyringBackend,
+			keyFrom,
+			keyPassphrase,
+			privKey,
+			false,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to init the grantee keyring")
+		}
+
+		clientCtx, err := chainclient.NewClientContext(network.ChainId, granteeAddress.String(), granteeKeyring)
+		if err != nil {
+			return errors.Wrap(err, "failed to initialize the grantee client context")
+		}
+		clientCtx = clientCtx.WithNodeURI(network.TmEndpoint).WithClient(tmClient).WithFromAddress(granteeAddress)
+
+		granteeClient, err := chainclient.NewChainClient(
+			clientCtx,
+			network,
+			common.OptionGasPrices(client.DefaultGasPriceWithDenom),
+		)
+		if err != nil {
+			return errors.Wrapf(err, "failed to connect the chain client of grantee %s", granteeAddress.String())
+		}
+
+		log.Infoln("Using grantee key", granteeAddress.String())
+		clients = append(clients, granteeClient)
+		return nil
+	}
+
+	for _, keyName := range keyNames {
+		if err := createClient(keyName, ""); err != nil {
+			return clients, err
+		}
+	}
+	for _, privKey := range privKeys {
+		if err := createClient("", privKey); err != nil {
+			return clients, err
+		}
+	}
+
+	return clients, nil
+}
+
+// parseSignerSettings parses the grantee keys selection strategy and the minimum INJ balance of a key in
+// rotation, a zero minimum balance disables the balance check.
+func parseSignerSettings(selection string, minBalance string, interval time.Duration) (service.SignerSettings, error) {
+	settings := service.SignerSettings{
+		Selection:            selection,
+		MinBalance:           math.ZeroInt(),
+		BalanceCheckInterval: interval,
+	}
+
+	switch selection {
+	case service.SignerSelectionRoundRobin, service.SignerSelectionLeastPending:
+	default:
+		return settings, errors.Errorf("invalid grantee selection %s", selection)
+	}
+
+	balance, err := math.LegacyNewDecFromStr(minBalance)
+	if err != nil || balance.IsNegative() {
+		return settings, errors.Errorf("invalid grantee min balance %s", minBalance)
+	}
+	settings.MinBalance = balance.MulInt64(1e18).TruncateInt()
+
+	if settings.MinBalance.IsPositive() && interval <= 0 {
+		return settings, errors.Errorf("invalid grantee balance check interval %s", interval)
+	}
+
+	return settings, nil
+}
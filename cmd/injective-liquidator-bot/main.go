@@ -4,18 +4,26 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/config"
 	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/version"
 
 	cli "github.com/jawher/mow.cli"
 	log "github.com/xlab/suplog"
-
-	"github.com/joho/godotenv"
 )
 
 var app = cli.App("injective-liquidator-bot", "Injective's Liquidator Bot.")
 
 var (
-	envFileName    *string
+	// configFile is the loaded configuration file, nil when none is set
+	configFile     *config.File
+	configFilePath *string
+)
+
+var (
+	envFileName *string
+	// envFileValues are the variables of the env file at startup, the file is read again on reload
+	envFileValues map[string]string
+
 	envName        *string
 	appLogLevel    *string
 	svcWaitTimeout *string
@@ -28,11 +36,19 @@ func main() {
 		Value: ".env",
 	})
 
-	err := godotenv.Load(*envFileName)
+	var err error
+	envFileValues, err = loadEnvFile(*envFileName)
 	if err != nil {
 		log.Fatalf("Error loading %s file", *envFileName)
 	}
 
+	configFilePath = app.String(cli.StringOpt{
+		Name:   "c config",
+		Desc:   "File name/path of the YAML or TOML configuration file",
+		EnvVar: "LIQUIDATOR_CONFIG_FILE",
+		Value:  "",
+	})
+
 	initGlobalOptions(
 		&envName,
 		&appLogLevel,
@@ -40,10 +56,22 @@ func main() {
 	)
 
 	app.Before = func() {
+		// the options of the command are declared once the command line is parsed
+		if *configFilePath != "" {
+			if configFile, err = config.Load(*configFilePath); err != nil {
+				log.WithError(err).Fatalf("Error loading %s configuration file", *configFilePath)
+			}
+			if err = applyConfigFile(configFile); err != nil {
+				log.WithError(err).Fatalf("Error applying %s configuration file", *configFilePath)
+			}
+		}
+
 		log.DefaultLogger.SetLevel(logLevel(*appLogLevel))
 	}
 
 	app.Command("start", "Starts the liquidator main loop.", liquidatorCmd)
+	app.Command("grant", "Manages the authz grants of the grantee keys.", grantCmd)
+	app.Command("config", "Checks the bot configuration.", configCmd)
 	app.Command("version", "Print the version information and exit.", versionCmd)
 
 	_ = app.Run(os.Args)
@@ -10,11 +10,14 @@ import (
 	// DEBUG: do not enable in production
 	// _ "net/http/pprof"
 
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/telemetry"
 	"github.com/InjectiveLabs/metrics"
 )
 
 // startMetricsGathering initializes metric reporting client,
-// if not globally disabled by the config.
+// if not globally disabled by the config, and serves the
+// Prometheus metrics when a listen address is set.
+// Both backends can be enabled at once.
 func startMetricsGathering(
 	statsdAgent *string,
 	statsdPrefix *string,
@@ -22,7 +25,16 @@ func startMetricsGathering(
 	statsdStuckDur *string,
 	statsdMocking *string,
 	statsdDisabled *string,
+	prometheusListenAddr *string,
 ) {
+	if *prometheusListenAddr != "" {
+		prometheusServer := telemetry.NewServer(*prometheusListenAddr)
+		if err := prometheusServer.Start(); err != nil {
+			log.WithError(err).Fatalln("failed to start the Prometheus metrics server")
+		}
+		closer.Bind(prometheusServer.Close)
+	}
+
 	if toBool(*statsdDisabled) {
 		// initializes statsd client with a mock one with no-op enabled
 		metrics.Disable()
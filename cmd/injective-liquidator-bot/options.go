@@ -1,6 +1,110 @@
 package main
 
-import cli "github.com/jawher/mow.cli"
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/config"
+)
+
+// fileOption is an option the configuration file sets, unless given on the command line or through its
+// environment variable.
+type fileOption struct {
+	envVar    string
+	setByUser *bool
+	set       func(value string) error
+}
+
+// fileOptions are the options of the running command the configuration file sets, by global option name.
+var fileOptions = make(map[string]fileOption)
+
+// processEnv is the environment of the process before loading the env file. Its variables take precedence
+// over the env file, also on reload.
+var processEnv = make(map[string]string)
+
+func (o fileOption) overridden() bool {
+	return *o.setByUser || os.Getenv(o.envVar) != ""
+}
+
+// fixed reports whether the option is given on the command line or through the process environment, that
+// the reloads do not change unlike the env file and the configuration file.
+func (o fileOption) fixed() bool {
+	return *o.setByUser || processEnv[o.envVar] != ""
+}
+
+// loadEnvFile records the process environment, then sets the variables of the env file it does not set. It
+// returns the variables of the env file.
+func loadEnvFile(path string) (map[string]string, error) {
+	for _, variable := range os.Environ() {
+		if name, value, found := strings.Cut(variable, "="); found {
+			processEnv[name] = value
+		}
+	}
+
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	return values, godotenv.Load(path)
+}
+
+func registerFileOption(envVar string, setByUser *bool, set func(value string) error) {
+	if envVar != "" {
+		fileOptions[config.Key(envVar)] = fileOption{envVar: envVar, setByUser: setByUser, set: set}
+	}
+}
+
+func fileString(cmd *cli.Cmd, opt cli.StringOpt) *string {
+	opt.SetByUser = new(bool)
+	value := cmd.String(opt)
+	registerFileOption(opt.EnvVar, opt.SetByUser, func(v string) error {
+		*value = v
+		return nil
+	})
+	return value
+}
+
+func fileInt(cmd *cli.Cmd, opt cli.IntOpt) *int {
+	opt.SetByUser = new(bool)
+	value := cmd.Int(opt)
+	registerFileOption(opt.EnvVar, opt.SetByUser, func(v string) error {
+		parsed, err := strconv.Atoi(v)
+		*value = parsed
+		return err
+	})
+	return value
+}
+
+func fileBool(cmd *cli.Cmd, opt cli.BoolOpt) *bool {
+	opt.SetByUser = new(bool)
+	value := cmd.Bool(opt)
+	registerFileOption(opt.EnvVar, opt.SetByUser, func(v string) error {
+		parsed, err := strconv.ParseBool(v)
+		*value = parsed
+		return err
+	})
+	return value
+}
+
+// applyConfigFile sets the options of the running command from the global section of the configuration
+// file, except the ones given on the command line or through their environment variable.
+func applyConfigFile(file *config.File) error {
+	for key, value := range file.Global.Values() {
+		option, found := fileOptions[key]
+		if !found || option.overridden() {
+			continue
+		}
+		if err := option.set(value); err != nil {
+			return errors.Wrapf(err, "invalid %s %s", key, value)
+		}
+	}
+	return nil
+}
 
 // initGlobalOptions defines some global CLI options, that are useful for most parts of the app.
 // Before adding option to there, consider moving it into the actual Cmd.
@@ -9,21 +113,21 @@ func initGlobalOptions(
 	appLogLevel **string,
 	svcWaitTimeout **string,
 ) {
-	*envName = app.String(cli.StringOpt{
+	*envName = fileString(app.Cmd, cli.StringOpt{
 		Name:   "metrics-env",
 		Desc:   "The environment name this app runs in. Used for metrics and error reporting.",
 		EnvVar: "LIQUIDATOR_ENV",
 		Value:  "local",
 	})
 
-	*appLogLevel = app.String(cli.StringOpt{
+	*appLogLevel = fileString(app.Cmd, cli.StringOpt{
 		Name:   "l log-level",
 		Desc:   "Available levels: error, warn, info, debug.",
 		EnvVar: "LIQUIDATOR_LOG_LEVEL",
 		Value:  "info",
 	})
 
-	*svcWaitTimeout = app.String(cli.StringOpt{
+	*svcWaitTimeout = fileString(app.Cmd, cli.StringOpt{
 		Name:   "svc-wait-timeout",
 		Desc:   "Standard wait timeout for external services (e.g. Cosmos daemon GRPC connection)",
 		EnvVar: "LIQUIDATOR_SERVICE_WAIT_TIMEOUT",
@@ -42,56 +146,56 @@ func initNetworkOptions(
 	exchangeGrpcEndpoint **string,
 	explorerGrpcEndpoint **string,
 ) {
-	*networkName = cmd.String(cli.StringOpt{
+	*networkName = fileString(cmd, cli.StringOpt{
 		Name:   "network-name",
 		Desc:   "Network to connect to (mainnet, testnet, custom)",
 		EnvVar: "LIQUIDATOR_NETWORK_NAME",
 		Value:  "mainnet",
 	})
 
-	*chainID = cmd.String(cli.StringOpt{
+	*chainID = fileString(cmd, cli.StringOpt{
 		Name:   "cosmos-chain-id",
 		Desc:   "Specify Chain ID for custom network",
 		EnvVar: "LIQUIDATOR_CHAIN_ID",
 		Value:  "injective-1",
 	})
 
-	*lcdEndpoint = cmd.String(cli.StringOpt{
+	*lcdEndpoint = fileString(cmd, cli.StringOpt{
 		Name:   "lcd-endpoint",
 		Desc:   "LCD endpoint for custom network",
 		EnvVar: "LIQUIDATOR_LCD_ENDPOINT",
 		Value:  "http://localhost:10337",
 	})
 
-	*tendermintEndpoint = cmd.String(cli.StringOpt{
+	*tendermintEndpoint = fileString(cmd, cli.StringOpt{
 		Name:   "tendermint-endpoint",
 		Desc:   "Tendermint endpoint for custom network",
 		EnvVar: "LIQUIDATOR_TENDERMINT_ENDPOINT",
 		Value:  "http://localhost:26657",
 	})
 
-	*chainGrpcEndpoint = cmd.String(cli.StringOpt{
+	*chainGrpcEndpoint = fileString(cmd, cli.StringOpt{
 		Name:   "chain-grpc-endpoint",
 		Desc:   "Chain GRPC endpoint for custom network",
 		EnvVar: "LIQUIDATOR_CHAIN_GRPC_ENDPOINT",
 		Value:  "tcp://localhost:9900",
 	})
 
-	*chainStreamGrpcEndpoint = cmd.String(cli.StringOpt{
+	*chainStreamGrpcEndpoint = fileString(cmd, cli.StringOpt{
 		Name:   "chain-stream-grpc-endpoint",
 		Desc:   "ChainStream GRPC endpoint for custom network",
 		EnvVar: "LIQUIDATOR_CHAIN_STREAM_GRPC_ENDPOINT",
 		Value:  "tcp://localhost:9999",
 	})
 
-	*exchangeGrpcEndpoint = cmd.String(cli.StringOpt{
+	*exchangeGrpcEndpoint = fileString(cmd, cli.StringOpt{
 		Name:   "exchange-grpc-endpoint",
 		Desc:   "Exchange GRPC endpoint for custom network",
 		EnvVar: "LIQUIDATOR_EXCHANGE_GRPC_ENDPOINT",
 		Value:  "tcp://localhost:9910",
 	})
 
-	*explorerGrpcEndpoint = cmd.String(cli.StringOpt{
+	*explorerGrpcEndpoint = fileString(cmd, cli.StringOpt{
 		Name:   "explorer-grpc-endpoint",
 		Desc:   "Explorer GRPC endpoint for custom network",
 		EnvVar: "LIQUIDATOR_EXPLORER_GRPC_ENDPOINT",
@@ -109,46 +213,46 @@ func initCosmosKeyOptions(
 	cosmosPrivKey **string,
 	cosmosUseLedger **bool,
 ) {
-	*cosmosKeyringBackend = cmd.String(cli.StringOpt{
+	*cosmosKeyringBackend = fileString(cmd, cli.StringOpt{
 		Name:   "cosmos-keyring",
 		Desc:   "Specify Cosmos keyring backend (os|file|kwallet|pass|test)",
 		EnvVar: "LIQUIDATOR_COSMOS_KEYRING",
 		Value:  "file",
 	})
 
-	*cosmosKeyringDir = cmd.String(cli.StringOpt{
+	*cosmosKeyringDir = fileString(cmd, cli.StringOpt{
 		Name:   "cosmos-keyring-dir",
 		Desc:   "Specify Cosmos keyring dir, if using file keyring.",
 		EnvVar: "LIQUIDATOR_COSMOS_KEYRING_DIR",
 		Value:  "",
 	})
 
-	*cosmosKeyringAppName = cmd.String(cli.StringOpt{
+	*cosmosKeyringAppName = fileString(cmd, cli.StringOpt{
 		Name:   "cosmos-keyring-app",
 		Desc:   "Specify Cosmos keyring app name.",
 		EnvVar: "LIQUIDATOR_COSMOS_KEYRING_APP",
 		Value:  "injectived",
 	})
 
-	*cosmosKeyFrom = cmd.String(cli.StringOpt{
+	*cosmosKeyFrom = fileString(cmd, cli.StringOpt{
 		Name:   "cosmos-from",
 		Desc:   "Specify the Cosmos validator key name or address. If specified, must exist in keyring, ledger or match the privkey.",
 		EnvVar: "LIQUIDATOR_COSMOS_FROM",
 	})
 
-	*cosmosKeyPassphrase = cmd.String(cli.StringOpt{
+	*cosmosKeyPassphrase = fileString(cmd, cli.StringOpt{
 		Name:   "cosmos-from-passphrase",
 		Desc:   "Specify keyring passphrase, otherwise Stdin will be used.",
 		EnvVar: "LIQUIDATOR_COSMOS_FROM_PASSPHRASE",
 	})
 
-	*cosmosPrivKey = cmd.String(cli.StringOpt{
+	*cosmosPrivKey = fileString(cmd, cli.StringOpt{
 		Name:   "cosmos-pk",
 		Desc:   "Provide a raw Cosmos account private key of the validator in hex. USE FOR TESTING ONLY!",
 		EnvVar: "LIQUIDATOR_COSMOS_PK",
 	})
 
-	*cosmosUseLedger = cmd.Bool(cli.BoolOpt{
+	*cosmosUseLedger = fileBool(cmd, cli.BoolOpt{
 		Name:   "cosmos-use-ledger",
 		Desc:   "Use the Cosmos app on hardware ledger to sign transactions.",
 		EnvVar: "LIQUIDATOR_COSMOS_USE_LEDGER",
@@ -166,42 +270,42 @@ func initStatsdOptions(
 	statsdMocking **string,
 	statsdDisabled **string,
 ) {
-	*statsdAgent = cmd.String(cli.StringOpt{
+	*statsdAgent = fileString(cmd, cli.StringOpt{
 		Name:   "statsd-agent",
 		Desc:   "Specify StatsD agent.",
 		EnvVar: "LIQUIDATOR_STATSD_AGENT",
 		Value:  "telegraf",
 	})
 
-	*statsdPrefix = cmd.String(cli.StringOpt{
+	*statsdPrefix = fileString(cmd, cli.StringOpt{
 		Name:   "statsd-prefix",
 		Desc:   "Specify StatsD compatible metrics prefix.",
 		EnvVar: "LIQUIDATOR_STATSD_PREFIX",
 		Value:  "liquidator",
 	})
 
-	*statsdAddr = cmd.String(cli.StringOpt{
+	*statsdAddr = fileString(cmd, cli.StringOpt{
 		Name:   "statsd-addr",
 		Desc:   "UDP address of a StatsD compatible metrics aggregator.",
 		EnvVar: "LIQUIDATOR_STATSD_ADDR",
 		Value:  "localhost:8125",
 	})
 
-	*statsdStuckDur = cmd.String(cli.StringOpt{
+	*statsdStuckDur = fileString(cmd, cli.StringOpt{
 		Name:   "statsd-stuck-func",
 		Desc:   "Sets a duration to consider a function to be stuck (e.g. in deadlock).",
 		EnvVar: "LIQUIDATOR_STATSD_STUCK_DUR",
 		Value:  "5m",
 	})
 
-	*statsdMocking = cmd.String(cli.StringOpt{
+	*statsdMocking = fileString(cmd, cli.StringOpt{
 		Name:   "statsd-mocking",
 		Desc:   "If enabled replaces statsd client with a mock one that simply logs values.",
 		EnvVar: "LIQUIDATOR_STATSD_MOCKING",
 		Value:  "false",
 	})
 
-	*statsdDisabled = cmd.String(cli.StringOpt{
+	*statsdDisabled = fileString(cmd, cli.StringOpt{
 		Name:   "statsd-disabled",
 		Desc:   "Force disabling statsd reporting completely.",
 		EnvVar: "LIQUIDATOR_STATSD_DISABLED",
@@ -209,6 +313,19 @@ func initStatsdOptions(
 	})
 }
 
+// initPrometheusOptions sets options for the Prometheus metrics endpoint.
+func initPrometheusOptions(
+	cmd *cli.Cmd,
+	prometheusListenAddr **string,
+) {
+	*prometheusListenAddr = fileString(cmd, cli.StringOpt{
+		Name:   "prometheus-listen-addr",
+		Desc:   "Address serving the Prometheus metrics on /metrics (disabled when empty)",
+		EnvVar: "LIQUIDATOR_PROMETHEUS_LISTEN_ADDR",
+		Value:  "",
+	})
+}
+
 func initLiquidationOptions(
 	cmd *cli.Cmd,
 	subaccountIndex **int,
@@ -217,46 +334,425 @@ func initLiquidationOptions(
 	granterSubaccountIndex **int,
 	maxOrderAmount **string,
 	maxOrderNotional **string,
+	marketLimits **string,
+	pricingPolicy **string,
+	marketPricingPolicies **string,
 ) {
-	*subaccountIndex = cmd.Int(cli.IntOpt{
+	*subaccountIndex = fileInt(cmd, cli.IntOpt{
 		Name:   "subaccount-index",
 		Desc:   "Subaccount number to use to create the liquidation orders",
 		EnvVar: "LIQUIDATOR_SUBACCOUNT_INDEX",
 		Value:  0,
 	})
 
-	*marketID = cmd.String(cli.StringOpt{
+	*marketID = fileString(cmd, cli.StringOpt{
 		Name:   "market-id",
-		Desc:   "Market ID of the market to check liquidations for",
+		Desc:   "Comma separated IDs of the markets to check liquidations for, or 'all' for every active perpetual and expiry market",
 		EnvVar: "LIQUIDATOR_MARKET_ID",
 		Value:  "",
 	})
 
-	*granterPublicAddress = cmd.String(cli.StringOpt{
+	*granterPublicAddress = fileString(cmd, cli.StringOpt{
 		Name:   "granter-public-address",
 		Desc:   "Public address of the granter account (when using a grantee account to broadcast the TXs for a granter account)",
 		EnvVar: "LIQUIDATOR_GRANTER_PUBLIC_ADDRESS",
 		Value:  "",
 	})
 
-	*granterSubaccountIndex = cmd.Int(cli.IntOpt{
+	*granterSubaccountIndex = fileInt(cmd, cli.IntOpt{
 		Name:   "granter-subaccount-index",
 		Desc:   "Subaccount number to use to create the liquidation orders (when using a granter account)",
 		EnvVar: "LIQUIDATOR_GRANTER_SUBACCOUNT_INDEX",
 		Value:  0,
 	})
 
-	*maxOrderAmount = cmd.String(cli.StringOpt{
+	*maxOrderAmount = fileString(cmd, cli.StringOpt{
 		Name:   "max-order-amount",
 		Desc:   "Maximum amount for liquidation orders (in base asset)",
 		EnvVar: "LIQUIDATOR_MAX_ORDER_AMOUNT",
 		Value:  "",
 	})
 
-	*maxOrderNotional = cmd.String(cli.StringOpt{
+	*maxOrderNotional = fileString(cmd, cli.StringOpt{
 		Name:   "max-order-notional",
 		Desc:   "Maximum notional for liquidation orders (in quote asset)",
 		EnvVar: "LIQUIDATOR_MAX_ORDER_NOTIONAL",
 		Value:  "",
 	})
+
+	*marketLimits = fileString(cmd, cli.StringOpt{
+		Name:   "market-limits",
+		Desc:   "Comma separated per market limits overrides, as marketID:maxOrderAmount:maxOrderNotional (empty values use the global limits)",
+		EnvVar: "LIQUIDATOR_MARKET_LIMITS",
+		Value:  "",
+	})
+
+	*pricingPolicy = fileString(cmd, cli.StringOpt{
+		Name:   "pricing-policy",
+		Desc:   "Pricing policy of the liquidation orders: mark, mark_buffer:<bps>, bankruptcy[:<bps>] or orderbook",
+		EnvVar: "LIQUIDATOR_PRICING_POLICY",
+		Value:  "mark",
+	})
+
+	*marketPricingPolicies = fileString(cmd, cli.StringOpt{
+		Name:   "market-pricing-policies",
+		Desc:   "Comma separated per market pricing policy overrides, as marketID=policy",
+		EnvVar: "LIQUIDATOR_MARKET_PRICING_POLICIES",
+		Value:  "",
+	})
+}
+
+// initDetectionOptions sets options for the liquidable positions detection.
+func initDetectionOptions(
+	cmd *cli.Cmd,
+	detectionMode **string,
+	pollInterval **string,
+	streamRetryDelay **string,
+) {
+	*detectionMode = fileString(cmd, cli.StringOpt{
+		Name:   "detection-mode",
+		Desc:   "How liquidable positions are detected: poll (indexer LiquidablePositions API) or stream (chain stream updates, falling back to poll)",
+		EnvVar: "LIQUIDATOR_DETECTION_MODE",
+		Value:  "poll",
+	})
+
+	*pollInterval = fileString(cmd, cli.StringOpt{
+		Name:   "poll-interval",
+		Desc:   "Time between two consecutive liquidable positions requests when polling",
+		EnvVar: "LIQUIDATOR_POLL_INTERVAL",
+		Value:  "10s",
+	})
+
+	*streamRetryDelay = fileString(cmd, cli.StringOpt{
+		Name:   "stream-retry-delay",
+		Desc:   "Time polling for liquidable positions after a chain stream failure, before subscribing again",
+		EnvVar: "LIQUIDATOR_STREAM_RETRY_DELAY",
+		Value:  "1m",
+	})
+}
+
+// initProfitabilityOptions sets options for the liquidations profitability gate.
+func initProfitabilityOptions(
+	cmd *cli.Cmd,
+	minProfit **string,
+	estimatedGas **int,
+	feeTokenMarketID **string,
+) {
+	*minProfit = fileString(cmd, cli.StringOpt{
+		Name:   "min-profit",
+		Desc:   "Minimum expected profit (in quote asset) to broadcast a liquidation. Empty disables the profitability gate",
+		EnvVar: "LIQUIDATOR_MIN_PROFIT",
+		Value:  "",
+	})
+
+	*estimatedGas = fileInt(cmd, cli.IntOpt{
+		Name:   "estimated-gas",
+		Desc:   "Gas expected to be used by each liquidation, to include the gas cost in the profit estimate and to limit the batches gas",
+		EnvVar: "LIQUIDATOR_ESTIMATED_GAS",
+		Value:  400000,
+	})
+
+	*feeTokenMarketID = fileString(cmd, cli.StringOpt{
+		Name:   "fee-token-market-id",
+		Desc:   "ID of the INJ spot market (quoted in the derivative markets quote asset) used to value the gas cost. Empty ignores the gas cost",
+		EnvVar: "LIQUIDATOR_FEE_TOKEN_MARKET_ID",
+		Value:  "",
+	})
+}
+
+// initBatchOptions sets options for grouping several liquidations in one transaction.
+func initBatchOptions(
+	cmd *cli.Cmd,
+	batchMaxMessages **int,
+	batchMaxGas **int,
+	maxInFlightTxs **int,
+	txConfirmationTimeout **string,
+) {
+	*batchMaxMessages = fileInt(cmd, cli.IntOpt{
+		Name:   "batch-max-messages",
+		Desc:   "Maximum number of liquidations sent in one transaction (1 disables batching)",
+		EnvVar: "LIQUIDATOR_BATCH_MAX_MESSAGES",
+		Value:  10,
+	})
+
+	*batchMaxGas = fileInt(cmd, cli.IntOpt{
+		Name:   "batch-max-gas",
+		Desc:   "Maximum estimated gas of a liquidations transaction, using the estimated gas of each liquidation (0 for no limit)",
+		EnvVar: "LIQUIDATOR_BATCH_MAX_GAS",
+		Value:  5000000,
+	})
+
+	*maxInFlightTxs = fileInt(cmd, cli.IntOpt{
+		Name:   "max-in-flight-txs",
+		Desc:   "Maximum number of liquidation transactions awaiting their confirmation (1 sends them one at a time)",
+		EnvVar: "LIQUIDATOR_MAX_IN_FLIGHT_TXS",
+		Value:  4,
+	})
+
+	*txConfirmationTimeout = fileString(cmd, cli.StringOpt{
+		Name:   "tx-confirmation-timeout",
+		Desc:   "Maximum time to wait for a liquidation transaction to be included in a block",
+		EnvVar: "LIQUIDATOR_TX_CONFIRMATION_TIMEOUT",
+		Value:  "1m",
+	})
+}
+
+// initShutdownOptions sets options for the service graceful shutdown.
+func initShutdownOptions(
+	cmd *cli.Cmd,
+	drainTimeout **string,
+) {
+	*drainTimeout = fileString(cmd, cli.StringOpt{
+		Name:   "drain-timeout",
+		Desc:   "Maximum time to wait on shutdown for the in-flight liquidation transactions to be confirmed",
+		EnvVar: "LIQUIDATOR_DRAIN_TIMEOUT",
+		Value:  "30s",
+	})
+}
+
+// initSimulationOptions sets options for the pre-flight simulation of the liquidations.
+func initSimulationOptions(
+	cmd *cli.Cmd,
+	simulate **string,
+	simulationGasMultiplier **string,
+) {
+	*simulate = fileString(cmd, cli.StringOpt{
+		Name:   "simulate",
+		Desc:   "Simulate every liquidation before broadcasting it, skipping the ones that fail and setting the gas limit from the simulated gas",
+		EnvVar: "LIQUIDATOR_SIMULATE",
+		Value:  "false",
+	})
+
+	*simulationGasMultiplier = fileString(cmd, cli.StringOpt{
+		Name:   "simulation-gas-multiplier",
+		Desc:   "Multiplier applied to the simulated gas to set the transactions gas limit",
+		EnvVar: "LIQUIDATOR_SIMULATION_GAS_MULTIPLIER",
+		Value:  "1.3",
+	})
+}
+
+// initDryRunOptions sets options for running the bot without broadcasting the liquidations.
+func initDryRunOptions(
+	cmd *cli.Cmd,
+	dryRun **bool,
+	dryRunOutput **string,
+) {
+	*dryRun = fileBool(cmd, cli.BoolOpt{
+		Name:   "dry-run",
+		Desc:   "Run the whole liquidation pipeline without broadcasting, writing the liquidations that would have been sent to the dry run output file",
+		EnvVar: "LIQUIDATOR_DRY_RUN",
+		Value:  false,
+	})
+
+	*dryRunOutput = fileString(cmd, cli.StringOpt{
+		Name:   "dry-run-output",
+		Desc:   "JSONL file the dry run liquidations are appended to",
+		EnvVar: "LIQUIDATOR_DRY_RUN_OUTPUT",
+		Value:  "dry-run.jsonl",
+	})
+}
+
+// initInventoryOptions sets options for the limits of the inventory acquired through the liquidations.
+func initInventoryOptions(
+	cmd *cli.Cmd,
+	maxMarketExposure **string,
+	maxTotalExposure **string,
+) {
+	*maxMarketExposure = fileString(cmd, cli.StringOpt{
+		Name:   "max-market-exposure",
+		Desc:   "Maximum net position notional (in quote asset) held in each market, liquidations growing it beyond are skipped (no limit when empty)",
+		EnvVar: "LIQUIDATOR_MAX_MARKET_EXPOSURE",
+		Value:  "",
+	})
+
+	*maxTotalExposure = fileString(cmd, cli.StringOpt{
+		Name:   "max-total-exposure",
+		Desc:   "Maximum sum of the net positions notional (in quote asset) of all markets (no limit when empty)",
+		EnvVar: "LIQUIDATOR_MAX_TOTAL_EXPOSURE",
+		Value:  "",
+	})
+}
+
+// initUnwindOptions sets options for closing the positions acquired through the liquidations.
+func initUnwindOptions(
+	cmd *cli.Cmd,
+	unwindPolicy **string,
+	unwindInterval **string,
+	unwindMaxSlippage **string,
+) {
+	*unwindPolicy = fileString(cmd, cli.StringOpt{
+		Name:   "unwind-policy",
+		Desc:   "Policy used to close the positions acquired through the liquidations (market, twap:<minutes> or limit:<spread bps>). Empty disables the unwind",
+		EnvVar: "LIQUIDATOR_UNWIND_POLICY",
+		Value:  "",
+	})
+
+	*unwindInterval = fileString(cmd, cli.StringOpt{
+		Name:   "unwind-interval",
+		Desc:   "Time between two checks of the positions to unwind",
+		EnvVar: "LIQUIDATOR_UNWIND_INTERVAL",
+		Value:  "1m",
+	})
+
+	*unwindMaxSlippage = fileString(cmd, cli.StringOpt{
+		Name:   "unwind-max-slippage",
+		Desc:   "Maximum distance (in bps) from the mark price accepted by the unwind market orders",
+		EnvVar: "LIQUIDATOR_UNWIND_MAX_SLIPPAGE",
+		Value:  "100",
+	})
+}
+
+// initHedgeOptions sets options for hedging the executed liquidations on correlated markets.
+func initHedgeOptions(
+	cmd *cli.Cmd,
+	hedgeMarkets **string,
+	hedgeMaxSlippage **string,
+) {
+	*hedgeMarkets = fileString(cmd, cli.StringOpt{
+		Name:   "hedge-markets",
+		Desc:   "Hedge markets of the liquidated markets, as comma separated marketID=hedgeMarketID:ratio entries (derivative or spot hedge markets). Empty disables the hedge",
+		EnvVar: "LIQUIDATOR_HEDGE_MARKETS",
+		Value:  "",
+	})
+
+	*hedgeMaxSlippage = fileString(cmd, cli.StringOpt{
+		Name:   "hedge-max-slippage",
+		Desc:   "Maximum distance (in bps) from the hedge market price accepted by the hedge orders",
+		EnvVar: "LIQUIDATOR_HEDGE_MAX_SLIPPAGE",
+		Value:  "50",
+	})
+}
+
+// initScoringOptions sets options for ranking the liquidation candidates.
+func initScoringOptions(
+	cmd *cli.Cmd,
+	scoringWeights **string,
+) {
+	*scoringWeights = fileString(cmd, cli.StringOpt{
+		Name:   "scoring-weights",
+		Desc:   "Weights of the criteria ranking the liquidation candidates, as comma separated criterion=weight entries (profit, notional, shortfall and age in seconds)",
+		EnvVar: "LIQUIDATOR_SCORING_WEIGHTS",
+		Value:  "profit=1",
+	})
+}
+
+// initDedupOptions sets options for suppressing the repeated liquidation attempts of a position.
+func initDedupOptions(
+	cmd *cli.Cmd,
+	liquidationCoolDown **string,
+	pendingLiquidationTTL **string,
+) {
+	*liquidationCoolDown = fileString(cmd, cli.StringOpt{
+		Name:   "liquidation-cool-down",
+		Desc:   "Time a position is not liquidated again once the outcome of its previous liquidation is known (0s disables it)",
+		EnvVar: "LIQUIDATOR_LIQUIDATION_COOL_DOWN",
+		Value:  "30s",
+	})
+
+	*pendingLiquidationTTL = fileString(cmd, cli.StringOpt{
+		Name:   "pending-liquidation-ttl",
+		Desc:   "Time after which a liquidation attempt whose outcome is still unknown stops suppressing new attempts",
+		EnvVar: "LIQUIDATOR_PENDING_LIQUIDATION_TTL",
+		Value:  "5m",
+	})
+}
+
+// initGranteeOptions sets options for signing the authz transactions with a pool of grantee keys.
+func initGranteeOptions(
+	cmd *cli.Cmd,
+	granteeKeys **string,
+	granteePrivKeys **string,
+	granteeSelection **string,
+	granteeMinBalance **string,
+	granteeBalanceCheckInterval **string,
+) {
+	*granteeKeys = fileString(cmd, cli.StringOpt{
+		Name:   "grantee-keys",
+		Desc:   "Comma separated names of additional grantee keys in the Cosmos keyring, signing the transactions along with the sender key",
+		EnvVar: "LIQUIDATOR_GRANTEE_KEYS",
+		Value:  "",
+	})
+
+	*granteePrivKeys = fileString(cmd, cli.StringOpt{
+		Name:   "grantee-pks",
+		Desc:   "Comma separated private keys in hex of additional grantee keys, signing the transactions along with the sender key",
+		EnvVar: "LIQUIDATOR_GRANTEE_PKS",
+		Value:  "",
+	})
+
+	*granteeSelection = fileString(cmd, cli.StringOpt{
+		Name:   "grantee-selection",
+		Desc:   "Strategy distributing the transactions over the signing keys (round_robin or least_pending)",
+		EnvVar: "LIQUIDATOR_GRANTEE_SELECTION",
+		Value:  "least_pending",
+	})
+
+	*granteeMinBalance = fileString(cmd, cli.StringOpt{
+		Name:   "grantee-min-balance",
+		Desc:   "INJ balance under which a signing key is taken out of rotation until funded again (0 disables the check)",
+		EnvVar: "LIQUIDATOR_GRANTEE_MIN_BALANCE",
+		Value:  "0",
+	})
+
+	*granteeBalanceCheckInterval = fileString(cmd, cli.StringOpt{
+		Name:   "grantee-balance-check-interval",
+		Desc:   "Time between two checks of the signing keys balance",
+		EnvVar: "LIQUIDATOR_GRANTEE_BALANCE_CHECK_INTERVAL",
+		Value:  "1m",
+	})
+}
+
+// initGrantOptions sets options for checking the authz grants of the granter account.
+func initGrantOptions(
+	cmd *cli.Cmd,
+	grantCheckInterval **string,
+	grantExpiryWarnings **string,
+) {
+	*grantCheckInterval = fileString(cmd, cli.StringOpt{
+		Name:   "grant-check-interval",
+		Desc:   "Time between two checks of the authz grants while running (0s disables them, the grants are always checked at startup)",
+		EnvVar: "LIQUIDATOR_GRANT_CHECK_INTERVAL",
+		Value:  "1h",
+	})
+
+	*grantExpiryWarnings = fileString(cmd, cli.StringOpt{
+		Name:   "grant-expiry-warnings",
+		Desc:   "Comma separated times before a grant expiration a warning is logged",
+		EnvVar: "LIQUIDATOR_GRANT_EXPIRY_WARNINGS",
+		Value:  "720h,168h,24h",
+	})
+}
+
+// initReloadOptions sets options for reloading the liquidation parameters of the configuration file.
+func initReloadOptions(
+	cmd *cli.Cmd,
+	configWatchInterval **string,
+) {
+	*configWatchInterval = fileString(cmd, cli.StringOpt{
+		Name:   "config-watch-interval",
+		Desc:   "Time between two checks of the configuration file changes (0s disables them, SIGHUP still reloads it)",
+		EnvVar: "LIQUIDATOR_CONFIG_WATCH_INTERVAL",
+		Value:  "5s",
+	})
+}
+
+// initHealthOptions sets options for the health, readiness and status endpoints.
+func initHealthOptions(
+	cmd *cli.Cmd,
+	healthListenAddr **string,
+	readyMaxCheckAge **string,
+) {
+	*healthListenAddr = fileString(cmd, cli.StringOpt{
+		Name:   "health-listen-addr",
+		Desc:   "Address serving the /healthz, /readyz and /status endpoints (disabled when empty)",
+		EnvVar: "LIQUIDATOR_HEALTH_LISTEN_ADDR",
+		Value:  "",
+	})
+
+	*readyMaxCheckAge = fileString(cmd, cli.StringOpt{
+		Name:   "ready-max-check-age",
+		Desc:   "Maximum time since the last complete check of the markets for the bot to be ready",
+		EnvVar: "LIQUIDATOR_READY_MAX_CHECK_AGE",
+		Value:  "1m",
+	})
 }
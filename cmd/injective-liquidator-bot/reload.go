@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/config"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/pricing"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/service"
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+)
+
+// reloadableOptions are the global options applied on reload, with their default value.
+var reloadableOptions = map[string]string{
+	"market_id":               "",
+	"max_order_amount":        "",
+	"max_order_notional":      "",
+	"market_limits":           "",
+	"pricing_policy":          "mark",
+	"market_pricing_policies": "",
+	"min_profit":              "",
+	"max_market_exposure":     "",
+	"max_total_exposure":      "",
+}
+
+// configReloader reloads the liquidation parameters of the services from the env file and the configuration
+// file, when the configuration file changes or on SIGHUP.
+type configReloader struct {
+	// path is the configuration file, none when empty
+	path string
+	// envPath is the env file, read again on each reload
+	envPath string
+	// startup are the parameter options at startup, the ones given on the command line or through the
+	// process environment override the env file and the configuration file
+	startup parameterOptions
+	file    *config.File
+	env     map[string]string
+
+	orderbooks       pricing.OrderbookSource
+	marketsAssistant chainclient.MarketsAssistant
+	defaultAccount   liquidationAccount
+	// accounts are the accounts of the services, in the same order
+	accounts []liquidationAccount
+	services []service.Service
+}
+
+// run reloads the configuration on SIGHUP, and when the configuration file modification time changes if
+// watchInterval is positive, until ctx is cancelled.
+func (r *configReloader) run(ctx context.Context, watchInterval time.Duration) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	var watchTicks <-chan time.Time
+	if r.path != "" && watchInterval > 0 {
+		watchTicker := time.NewTicker(watchInterval)
+		defer watchTicker.Stop()
+		watchTicks = watchTicker.C
+	}
+
+	logger := log.WithFields(log.Fields{"env_file": r.envPath, "config_file": r.path})
+	lastModified, _ := r.modTime()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			logger.Infoln("SIGHUP received, reloading the configuration")
+		case <-watchTicks:
+			modified, err := r.modTime()
+			// the file may be missing while an editor replaces it
+			if err != nil || modified.Equal(lastModified) {
+				continue
+			}
+			lastModified = modified
+			logger.Infoln("Configuration file changed, reloading it")
+		}
+
+		if err := r.reload(); err != nil {
+			logger.WithError(err).Errorln("Configuration rejected, the current one stays active")
+		}
+	}
+}
+
+func (r *configReloader) modTime() (time.Time, error) {
+	if r.path == "" {
+		return time.Time{}, nil
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// reload reads the env file and the configuration file, and hands the new parameters to the services. The
+// configuration is rejected as a whole when invalid, or when it moves markets to an account without a service.
+func (r *configReloader) reload() error {
+	env, err := godotenv.Read(r.envPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the env file %s", r.envPath)
+	}
+
+	file := &config.File{}
+	if r.path != "" {
+		if file, err = config.Load(r.path); err != nil {
+			return err
+		}
+	}
+
+	params, err := parseParameters(r.options(file, env), file.Markets, r.orderbooks)
+	if err != nil {
+		return err
+	}
+
+	accounts, err := groupMarketsByAccount(r.marketsAssistant, params.MarketIDs, file.Markets, r.defaultAccount)
+	if err != nil {
+		return err
+	}
+
+	// markets of an account without a service, and services without markets, need a restart
+	paramsByService := make([]service.Parameters, len(r.services))
+	assigned := make([]bool, len(r.services))
+	for _, account := range accounts {
+		i := 0
+		for i < len(r.accounts) && !r.accounts[i].sameAccount(account) {
+			i++
+		}
+		if i == len(r.accounts) {
+			return errors.Errorf("markets %v moved to a new account, a restart is needed", account.marketIDs)
+		}
+
+		paramsByService[i] = params
+		paramsByService[i].MarketIDs = account.marketIDs
+		assigned[i] = true
+	}
+	for i, account := range r.accounts {
+		if !assigned[i] {
+			return errors.Errorf("no markets left for subaccount %d, a restart is needed", account.subaccountIndex)
+		}
+		if _, err := service.ResolveMarkets(r.marketsAssistant, paramsByService[i].MarketIDs); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range restartOnlyChanges(r.file, file, r.env, env) {
+		log.WithFields(log.Fields{"env_file": r.envPath, "config_file": r.path}).Warningf("Change of %s ignored until the bot restarts", name)
+	}
+
+	for i, svc := range r.services {
+		if err := svc.Reload(paramsByService[i]); err != nil {
+			return err
+		}
+	}
+
+	r.file, r.env = file, env
+	return nil
+}
+
+// options returns the values of the parameter options. The command line and the process environment override
+// the env file, that overrides the configuration file.
+func (r *configReloader) options(file *config.File, env map[string]string) parameterOptions {
+	values := file.Global.Values()
+	value := func(key, startup string) string {
+		option, found := fileOptions[key]
+		if !found || option.fixed() {
+			return startup
+		}
+		if value := env[option.envVar]; value != "" {
+			return value
+		}
+		if value, found := values[key]; found {
+			return value
+		}
+		return reloadableOptions[key]
+	}
+
+	return parameterOptions{
+		marketID:              value("market_id", r.startup.marketID),
+		maxOrderAmount:        value("max_order_amount", r.startup.maxOrderAmount),
+		maxOrderNotional:      value("max_order_notional", r.startup.maxOrderNotional),
+		marketLimits:          value("market_limits", r.startup.marketLimits),
+		pricingPolicy:         value("pricing_policy", r.startup.pricingPolicy),
+		marketPricingPolicies: value("market_pricing_policies", r.startup.marketPricingPolicies),
+		minProfit:             value("min_profit", r.startup.minProfit),
+		maxMarketExposure:     value("max_market_exposure", r.startup.maxMarketExposure),
+		maxTotalExposure:      value("max_total_exposure", r.startup.maxTotalExposure),
+	}
+}
+
+// restartOnlyChanges returns the changed options of the configuration file and the env file that are only
+// applied at startup.
+func restartOnlyChanges(current, next *config.File, currentEnv, nextEnv map[string]string) []string {
+	var changes []string
+	if current == nil {
+		current = &config.File{}
+	}
+
+	currentValues, nextValues := current.Global.Values(), next.Global.Values()
+	for key := range mergeKeys(currentValues, nextValues) {
+		if _, reloadable := reloadableOptions[key]; !reloadable && currentValues[key] != nextValues[key] {
+			changes = append(changes, key)
+		}
+	}
+
+	for envVar := range mergeKeys(currentEnv, nextEnv) {
+		key := config.Key(envVar)
+		if _, isOption := fileOptions[key]; !isOption {
+			continue
+		}
+		if _, reloadable := reloadableOptions[key]; !reloadable && currentEnv[envVar] != nextEnv[envVar] {
+			changes = append(changes, envVar)
+		}
+	}
+
+	sort.Strings(changes)
+	return changes
+}
+
+// mergeKeys returns the keys of both maps.
+func mergeKeys[V any](current, next map[string]V) map[string]struct{} {
+	keys := make(map[string]struct{}, len(current)+len(next))
+	for key := range current {
+		keys[key] = struct{}{}
+	}
+	for key := range next {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
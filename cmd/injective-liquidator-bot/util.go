@@ -8,7 +8,8 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/xlab/suplog"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/connectivity"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/health"
 )
 
 // logLevel converts vague log level name into typed level.
@@ -46,6 +47,25 @@ func duration(s string, defaults time.Duration) time.Duration {
 	return dur
 }
 
+// splitList splits a comma separated option, dropping the empty entries.
+func splitList(s string) []string {
+	var entries []string
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// stringValue returns the optional string value, empty when unset.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 // checkStatsdPrefix ensures that the statsd prefix really
 // have "." at end.
 func checkStatsdPrefix(s string) string {
@@ -61,10 +81,12 @@ func waitForService(ctx context.Context, conn *grpc.ClientConn) error {
 		case <-ctx.Done():
 			return errors.Errorf("Service wait timed out. Please run injective exchange service:\n\nmake install && injective-exchange")
 		default:
-			state := conn.GetState()
-
-			if state != connectivity.Ready {
-				time.Sleep(time.Second)
+			// the health server reports the readiness with the same check
+			if !health.Connected(conn) {
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
 				continue
 			}
 
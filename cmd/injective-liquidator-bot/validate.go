@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/sdk-go/client"
+	"github.com/InjectiveLabs/sdk-go/client/common"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	"github.com/cosmos/cosmos-sdk/types"
+	"github.com/pkg/errors"
+
+	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
+	cli "github.com/jawher/mow.cli"
+	"google.golang.org/grpc"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/config"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/grants"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/service"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/validation"
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+	exchangeclient "github.com/InjectiveLabs/sdk-go/client/exchange"
+)
+
+// configCmd groups the commands working on the bot configuration.
+//
+// $ injective-liquidator-bot config validate
+func configCmd(cmd *cli.Cmd) {
+	cmd.Command("validate", "Checks the configuration against the network and prints a pass/fail report.", configValidateCmd)
+}
+
+// validateOptions are the options of the start command checked by the validation.
+type validateOptions struct {
+	*clientOptions
+
+	subaccountIndex        *int
+	marketID               *string
+	granterPublicAddress   *string
+	granterSubaccountIndex *int
+	maxOrderAmount         *string
+	maxOrderNotional       *string
+	marketLimits           *string
+	pricingPolicy          *string
+	marketPricingPolicies  *string
+
+	granteeKeys                 *string
+	granteePrivKeys             *string
+	granteeSelection            *string
+	granteeMinBalance           *string
+	granteeBalanceCheckInterval *string
+
+	unwindPolicy      *string
+	unwindInterval    *string
+	unwindMaxSlippage *string
+
+	hedgeMarkets     *string
+	hedgeMaxSlippage *string
+}
+
+func configValidateCmd(cmd *cli.Cmd) {
+	o := &validateOptions{clientOptions: initClientOptions(cmd)}
+
+	initLiquidationOptions(
+		cmd,
+		&o.subaccountIndex,
+		&o.marketID,
+		&o.granterPublicAddress,
+		&o.granterSubaccountIndex,
+		&o.maxOrderAmount,
+		&o.maxOrderNotional,
+		&o.marketLimits,
+		&o.pricingPolicy,
+		&o.marketPricingPolicies,
+	)
+
+	initGranteeOptions(
+		cmd,
+		&o.granteeKeys,
+		&o.granteePrivKeys,
+		&o.granteeSelection,
+		&o.granteeMinBalance,
+		&o.granteeBalanceCheckInterval,
+	)
+
+	initUnwindOptions(
+		cmd,
+		&o.unwindPolicy,
+		&o.unwindInterval,
+		&o.unwindMaxSlippage,
+	)
+
+	initHedgeOptions(
+		cmd,
+		&o.hedgeMarkets,
+		&o.hedgeMaxSlippage,
+	)
+
+	cmd.Action = func() {
+		report := o.validate(context.Background())
+		_ = report.Print(os.Stdout)
+
+		if report.Failed() {
+			os.Exit(1)
+		}
+	}
+}
+
+// validate runs the checks, the ones depending on a failed check are skipped.
+func (o *validateOptions) validate(ctx context.Context) *validation.Report {
+	report := &validation.Report{}
+
+	var profiles map[string]config.Profile
+	if configFile != nil {
+		profiles = configFile.Markets
+		report.Pass("config file", fmt.Sprintf("%s with %d options and %d market profiles", *configFilePath, len(configFile.Global.Values()), len(profiles)))
+	}
+
+	// the signing keys are the Cosmos key and the grantee keys
+	var signers []types.AccAddress
+	senderAddress, _, err := chainclient.InitCosmosKeyring(
+		*o.cosmosKeyringDir,
+		*o.cosmosKeyringAppName,
+		*o.cosmosKeyringBackend,
+		*o.cosmosKeyFrom,
+		*o.cosmosKeyPassphrase,
+		*o.cosmosPrivKey,
+		*o.cosmosUseLedger,
+	)
+	if err != nil {
+		report.Fail("cosmos key", err)
+	} else {
+		report.Pass("cosmos key", senderAddress.String())
+		signers = append(signers, senderAddress)
+	}
+
+	resolveGrantee := func(name, keyFrom, privKey string) {
+		granteeAddress, _, err := chainclient.InitCosmosKeyring(
+			*o.cosmosKeyringDir,
+			*o.cosmosKeyringAppName,
+			*o.cosmosKeyringBackend,
+			keyFrom,
+			*o.cosmosKeyPassphrase,
+			privKey,
+			false,
+		)
+		if err != nil {
+			report.Fail(name, err)
+			return
+		}
+		report.Pass(name, granteeAddress.String())
+		signers = append(signers, granteeAddress)
+	}
+	for _, keyName := range splitList(*o.granteeKeys) {
+		resolveGrantee("grantee key "+keyName, keyName, "")
+	}
+	for i, privKey := range splitList(*o.granteePrivKeys) {
+		// the private keys are not printed
+		resolveGrantee(fmt.Sprintf("grantee private key #%d", i+1), "", privKey)
+	}
+
+	params, err := parseParameters(parameterOptions{
+		marketID:              *o.marketID,
+		maxOrderAmount:        *o.maxOrderAmount,
+		maxOrderNotional:      *o.maxOrderNotional,
+		marketLimits:          *o.marketLimits,
+		pricingPolicy:         *o.pricingPolicy,
+		marketPricingPolicies: *o.marketPricingPolicies,
+	}, profiles, nil)
+	paramsValid := err == nil
+	if !paramsValid {
+		report.Fail("liquidation parameters", err)
+	} else {
+		report.Pass("liquidation parameters", fmt.Sprintf("max order amount %s, max order notional %s, pricing policy %s",
+			service.FormatLimit(params.DefaultLimits.MaxOrderAmount), service.FormatLimit(params.DefaultLimits.MaxOrderNotional), params.DefaultPricing.String()))
+	}
+
+	unwindSettings, err := parseUnwindSettings(*o.unwindPolicy, *o.unwindMaxSlippage, duration(*o.unwindInterval, time.Minute))
+	if err != nil {
+		report.Fail("unwind options", err)
+	}
+	hedgeSettings, err := parseHedgeSettings(*o.hedgeMarkets, *o.hedgeMaxSlippage)
+	if err != nil {
+		report.Fail("hedge options", err)
+	}
+
+	granters := []string{*o.granterPublicAddress}
+	for _, profile := range profiles {
+		if profile.GranterPublicAddress != nil {
+			granters = append(granters, *profile.GranterPublicAddress)
+		}
+	}
+	sort.Strings(granters)
+	for i, granter := range granters {
+		if granter == "" || (i > 0 && granter == granters[i-1]) {
+			continue
+		}
+		if _, err := types.AccAddressFromBech32(granter); err != nil {
+			report.Fail("granter "+granter, errors.Wrap(err, "invalid granter public address"))
+		} else {
+			report.Pass("granter "+granter, "valid address")
+		}
+	}
+
+	network, err := o.createNetwork()
+	if err != nil {
+		report.Fail("network", err)
+		return report
+	}
+
+	exchangeClient, err := exchangeclient.NewExchangeClient(network)
+	if err == nil {
+		defer exchangeClient.Close()
+		err = o.waitForService(ctx, exchangeClient.QueryClient())
+	}
+	if err != nil {
+		report.Fail("exchange API", err)
+		return report
+	}
+	report.Pass("exchange API", network.ExchangeGrpcEndpoint)
+
+	chainClient, err := o.readOnlyChainClient(network)
+	if err == nil {
+		defer chainClient.Close()
+		err = o.waitForService(ctx, chainClient.QueryClient())
+	}
+	if err != nil {
+		report.Fail("chain gRPC", err)
+		return report
+	}
+	report.Pass("chain gRPC", network.ChainGrpcEndpoint)
+
+	marketsAssistant, err := chainclient.NewMarketsAssistantInitializedFromChain(ctx, exchangeClient)
+	if err != nil {
+		report.Fail("markets", err)
+		return report
+	}
+
+	if !paramsValid {
+		report.Skip("markets", "the liquidation parameters are invalid")
+		return report
+	}
+	accounts, err := groupMarketsByAccount(marketsAssistant, params.MarketIDs, profiles, liquidationAccount{
+		subaccountIndex:        *o.subaccountIndex,
+		granterPublicAddress:   *o.granterPublicAddress,
+		granterSubaccountIndex: *o.granterSubaccountIndex,
+	})
+	if err != nil {
+		report.Fail("markets", err)
+		return report
+	}
+
+	marketsByAccount := make([][]core.DerivativeMarket, len(accounts))
+	marketCount := 0
+	for i, account := range accounts {
+		marketsByAccount[i], err = service.ResolveMarkets(marketsAssistant, account.marketIDs)
+		if err != nil {
+			report.Fail("markets", err)
+			return report
+		}
+		marketCount += len(marketsByAccount[i])
+	}
+	report.Pass("markets", fmt.Sprintf("%d derivative markets liquidated from %d accounts", marketCount, len(accounts)))
+
+	for _, markets := range marketsByAccount {
+		o.checkLimits(report, params, markets)
+	}
+
+	msgTypes := service.RequiredMsgTypes(marketsAssistant, unwindSettings, hedgeSettings)
+	for i, account := range accounts {
+		o.checkGrants(ctx, report, chainClient, account, signers, msgTypes)
+		o.checkDeposits(ctx, report, chainClient, account, senderAddress, marketsByAccount[i])
+	}
+
+	return report
+}
+
+// checkLimits checks the effective sizing limits of the markets against their min quantity tick.
+func (o *validateOptions) checkLimits(report *validation.Report, params service.Parameters, markets []core.DerivativeMarket) {
+	for _, market := range markets {
+		limits, found := params.MarketLimits[market.Id]
+		if !found {
+			limits = params.DefaultLimits
+		}
+
+		name := "limits of " + market.Ticker
+		if limits.MaxOrderAmount.LT(math.LegacyMaxSortableDec) {
+			tick := math.LegacyMustNewDecFromStr(market.MinQuantityTickSize.String())
+			if err := validation.CheckOrderAmount(limits.MaxOrderAmount, tick); err != nil {
+				report.Fail(name, err)
+				continue
+			}
+		}
+		if limits.MaxOrderNotional.LT(math.LegacyMaxSortableDec) {
+			if err := validation.CheckOrderNotional(limits.MaxOrderNotional); err != nil {
+				report.Fail(name, err)
+				continue
+			}
+		}
+		report.Pass(name, fmt.Sprintf("max order amount %s, max order notional %s",
+			service.FormatLimit(limits.MaxOrderAmount), service.FormatLimit(limits.MaxOrderNotional)))
+	}
+}
+
+// checkGrants checks the grants of every signing key when the account has a granter.
+func (o *validateOptions) checkGrants(
+	ctx context.Context,
+	report *validation.Report,
+	chainClient chainclient.ChainClient,
+	account liquidationAccount,
+	signers []types.AccAddress,
+	msgTypes []string,
+) {
+	if account.granterPublicAddress == "" {
+		return
+	}
+	if _, err := types.AccAddressFromBech32(account.granterPublicAddress); err != nil {
+		report.Skip("grants from "+account.granterPublicAddress, "the granter address is invalid")
+		return
+	}
+	if len(signers) == 0 {
+		report.Skip("grants from "+account.granterPublicAddress, "no signing key resolved")
+		return
+	}
+
+	now := time.Now()
+	for _, signer := range signers {
+		name := fmt.Sprintf("grants of %s from %s", signer.String(), account.granterPublicAddress)
+
+		var missing []string
+		for _, msgType := range msgTypes {
+			grant, err := grants.Fetch(ctx, chainClient, account.granterPublicAddress, signer.String(), msgType)
+			if err != nil {
+				report.Fail(name, err)
+				return
+			}
+			if !grant.Valid(now) {
+				missing = append(missing, msgType)
+			}
+		}
+
+		if len(missing) > 0 {
+			report.Fail(name, errors.Errorf("missing or expired: %s", strings.Join(missing, ", ")))
+			continue
+		}
+		report.Pass(name, fmt.Sprintf("%d message types granted", len(msgTypes)))
+	}
+}
+
+// checkDeposits checks that the subaccount paying the liquidation orders of the account has available
+// deposits of the quote denom of its markets.
+func (o *validateOptions) checkDeposits(
+	ctx context.Context,
+	report *validation.Report,
+	chainClient chainclient.ChainClient,
+	account liquidationAccount,
+	senderAddress types.AccAddress,
+	markets []core.DerivativeMarket,
+) {
+	var subaccountID string
+	if account.granterPublicAddress != "" {
+		granterAddress, err := types.AccAddressFromBech32(account.granterPublicAddress)
+		if err != nil {
+			report.Skip("deposits of the granter "+account.granterPublicAddress, "the granter address is invalid")
+			return
+		}
+		subaccountID = chainClient.Subaccount(granterAddress, account.granterSubaccountIndex).Hex()
+	} else {
+		if senderAddress == nil {
+			report.Skip(fmt.Sprintf("deposits of subaccount %d", account.subaccountIndex), "the Cosmos key did not resolve")
+			return
+		}
+		subaccountID = chainClient.Subaccount(senderAddress, account.subaccountIndex).Hex()
+	}
+
+	name := "deposits of " + subaccountID
+	resp, err := chainClient.FetchSubaccountDeposits(ctx, subaccountID)
+	if err != nil {
+		report.Fail(name, errors.Wrap(err, "failed to fetch the subaccount deposits"))
+		return
+	}
+
+	var funded, missing []string
+	seen := make(map[string]bool)
+	for _, market := range markets {
+		denom := market.QuoteToken.Denom
+		if seen[denom] {
+			continue
+		}
+		seen[denom] = true
+
+		deposit, found := resp.Deposits[denom]
+		if !found || deposit == nil || deposit.AvailableBalance.IsNil() || !deposit.AvailableBalance.IsPositive() {
+			missing = append(missing, market.QuoteToken.Symbol)
+			continue
+		}
+		funded = append(funded, market.QuoteToken.Symbol)
+	}
+
+	if len(missing) > 0 {
+		report.Fail(name, errors.Errorf("no available balance of %s", strings.Join(missing, ", ")))
+		return
+	}
+	report.Pass(name, "available balance of "+strings.Join(funded, ", "))
+}
+
+// readOnlyChainClient connects a chain client without signing key.
+func (o *validateOptions) readOnlyChainClient(network common.Network) (chainclient.ChainClient, error) {
+	clientCtx, err := chainclient.NewClientContext(network.ChainId, "", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize cosmos client context")
+	}
+
+	tmClient, err := rpchttp.New(network.TmEndpoint, "/websocket")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to tendermint RPC")
+	}
+	clientCtx = clientCtx.WithNodeURI(network.TmEndpoint).WithClient(tmClient)
+
+	return chainclient.NewChainClient(clientCtx, network, common.OptionGasPrices(client.DefaultGasPriceWithDenom))
+}
+
+// waitForService waits for the connection to be ready, up to the service wait timeout.
+func (o *validateOptions) waitForService(ctx context.Context, conn *grpc.ClientConn) error {
+	timeout := duration(*svcWaitTimeout, time.Minute)
+	waitCtx, cancelWait := context.WithTimeout(ctx, timeout)
+	defer cancelWait()
+
+	if err := waitForService(waitCtx, conn); err != nil {
+		return errors.Errorf("%s not connected after %s", conn.Target(), timeout)
+	}
+	return nil
+}
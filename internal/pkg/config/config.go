@@ -0,0 +1,253 @@
+// Package config loads the configuration file: a global section holding the bot options, and per market
+// profiles overriding the liquidation settings of a market. The command line flags and the environment
+// variables override the file.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables of the bot options.
+const EnvPrefix = "LIQUIDATOR_"
+
+// Global holds the bot options of the global section, named after their environment variable without the
+// prefix: max_order_amount sets LIQUIDATOR_MAX_ORDER_AMOUNT. The unset options keep their default value.
+type Global struct {
+	Env                *string   `yaml:"env" toml:"env"`
+	LogLevel           *string   `yaml:"log_level" toml:"log_level"`
+	ServiceWaitTimeout *Duration `yaml:"service_wait_timeout" toml:"service_wait_timeout"`
+
+	NetworkName             *string `yaml:"network_name" toml:"network_name"`
+	ChainID                 *string `yaml:"chain_id" toml:"chain_id"`
+	LcdEndpoint             *string `yaml:"lcd_endpoint" toml:"lcd_endpoint"`
+	TendermintEndpoint      *string `yaml:"tendermint_endpoint" toml:"tendermint_endpoint"`
+	ChainGrpcEndpoint       *string `yaml:"chain_grpc_endpoint" toml:"chain_grpc_endpoint"`
+	ChainStreamGrpcEndpoint *string `yaml:"chain_stream_grpc_endpoint" toml:"chain_stream_grpc_endpoint"`
+	ExchangeGrpcEndpoint    *string `yaml:"exchange_grpc_endpoint" toml:"exchange_grpc_endpoint"`
+	ExplorerGrpcEndpoint    *string `yaml:"explorer_grpc_endpoint" toml:"explorer_grpc_endpoint"`
+
+	CosmosKeyring        *string `yaml:"cosmos_keyring" toml:"cosmos_keyring"`
+	CosmosKeyringDir     *string `yaml:"cosmos_keyring_dir" toml:"cosmos_keyring_dir"`
+	CosmosKeyringApp     *string `yaml:"cosmos_keyring_app" toml:"cosmos_keyring_app"`
+	CosmosFrom           *string `yaml:"cosmos_from" toml:"cosmos_from"`
+	CosmosFromPassphrase *string `yaml:"cosmos_from_passphrase" toml:"cosmos_from_passphrase"`
+	CosmosPk             *string `yaml:"cosmos_pk" toml:"cosmos_pk"`
+	CosmosUseLedger      *bool   `yaml:"cosmos_use_ledger" toml:"cosmos_use_ledger"`
+
+	StatsdAgent          *string   `yaml:"statsd_agent" toml:"statsd_agent"`
+	StatsdPrefix         *string   `yaml:"statsd_prefix" toml:"statsd_prefix"`
+	StatsdAddr           *string   `yaml:"statsd_addr" toml:"statsd_addr"`
+	StatsdStuckDur       *Duration `yaml:"statsd_stuck_dur" toml:"statsd_stuck_dur"`
+	StatsdMocking        *bool     `yaml:"statsd_mocking" toml:"statsd_mocking"`
+	StatsdDisabled       *bool     `yaml:"statsd_disabled" toml:"statsd_disabled"`
+	PrometheusListenAddr *string   `yaml:"prometheus_listen_addr" toml:"prometheus_listen_addr"`
+
+	SubaccountIndex        *int     `yaml:"subaccount_index" toml:"subaccount_index"`
+	MarketID               *string  `yaml:"market_id" toml:"market_id"`
+	GranterPublicAddress   *string  `yaml:"granter_public_address" toml:"granter_public_address"`
+	GranterSubaccountIndex *int     `yaml:"granter_subaccount_index" toml:"granter_subaccount_index"`
+	MaxOrderAmount         *Decimal `yaml:"max_order_amount" toml:"max_order_amount"`
+	MaxOrderNotional       *Decimal `yaml:"max_order_notional" toml:"max_order_notional"`
+	MarketLimits           *string  `yaml:"market_limits" toml:"market_limits"`
+	PricingPolicy          *string  `yaml:"pricing_policy" toml:"pricing_policy"`
+	MarketPricingPolicies  *string  `yaml:"market_pricing_policies" toml:"market_pricing_policies"`
+
+	DetectionMode    *string   `yaml:"detection_mode" toml:"detection_mode"`
+	PollInterval     *Duration `yaml:"poll_interval" toml:"poll_interval"`
+	StreamRetryDelay *Duration `yaml:"stream_retry_delay" toml:"stream_retry_delay"`
+
+	MinProfit        *Decimal `yaml:"min_profit" toml:"min_profit"`
+	EstimatedGas     *int     `yaml:"estimated_gas" toml:"estimated_gas"`
+	FeeTokenMarketID *string  `yaml:"fee_token_market_id" toml:"fee_token_market_id"`
+
+	BatchMaxMessages      *int      `yaml:"batch_max_messages" toml:"batch_max_messages"`
+	BatchMaxGas           *int      `yaml:"batch_max_gas" toml:"batch_max_gas"`
+	MaxInFlightTxs        *int      `yaml:"max_in_flight_txs" toml:"max_in_flight_txs"`
+	TxConfirmationTimeout *Duration `yaml:"tx_confirmation_timeout" toml:"tx_confirmation_timeout"`
+	DrainTimeout          *Duration `yaml:"drain_timeout" toml:"drain_timeout"`
+
+	Simulate                *bool    `yaml:"simulate" toml:"simulate"`
+	SimulationGasMultiplier *Decimal `yaml:"simulation_gas_multiplier" toml:"simulation_gas_multiplier"`
+	DryRun                  *bool    `yaml:"dry_run" toml:"dry_run"`
+	DryRunOutput            *string  `yaml:"dry_run_output" toml:"dry_run_output"`
+
+	MaxMarketExposure *Decimal `yaml:"max_market_exposure" toml:"max_market_exposure"`
+	MaxTotalExposure  *Decimal `yaml:"max_total_exposure" toml:"max_total_exposure"`
+
+	UnwindPolicy      *string   `yaml:"unwind_policy" toml:"unwind_policy"`
+	UnwindInterval    *Duration `yaml:"unwind_interval" toml:"unwind_interval"`
+	UnwindMaxSlippage *Decimal  `yaml:"unwind_max_slippage" toml:"unwind_max_slippage"`
+	HedgeMarkets      *string   `yaml:"hedge_markets" toml:"hedge_markets"`
+	HedgeMaxSlippage  *Decimal  `yaml:"hedge_max_slippage" toml:"hedge_max_slippage"`
+	ScoringWeights    *string   `yaml:"scoring_weights" toml:"scoring_weights"`
+
+	LiquidationCoolDown   *Duration `yaml:"liquidation_cool_down" toml:"liquidation_cool_down"`
+	PendingLiquidationTTL *Duration `yaml:"pending_liquidation_ttl" toml:"pending_liquidation_ttl"`
+
+	GranteeKeys                 *string   `yaml:"grantee_keys" toml:"grantee_keys"`
+	GranteePks                  *string   `yaml:"grantee_pks" toml:"grantee_pks"`
+	GranteeSelection            *string   `yaml:"grantee_selection" toml:"grantee_selection"`
+	GranteeMinBalance           *Decimal  `yaml:"grantee_min_balance" toml:"grantee_min_balance"`
+	GranteeBalanceCheckInterval *Duration `yaml:"grantee_balance_check_interval" toml:"grantee_balance_check_interval"`
+	GrantCheckInterval          *Duration `yaml:"grant_check_interval" toml:"grant_check_interval"`
+	GrantExpiryWarnings         *string   `yaml:"grant_expiry_warnings" toml:"grant_expiry_warnings"`
+
+	ConfigWatchInterval *Duration `yaml:"config_watch_interval" toml:"config_watch_interval"`
+	HealthListenAddr    *string   `yaml:"health_listen_addr" toml:"health_listen_addr"`
+	ReadyMaxCheckAge    *Duration `yaml:"ready_max_check_age" toml:"ready_max_check_age"`
+}
+
+// Duration is a duration option, written like 30s or 1h30m.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return errors.Errorf("invalid duration %s", text)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Decimal is a decimal number option.
+type Decimal struct {
+	decimal.Decimal
+}
+
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := decimal.NewFromString(strings.ReplaceAll(string(text), "_", ""))
+	if err != nil {
+		return errors.Errorf("invalid decimal %s", text)
+	}
+	d.Decimal = parsed
+	return nil
+}
+
+// Profile holds the liquidation settings a market profile overrides, the unset ones are the global ones.
+type Profile struct {
+	MaxOrderAmount         *string `yaml:"max_order_amount" toml:"max_order_amount"`
+	MaxOrderNotional       *string `yaml:"max_order_notional" toml:"max_order_notional"`
+	PricingPolicy          *string `yaml:"pricing_policy" toml:"pricing_policy"`
+	MinProfit              *string `yaml:"min_profit" toml:"min_profit"`
+	SubaccountIndex        *int    `yaml:"subaccount_index" toml:"subaccount_index"`
+	GranterPublicAddress   *string `yaml:"granter_public_address" toml:"granter_public_address"`
+	GranterSubaccountIndex *int    `yaml:"granter_subaccount_index" toml:"granter_subaccount_index"`
+}
+
+// File is the configuration file.
+type File struct {
+	Global Global `yaml:"global" toml:"global"`
+	// Markets holds the market profiles by market ID
+	Markets map[string]Profile `yaml:"markets" toml:"markets"`
+}
+
+// Load reads the configuration file, in YAML or TOML depending on its extension. Unknown fields are rejected.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the configuration file")
+	}
+
+	return Parse(data, filepath.Ext(path))
+}
+
+// Parse decodes the configuration, ext being the file extension of its format.
+func Parse(data []byte, ext string) (*File, error) {
+	file := &File{}
+
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, errors.Wrap(err, "failed to decode the YAML configuration")
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(file); err != nil {
+			return nil, errors.Wrap(err, "failed to decode the TOML configuration")
+		}
+	default:
+		return nil, errors.Errorf("unsupported configuration file extension %s (yaml, yml or toml)", ext)
+	}
+
+	if err := file.validate(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *File) validate() error {
+	for name, index := range map[string]*int{
+		"subaccount index":         f.Global.SubaccountIndex,
+		"granter subaccount index": f.Global.GranterSubaccountIndex,
+	} {
+		if index != nil && *index < 0 {
+			return errors.Errorf("invalid global %s %d", name, *index)
+		}
+	}
+
+	for marketID, profile := range f.Markets {
+		if marketID == "" {
+			return errors.New("market profile without market ID")
+		}
+		for name, value := range map[string]*string{
+			"max order amount":   profile.MaxOrderAmount,
+			"max order notional": profile.MaxOrderNotional,
+			"min profit":         profile.MinProfit,
+		} {
+			if value == nil {
+				continue
+			}
+			if _, err := decimal.NewFromString(*value); err != nil {
+				return errors.Errorf("invalid %s %s of market %s", name, *value, marketID)
+			}
+		}
+		if profile.SubaccountIndex != nil && *profile.SubaccountIndex < 0 {
+			return errors.Errorf("invalid subaccount index of market %s", marketID)
+		}
+		if profile.GranterSubaccountIndex != nil && *profile.GranterSubaccountIndex < 0 {
+			return errors.Errorf("invalid granter subaccount index of market %s", marketID)
+		}
+	}
+
+	return nil
+}
+
+// Key returns the global option of an environment variable.
+func Key(envVar string) string {
+	return strings.ToLower(strings.TrimPrefix(envVar, EnvPrefix))
+}
+
+// Values returns the options set in the global section by name, formatted like their command line value.
+func (g Global) Values() map[string]string {
+	values := make(map[string]string)
+
+	v := reflect.ValueOf(g)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.IsNil() {
+			continue
+		}
+		key := strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		values[key] = fmt.Sprint(field.Elem().Interface())
+	}
+	return values
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlConfig = `
+global:
+  market_id: all
+  max_order_notional: 1000000.5
+  batch_max_messages: 10
+  simulate: true
+  poll_interval: 90s
+markets:
+  "0x4ca0f92fc28be0c9761326016b5a1a2177dd6375558365116b5bdda9abc229ce":
+    max_order_amount: "2"
+    pricing_policy: mark_buffer:50
+    subaccount_index: 1
+`
+
+const tomlConfig = `
+[global]
+market_id = "all"
+max_order_notional = 1000000.5
+batch_max_messages = 10
+simulate = true
+poll_interval = "90s"
+
+[markets.0x4ca0f92fc28be0c9761326016b5a1a2177dd6375558365116b5bdda9abc229ce]
+max_order_amount = "2"
+pricing_policy = "mark_buffer:50"
+subaccount_index = 1
+`
+
+func TestParseYAMLAndTOML(t *testing.T) {
+	for ext, data := range map[string]string{".yaml": yamlConfig, ".toml": tomlConfig} {
+		file, err := Parse([]byte(data), ext)
+		assert.NoError(t, err, ext)
+
+		assert.Equal(t, "all", *file.Global.MarketID, ext)
+		assert.Equal(t, "1000000.5", file.Global.MaxOrderNotional.String(), ext)
+		assert.Equal(t, 10, *file.Global.BatchMaxMessages, ext)
+		assert.True(t, *file.Global.Simulate, ext)
+		assert.Equal(t, 90*time.Second, time.Duration(*file.Global.PollInterval), ext)
+		assert.Nil(t, file.Global.MinProfit, ext)
+
+		assert.Equal(t, map[string]string{
+			"market_id":          "all",
+			"max_order_notional": "1000000.5",
+			"batch_max_messages": "10",
+			"simulate":           "true",
+			"poll_interval":      "1m30s",
+		}, file.Global.Values(), ext)
+
+		profile := file.Markets["0x4ca0f92fc28be0c9761326016b5a1a2177dd6375558365116b5bdda9abc229ce"]
+		assert.Equal(t, "2", *profile.MaxOrderAmount, ext)
+		assert.Equal(t, "mark_buffer:50", *profile.PricingPolicy, ext)
+		assert.Equal(t, 1, *profile.SubaccountIndex, ext)
+		assert.Nil(t, profile.MinProfit, ext)
+		assert.Nil(t, profile.GranterPublicAddress, ext)
+	}
+}
+
+func TestParseRejectsInvalidConfigurations(t *testing.T) {
+	for _, data := range []string{
+		"global:\n  MAX_ORDER_AMOUNT: 1\n",
+		"global:\n  max_order_amout: 1\n",
+		"global:\n  market_id: [a, b]\n",
+		"global:\n  poll_interval: 10\n",
+		"global:\n  max_order_notional: 10k\n",
+		"global:\n  batch_max_messages: ten\n",
+		"global:\n  simulate: maybe\n",
+		"global:\n  subaccount_index: -1\n",
+		"markets:\n  btc:\n    max_amount: 1\n",
+		"markets:\n  btc:\n    subaccount_index: -1\n",
+		"markets:\n  btc:\n    max_order_notional: 10k\n",
+		"limits: {}\n",
+	} {
+		_, err := Parse([]byte(data), ".yaml")
+		assert.Error(t, err, data)
+	}
+
+	_, err := Parse([]byte(yamlConfig), ".json")
+	assert.Error(t, err)
+}
+
+func TestParseRejectsMistypedTOML(t *testing.T) {
+	for _, data := range []string{
+		"[global]\nmax_order_amout = \"1\"\n",
+		"[global]\npoll_interval = \"1 minute\"\n",
+		"[global]\nestimated_gas = \"high\"\n",
+	} {
+		_, err := Parse([]byte(data), ".toml")
+		assert.Error(t, err, data)
+	}
+}
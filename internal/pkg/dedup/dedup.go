@@ -0,0 +1,117 @@
+// Package dedup suppresses the liquidation attempts of a position while a previous attempt is pending,
+// and during a cool-down once it is resolved.
+package dedup
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the reason a liquidation attempt is suppressed.
+type State string
+
+const (
+	// StatePending is an attempt whose transaction outcome is not known yet
+	StatePending State = "pending"
+	// StateCoolDown is an attempt resolved less than the cool-down ago
+	StateCoolDown State = "cool_down"
+)
+
+// Key identifies the liquidated position.
+type Key struct {
+	MarketID     string
+	SubaccountID string
+	// Direction is the position direction (long or short)
+	Direction string
+}
+
+type attempt struct {
+	pending bool
+	// at is when the attempt began, or was resolved
+	at time.Time
+}
+
+// Cache holds the latest liquidation attempt of each position. It is safe for concurrent use.
+type Cache struct {
+	mux      sync.Mutex
+	coolDown time.Duration
+	// pendingTTL expires the attempts never resolved
+	pendingTTL time.Duration
+	attempts   map[Key]attempt
+	now        func() time.Time
+}
+
+func New(coolDown, pendingTTL time.Duration) *Cache {
+	return &Cache{
+		coolDown:   coolDown,
+		pendingTTL: pendingTTL,
+		attempts:   make(map[Key]attempt),
+		now:        time.Now,
+	}
+}
+
+// Check returns the state suppressing a new liquidation attempt of the position, if any.
+func (c *Cache) Check(key Key) (State, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	previous, found := c.attempts[key]
+	if !found {
+		return "", false
+	}
+	if c.expired(previous) {
+		delete(c.attempts, key)
+		return "", false
+	}
+	if previous.pending {
+		return StatePending, true
+	}
+	return StateCoolDown, true
+}
+
+// Begin records a pending liquidation attempt of the position.
+func (c *Cache) Begin(key Key) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.attempts[key] = attempt{pending: true, at: c.now()}
+}
+
+// Resolve ends the pending attempt of the position, which starts its cool-down.
+func (c *Cache) Resolve(key Key) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.coolDown <= 0 {
+		delete(c.attempts, key)
+		return
+	}
+	c.attempts[key] = attempt{at: c.now()}
+}
+
+// Prune removes the expired attempts.
+func (c *Cache) Prune() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for key, previous := range c.attempts {
+		if c.expired(previous) {
+			delete(c.attempts, key)
+		}
+	}
+}
+
+// Len returns the number of attempts suppressing new ones.
+func (c *Cache) Len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return len(c.attempts)
+}
+
+func (c *Cache) expired(previous attempt) bool {
+	if previous.pending {
+		return c.pendingTTL > 0 && c.now().Sub(previous.at) >= c.pendingTTL
+	}
+	return c.now().Sub(previous.at) >= c.coolDown
+}
@@ -0,0 +1,77 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestCache(coolDown, pendingTTL time.Duration) (*Cache, *testClock) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	cache := New(coolDown, pendingTTL)
+	cache.now = clock.Now
+	return cache, clock
+}
+
+func TestAttemptsAreSuppressedUntilResolvedAndCooledDown(t *testing.T) {
+	cache, clock := newTestCache(30*time.Second, 5*time.Minute)
+	key := Key{MarketID: "btc", SubaccountID: "a", Direction: "long"}
+
+	_, suppressed := cache.Check(key)
+	assert.False(t, suppressed)
+
+	cache.Begin(key)
+	clock.now = clock.now.Add(time.Minute)
+	state, suppressed := cache.Check(key)
+	assert.True(t, suppressed)
+	assert.Equal(t, StatePending, state)
+
+	// the other direction of the subaccount is a different position
+	_, suppressed = cache.Check(Key{MarketID: "btc", SubaccountID: "a", Direction: "short"})
+	assert.False(t, suppressed)
+
+	cache.Resolve(key)
+	clock.now = clock.now.Add(20 * time.Second)
+	state, suppressed = cache.Check(key)
+	assert.True(t, suppressed)
+	assert.Equal(t, StateCoolDown, state)
+
+	clock.now = clock.now.Add(10 * time.Second)
+	_, suppressed = cache.Check(key)
+	assert.False(t, suppressed)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestPendingAttemptsExpire(t *testing.T) {
+	cache, clock := newTestCache(30*time.Second, 5*time.Minute)
+	cache.Begin(Key{MarketID: "btc", SubaccountID: "a", Direction: "long"})
+	cache.Begin(Key{MarketID: "btc", SubaccountID: "b", Direction: "short"})
+
+	clock.now = clock.now.Add(4 * time.Minute)
+	cache.Prune()
+	assert.Equal(t, 2, cache.Len())
+
+	clock.now = clock.now.Add(time.Minute)
+	cache.Prune()
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestNoCoolDownOnceResolvedWhenDisabled(t *testing.T) {
+	cache, _ := newTestCache(0, 5*time.Minute)
+	key := Key{MarketID: "btc", SubaccountID: "a", Direction: "long"}
+
+	cache.Begin(key)
+	cache.Resolve(key)
+
+	_, suppressed := cache.Check(key)
+	assert.False(t, suppressed)
+}
@@ -0,0 +1,173 @@
+// Package eligibility recomputes locally, with the same formulas used by the chain exchange module,
+// the margin health of derivative positions and whether they can be liquidated.
+package eligibility
+
+import (
+	"cosmossdk.io/math"
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	"github.com/pkg/errors"
+)
+
+// Position is a derivative position with all values in chain format.
+type Position struct {
+	IsLong     bool
+	Quantity   math.LegacyDec
+	EntryPrice math.LegacyDec
+	Margin     math.LegacyDec
+	// CumulativeFundingEntry is only used for perpetual markets
+	CumulativeFundingEntry math.LegacyDec
+}
+
+// MarketState is the market information needed to evaluate a position, with all values in chain format.
+type MarketState struct {
+	MaintenanceMarginRatio math.LegacyDec
+	MarkPrice              math.LegacyDec
+	// CumulativeFunding is nil for expiry futures markets
+	CumulativeFunding *math.LegacyDec
+}
+
+// Result holds the margin health values of a position.
+type Result struct {
+	// EffectiveMargin is the funding adjusted margin plus the unrealized PnL at the mark price
+	EffectiveMargin math.LegacyDec
+	// MarginRatio is the effective margin divided by the position notional at the mark price
+	MarginRatio math.LegacyDec
+	// MaintenanceMargin is the margin required to keep the position open at the mark price
+	MaintenanceMargin math.LegacyDec
+	LiquidationPrice  math.LegacyDec
+	BankruptcyPrice   math.LegacyDec
+	// DistanceToLiquidation is the relative mark price move left before the position is liquidable.
+	// It is zero or negative for liquidable positions.
+	DistanceToLiquidation math.LegacyDec
+	IsLiquidable          bool
+}
+
+// MarginShortfall returns how far below the maintenance margin the position is (zero if it is above).
+func (r Result) MarginShortfall() math.LegacyDec {
+	shortfall := r.MaintenanceMargin.Sub(r.EffectiveMargin)
+	if shortfall.IsNegative() {
+		return math.LegacyZeroDec()
+	}
+	return shortfall
+}
+
+// Evaluate computes the margin health of the position in the market.
+func Evaluate(position Position, market MarketState) Result {
+	chainPosition := position.toChainPosition()
+
+	var funding *exchangetypes.PerpetualMarketFunding
+	if market.CumulativeFunding != nil {
+		funding = &exchangetypes.PerpetualMarketFunding{
+			CumulativeFunding: *market.CumulativeFunding,
+		}
+	}
+
+	liquidationPrice := chainPosition.GetLiquidationPrice(market.MaintenanceMarginRatio, funding)
+	effectiveMargin := chainPosition.GetEffectiveMargin(funding, market.MarkPrice)
+	notional := market.MarkPrice.Mul(position.Quantity)
+
+	// same condition the chain applies in the liquidation handler
+	isLiquidable := (position.IsLong && market.MarkPrice.LTE(liquidationPrice)) ||
+		(!position.IsLong && market.MarkPrice.GTE(liquidationPrice))
+
+	result := Result{
+		EffectiveMargin:       effectiveMargin,
+		MarginRatio:           math.LegacyZeroDec(),
+		MaintenanceMargin:     notional.Mul(market.MaintenanceMarginRatio),
+		LiquidationPrice:      liquidationPrice,
+		BankruptcyPrice:       chainPosition.GetBankruptcyPrice(funding),
+		DistanceToLiquidation: math.LegacyZeroDec(),
+		IsLiquidable:          isLiquidable,
+	}
+
+	if notional.IsPositive() {
+		result.MarginRatio = effectiveMargin.Quo(notional)
+	}
+
+	if market.MarkPrice.IsPositive() {
+		if position.IsLong {
+			result.DistanceToLiquidation = market.MarkPrice.Sub(liquidationPrice).Quo(market.MarkPrice)
+		} else {
+			result.DistanceToLiquidation = liquidationPrice.Sub(market.MarkPrice).Quo(market.MarkPrice)
+		}
+	}
+
+	return result
+}
+
+func (p Position) toChainPosition() *exchangetypes.Position {
+	chainPosition := &exchangetypes.Position{
+		IsLong:                 p.IsLong,
+		Quantity:               p.Quantity,
+		EntryPrice:             p.EntryPrice,
+		Margin:                 p.Margin,
+		CumulativeFundingEntry: p.CumulativeFundingEntry,
+	}
+	if chainPosition.CumulativeFundingEntry.IsNil() {
+		chainPosition.CumulativeFundingEntry = math.LegacyZeroDec()
+	}
+
+	return chainPosition
+}
+
+// PositionFromChain converts a chain position.
+func PositionFromChain(position *exchangetypes.Position) Position {
+	return Position{
+		IsLong:                 position.IsLong,
+		Quantity:               position.Quantity,
+		EntryPrice:             position.EntryPrice,
+		Margin:                 position.Margin,
+		CumulativeFundingEntry: position.CumulativeFundingEntry,
+	}
+}
+
+// PositionFromDerivativePosition converts an indexer position. The indexer does not provide the position
+// cumulative funding entry, so it has to be provided (use the market cumulative funding if unknown).
+func PositionFromDerivativePosition(position *derivativeExchangePB.DerivativePosition, cumulativeFundingEntry math.LegacyDec) (Position, error) {
+	quantity, err := math.LegacyNewDecFromStr(position.Quantity)
+	if err != nil {
+		return Position{}, errors.Wrapf(err, "invalid position quantity %s", position.Quantity)
+	}
+	entryPrice, err := math.LegacyNewDecFromStr(position.EntryPrice)
+	if err != nil {
+		return Position{}, errors.Wrapf(err, "invalid position entry price %s", position.EntryPrice)
+	}
+	margin, err := math.LegacyNewDecFromStr(position.Margin)
+	if err != nil {
+		return Position{}, errors.Wrapf(err, "invalid position margin %s", position.Margin)
+	}
+
+	return Position{
+		IsLong:                 position.Direction == "long",
+		Quantity:               quantity,
+		EntryPrice:             entryPrice,
+		Margin:                 margin,
+		CumulativeFundingEntry: cumulativeFundingEntry,
+	}, nil
+}
+
+// MarketStateFromChain builds the market state from the chain full derivative market information.
+func MarketStateFromChain(market *exchangetypes.FullDerivativeMarket) MarketState {
+	state := MarketState{
+		MaintenanceMarginRatio: market.Market.MaintenanceMarginRatio,
+		MarkPrice:              market.MarkPrice,
+	}
+	if perpetualInfo := market.GetPerpetualInfo(); perpetualInfo != nil && perpetualInfo.FundingInfo != nil {
+		cumulativeFunding := perpetualInfo.FundingInfo.CumulativeFunding
+		state.CumulativeFunding = &cumulativeFunding
+	}
+
+	return state
+}
+
+// MarketStateFromCore builds the market state from the markets assistant market, that does not include the
+// mark price and funding information.
+func MarketStateFromCore(market core.DerivativeMarket, markPrice math.LegacyDec, cumulativeFunding *math.LegacyDec) MarketState {
+	return MarketState{
+		MaintenanceMarginRatio: math.LegacyMustNewDecFromStr(market.MaintenanceMarginRatio.String()),
+		MarkPrice:              markPrice,
+		CumulativeFunding:      cumulativeFunding,
+	}
+}
@@ -0,0 +1,191 @@
+package eligibility
+
+import (
+	"testing"
+
+	"cosmossdk.io/math"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func dec(value string) math.LegacyDec {
+	return math.LegacyMustNewDecFromStr(value)
+}
+
+func decPtr(value string) *math.LegacyDec {
+	d := dec(value)
+	return &d
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		position Position
+		market   MarketState
+		expected Result
+	}{
+		{
+			name: "long below liquidation price in expiry market",
+			position: Position{
+				IsLong:     true,
+				Quantity:   dec("1"),
+				EntryPrice: dec("42000"),
+				Margin:     dec("3000"),
+			},
+			market: MarketState{
+				MaintenanceMarginRatio: dec("0.025"),
+				MarkPrice:              dec("39000"),
+			},
+			expected: Result{
+				EffectiveMargin:       dec("0"),
+				MarginRatio:           dec("0"),
+				MaintenanceMargin:     dec("975"),
+				LiquidationPrice:      dec("40000"),
+				BankruptcyPrice:       dec("39000"),
+				DistanceToLiquidation: dec("-0.025641025641025641"),
+				IsLiquidable:          true,
+			},
+		},
+		{
+			name: "long above liquidation price in expiry market",
+			position: Position{
+				IsLong:     true,
+				Quantity:   dec("1"),
+				EntryPrice: dec("42000"),
+				Margin:     dec("3000"),
+			},
+			market: MarketState{
+				MaintenanceMarginRatio: dec("0.025"),
+				MarkPrice:              dec("41000"),
+			},
+			expected: Result{
+				EffectiveMargin:       dec("2000"),
+				MarginRatio:           dec("0.048780487804878049"),
+				MaintenanceMargin:     dec("1025"),
+				LiquidationPrice:      dec("40000"),
+				BankruptcyPrice:       dec("39000"),
+				DistanceToLiquidation: dec("0.024390243902439024"),
+				IsLiquidable:          false,
+			},
+		},
+		{
+			name: "short exactly at liquidation price",
+			position: Position{
+				IsLong:     false,
+				Quantity:   dec("2"),
+				EntryPrice: dec("40000"),
+				Margin:     dec("2000"),
+			},
+			market: MarketState{
+				MaintenanceMarginRatio: dec("0.025"),
+				MarkPrice:              dec("40000"),
+			},
+			expected: Result{
+				EffectiveMargin:       dec("2000"),
+				MarginRatio:           dec("0.025"),
+				MaintenanceMargin:     dec("2000"),
+				LiquidationPrice:      dec("40000"),
+				BankruptcyPrice:       dec("41000"),
+				DistanceToLiquidation: dec("0"),
+				IsLiquidable:          true,
+			},
+		},
+		{
+			name: "perpetual long made liquidable by unrealized funding",
+			position: Position{
+				IsLong:                 true,
+				Quantity:               dec("2"),
+				EntryPrice:             dec("42000"),
+				Margin:                 dec("8000"),
+				CumulativeFundingEntry: dec("100"),
+			},
+			market: MarketState{
+				MaintenanceMarginRatio: dec("0.025"),
+				MarkPrice:              dec("39500"),
+				CumulativeFunding:      decPtr("1100"),
+			},
+			expected: Result{
+				EffectiveMargin:       dec("1000"),
+				MarginRatio:           dec("0.012658227848101266"),
+				MaintenanceMargin:     dec("1975"),
+				LiquidationPrice:      dec("40000"),
+				BankruptcyPrice:       dec("39000"),
+				DistanceToLiquidation: dec("-0.012658227848101266"),
+				IsLiquidable:          true,
+			},
+		},
+		{
+			name: "perpetual short receiving funding",
+			position: Position{
+				IsLong:                 false,
+				Quantity:               dec("1"),
+				EntryPrice:             dec("30000"),
+				Margin:                 dec("1275"),
+				CumulativeFundingEntry: dec("0"),
+			},
+			market: MarketState{
+				MaintenanceMarginRatio: dec("0.025"),
+				MarkPrice:              dec("30500"),
+				CumulativeFunding:      decPtr("500"),
+			},
+			expected: Result{
+				EffectiveMargin:       dec("1275"),
+				MarginRatio:           dec("0.041803278688524590"),
+				MaintenanceMargin:     dec("762.5"),
+				LiquidationPrice:      dec("31000"),
+				BankruptcyPrice:       dec("31775"),
+				DistanceToLiquidation: dec("0.016393442622950820"),
+				IsLiquidable:          false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Evaluate(tt.position, tt.market)
+
+			assert.Equal(t, tt.expected.EffectiveMargin.String(), result.EffectiveMargin.String(), "effective margin")
+			assert.Equal(t, tt.expected.MarginRatio.String(), result.MarginRatio.String(), "margin ratio")
+			assert.Equal(t, tt.expected.MaintenanceMargin.String(), result.MaintenanceMargin.String(), "maintenance margin")
+			assert.Equal(t, tt.expected.LiquidationPrice.String(), result.LiquidationPrice.String(), "liquidation price")
+			assert.Equal(t, tt.expected.BankruptcyPrice.String(), result.BankruptcyPrice.String(), "bankruptcy price")
+			assert.Equal(t, tt.expected.DistanceToLiquidation.String(), result.DistanceToLiquidation.String(), "distance to liquidation")
+			assert.Equal(t, tt.expected.IsLiquidable, result.IsLiquidable, "is liquidable")
+		})
+	}
+}
+
+func TestMarginShortfall(t *testing.T) {
+	result := Result{
+		EffectiveMargin:   dec("500"),
+		MaintenanceMargin: dec("975"),
+	}
+	assert.Equal(t, dec("475"), result.MarginShortfall())
+
+	result.EffectiveMargin = dec("1000")
+	assert.True(t, result.MarginShortfall().IsZero())
+}
+
+func TestPositionFromDerivativePosition(t *testing.T) {
+	position, err := PositionFromDerivativePosition(&derivativeExchangePB.DerivativePosition{
+		Direction:  "short",
+		Quantity:   "0.5",
+		EntryPrice: "30000000000",
+		Margin:     "1500000000",
+	}, dec("10"))
+
+	assert.NoError(t, err)
+	assert.False(t, position.IsLong)
+	assert.Equal(t, dec("0.5"), position.Quantity)
+	assert.Equal(t, dec("30000000000"), position.EntryPrice)
+	assert.Equal(t, dec("1500000000"), position.Margin)
+	assert.Equal(t, dec("10"), position.CumulativeFundingEntry)
+
+	_, err = PositionFromDerivativePosition(&derivativeExchangePB.DerivativePosition{
+		Direction:  "long",
+		Quantity:   "invalid",
+		EntryPrice: "1",
+		Margin:     "1",
+	}, math.LegacyZeroDec())
+	assert.Error(t, err)
+}
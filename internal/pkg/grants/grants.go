@@ -0,0 +1,199 @@
+// Package grants checks the authz grants the granter account gives to the grantee keys, and follows
+// their expiration.
+package grants
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos/gogoproto/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	authztypes "github.com/cosmos/cosmos-sdk/x/authz"
+)
+
+// Messages the grantee keys execute on behalf of the granter.
+const (
+	MsgLiquidatePosition           = "/injective.exchange.v1beta1.MsgLiquidatePosition"
+	MsgCreateDerivativeMarketOrder = "/injective.exchange.v1beta1.MsgCreateDerivativeMarketOrder"
+	MsgBatchUpdateOrders           = "/injective.exchange.v1beta1.MsgBatchUpdateOrders"
+	MsgCreateSpotMarketOrder       = "/injective.exchange.v1beta1.MsgCreateSpotMarketOrder"
+)
+
+// BotMsgTypes are all the message types the bot may execute for the granter, the order messages are
+// only needed to unwind or hedge the liquidated positions.
+var BotMsgTypes = []string{
+	MsgLiquidatePosition,
+	MsgCreateDerivativeMarketOrder,
+	MsgBatchUpdateOrders,
+	MsgCreateSpotMarketOrder,
+}
+
+// Querier queries the authz grants, implemented by the chain client.
+type Querier interface {
+	GetAuthzGrants(ctx context.Context, req authztypes.QueryGrantsRequest) (*authztypes.QueryGrantsResponse, error)
+}
+
+// Grant is the authorization of a grantee to execute a message type for the granter.
+type Grant struct {
+	Granter string
+	Grantee string
+	MsgType string
+	Found   bool
+	// Expiration is nil when the grant never expires
+	Expiration *time.Time
+}
+
+// Fetch returns the grant of the message type from the granter to the grantee. A missing grant is returned
+// with Found unset.
+func Fetch(ctx context.Context, querier Querier, granter, grantee, msgType string) (Grant, error) {
+	grant := Grant{Granter: granter, Grantee: grantee, MsgType: msgType}
+
+	resp, err := querier.GetAuthzGrants(ctx, authztypes.QueryGrantsRequest{
+		Granter:    granter,
+		Grantee:    grantee,
+		MsgTypeUrl: msgType,
+	})
+	if status.Code(err) == codes.NotFound {
+		return grant, nil
+	}
+	if err != nil {
+		return grant, errors.Wrapf(err, "failed to query the %s grant of %s", msgType, grantee)
+	}
+
+	for _, found := range resp.Grants {
+		if found == nil {
+			continue
+		}
+		// the latest expiration is kept when several grants authorize the message type
+		if grant.Found && (grant.Expiration == nil || (found.Expiration != nil && !found.Expiration.After(*grant.Expiration))) {
+			continue
+		}
+		grant.Found = true
+		grant.Expiration = found.Expiration
+	}
+
+	return grant, nil
+}
+
+// List returns all the grants from the granter to the grantee. The message type of the authorizations
+// other than the generic one is their own type.
+func List(ctx context.Context, querier Querier, granter, grantee string) ([]Grant, error) {
+	resp, err := querier.GetAuthzGrants(ctx, authztypes.QueryGrantsRequest{
+		Granter: granter,
+		Grantee: grantee,
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query the grants of %s", grantee)
+	}
+
+	list := make([]Grant, 0, len(resp.Grants))
+	for _, found := range resp.Grants {
+		if found == nil || found.Authorization == nil {
+			continue
+		}
+
+		msgType := found.Authorization.TypeUrl
+		var generic authztypes.GenericAuthorization
+		if msgType == "/"+proto.MessageName(&generic) {
+			if err := proto.Unmarshal(found.Authorization.Value, &generic); err != nil {
+				return nil, errors.Wrap(err, "failed to decode the generic authorization")
+			}
+			msgType = generic.Msg
+		}
+
+		list = append(list, Grant{
+			Granter:    granter,
+			Grantee:    grantee,
+			MsgType:    msgType,
+			Found:      true,
+			Expiration: found.Expiration,
+		})
+	}
+
+	return list, nil
+}
+
+// Valid reports whether the grant exists and is not expired at now.
+func (g Grant) Valid(now time.Time) bool {
+	return g.Found && (g.Expiration == nil || g.Expiration.After(now))
+}
+
+// TimeToExpiry returns the time left before the grant expires, false when it never expires.
+func (g Grant) TimeToExpiry(now time.Time) (time.Duration, bool) {
+	if g.Expiration == nil {
+		return 0, false
+	}
+	return g.Expiration.Sub(now), true
+}
+
+// ParseThresholds parses comma separated durations, returned from the longest to the shortest.
+func ParseThresholds(s string) ([]time.Duration, error) {
+	var thresholds []time.Duration
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		threshold, err := time.ParseDuration(entry)
+		if err != nil || threshold <= 0 {
+			return nil, errors.Errorf("invalid expiry warning threshold %s", entry)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+
+	sort.Slice(thresholds, func(i, j int) bool {
+		return thresholds[i] > thresholds[j]
+	})
+	return thresholds, nil
+}
+
+// Monitor warns once for each threshold a grant expiration gets closer than. It is safe for concurrent use.
+type Monitor struct {
+	mux        sync.Mutex
+	thresholds []time.Duration
+	// warned is the number of thresholds already warned by grant
+	warned map[string]int
+	now    func() time.Time
+}
+
+// NewMonitor returns a monitor warning at the thresholds, sorted from the longest to the shortest.
+func NewMonitor(thresholds []time.Duration) *Monitor {
+	return &Monitor{
+		thresholds: thresholds,
+		warned:     make(map[string]int),
+		now:        time.Now,
+	}
+}
+
+// Crossed returns the shortest threshold the grant expiration is now closer than, when it was not warned
+// yet. A renewed grant is warned again.
+func (m *Monitor) Crossed(grant Grant) (time.Duration, bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	key := grant.Grantee + "/" + grant.MsgType
+	left, expires := grant.TimeToExpiry(m.now())
+
+	crossed := 0
+	for _, threshold := range m.thresholds {
+		if expires && left <= threshold {
+			crossed++
+		}
+	}
+
+	warned := m.warned[key]
+	m.warned[key] = crossed
+	if crossed <= warned {
+		return 0, false
+	}
+	return m.thresholds[crossed-1], true
+}
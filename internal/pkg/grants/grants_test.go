@@ -0,0 +1,130 @@
+package grants
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	authztypes "github.com/cosmos/cosmos-sdk/x/authz"
+)
+
+type testQuerier struct {
+	grants map[string][]*authztypes.Grant
+	err    error
+}
+
+func (q testQuerier) GetAuthzGrants(_ context.Context, req authztypes.QueryGrantsRequest) (*authztypes.QueryGrantsResponse, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	grants, found := q.grants[req.Grantee+req.MsgTypeUrl]
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "no authorization found for %s type", req.MsgTypeUrl)
+	}
+	return &authztypes.QueryGrantsResponse{Grants: grants}, nil
+}
+
+func TestFetchGrant(t *testing.T) {
+	expiration := time.Unix(1700000000, 0)
+	later := expiration.Add(time.Hour)
+	querier := testQuerier{grants: map[string][]*authztypes.Grant{
+		"a" + MsgLiquidatePosition:     {{Expiration: &expiration}, {Expiration: &later}},
+		"a" + MsgBatchUpdateOrders:     {{}},
+		"b" + MsgCreateSpotMarketOrder: {},
+	}}
+
+	grant, err := Fetch(context.Background(), querier, "granter", "a", MsgLiquidatePosition)
+	assert.NoError(t, err)
+	assert.True(t, grant.Found)
+	assert.Equal(t, later, *grant.Expiration)
+	assert.True(t, grant.Valid(expiration))
+	assert.False(t, grant.Valid(later))
+
+	grant, err = Fetch(context.Background(), querier, "granter", "a", MsgBatchUpdateOrders)
+	assert.NoError(t, err)
+	assert.True(t, grant.Valid(later))
+	_, expires := grant.TimeToExpiry(later)
+	assert.False(t, expires)
+
+	for _, grantee := range []string{"a", "b"} {
+		grant, err = Fetch(context.Background(), querier, "granter", grantee, MsgCreateSpotMarketOrder)
+		assert.NoError(t, err)
+		assert.False(t, grant.Found)
+	}
+
+	_, err = Fetch(context.Background(), testQuerier{err: errors.New("unavailable")}, "granter", "a", MsgLiquidatePosition)
+	assert.Error(t, err)
+}
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := ParseThresholds("24h, 720h,168h")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{720 * time.Hour, 168 * time.Hour, 24 * time.Hour}, thresholds)
+
+	for _, spec := range []string{"7d", "-1h", "0s"} {
+		_, err = ParseThresholds(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestMonitorWarnsOnceForEachThreshold(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	monitor := NewMonitor([]time.Duration{720 * time.Hour, 24 * time.Hour})
+	monitor.now = func() time.Time { return now }
+
+	expiration := now.Add(1000 * time.Hour)
+	grant := Grant{Grantee: "a", MsgType: MsgLiquidatePosition, Found: true, Expiration: &expiration}
+	_, crossed := monitor.Crossed(grant)
+	assert.False(t, crossed)
+
+	now = expiration.Add(-700 * time.Hour)
+	threshold, crossed := monitor.Crossed(grant)
+	assert.True(t, crossed)
+	assert.Equal(t, 720*time.Hour, threshold)
+	_, crossed = monitor.Crossed(grant)
+	assert.False(t, crossed)
+
+	now = expiration.Add(-time.Hour)
+	threshold, crossed = monitor.Crossed(grant)
+	assert.True(t, crossed)
+	assert.Equal(t, 24*time.Hour, threshold)
+
+	// the renewed grant is warned again
+	renewed := now.Add(800 * time.Hour)
+	grant.Expiration = &renewed
+	_, crossed = monitor.Crossed(grant)
+	assert.False(t, crossed)
+	now = renewed.Add(-10 * time.Hour)
+	threshold, crossed = monitor.Crossed(grant)
+	assert.True(t, crossed)
+	assert.Equal(t, 24*time.Hour, threshold)
+}
+
+func TestListGrants(t *testing.T) {
+	generic, err := codectypes.NewAnyWithValue(authztypes.NewGenericAuthorization(MsgLiquidatePosition))
+	assert.NoError(t, err)
+	expiration := time.Unix(1700000000, 0)
+	querier := testQuerier{grants: map[string][]*authztypes.Grant{
+		"a": {{Authorization: generic, Expiration: &expiration}},
+	}}
+
+	list, err := List(context.Background(), querier, "granter", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []Grant{{
+		Granter:    "granter",
+		Grantee:    "a",
+		MsgType:    MsgLiquidatePosition,
+		Found:      true,
+		Expiration: &expiration,
+	}}, list)
+
+	list, err = List(context.Background(), querier, "granter", "b")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
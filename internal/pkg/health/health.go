@@ -0,0 +1,197 @@
+// Package health serves the liveness, readiness and status endpoints of the bot over HTTP.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/service"
+)
+
+// shutdownTimeout bounds the wait for the requests being served when the server closes.
+const shutdownTimeout = 5 * time.Second
+
+// Conn is a gRPC connection whose state decides the readiness, like a *grpc.ClientConn.
+type Conn interface {
+	GetState() connectivity.State
+	Connect()
+}
+
+// Connected reports whether the connection is ready to serve requests. An idle connection, one without
+// requests for a while, is reachable too: it is asked to reconnect and the next request waits for it.
+func Connected(conn Conn) bool {
+	switch conn.GetState() {
+	case connectivity.Ready:
+		return true
+	case connectivity.Idle:
+		conn.Connect()
+		return true
+	default:
+		return false
+	}
+}
+
+// NamedConn is a connection checked for readiness, named in the reports.
+type NamedConn struct {
+	Name string
+	Conn Conn
+}
+
+// StatusSource reports the state of a liquidation service.
+type StatusSource interface {
+	Status() service.Status
+}
+
+// Server serves /healthz (the process is alive), /readyz (the bot is connected and checking its markets)
+// and /status (the state of the liquidation services, in JSON).
+type Server struct {
+	// maxCheckAge is the maximum time since the last complete check of the markets of a ready service
+	maxCheckAge time.Duration
+	conns       []NamedConn
+	services    []StatusSource
+
+	server *http.Server
+	logger log.Logger
+}
+
+// NewServer returns a server listening on listenAddr once started.
+func NewServer(listenAddr string, maxCheckAge time.Duration, conns []NamedConn, services []StatusSource) *Server {
+	s := &Server{
+		maxCheckAge: maxCheckAge,
+		conns:       conns,
+		services:    services,
+
+		logger: log.WithField("svc", "health"),
+	}
+	s.server = &http.Server{
+		Addr:              listenAddr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Handler returns the handler of the endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/readyz", s.serveReadiness)
+	mux.HandleFunc("/status", s.serveStatus)
+	return mux
+}
+
+// Start listens on the server address and serves the requests in the background.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", s.server.Addr)
+	}
+
+	s.logger.Infof("Serving the health endpoints on %s", listener.Addr().String())
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.WithError(err).Errorln("Health server stopped")
+		}
+	}()
+	return nil
+}
+
+// Close stops the server, waiting for the requests being served.
+func (s *Server) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.WithError(err).Warningln("Failed to stop the health server")
+	}
+}
+
+// Readiness is the readiness report, listing what keeps the bot from being ready.
+type Readiness struct {
+	Ready    bool     `json:"ready"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// StatusReport is the state of the connections and of the liquidation services.
+type StatusReport struct {
+	Connections map[string]string `json:"connections"`
+	Services    []service.Status  `json:"services"`
+}
+
+// readiness checks the connections and every service: their markets are loaded, their grants are valid
+// and they completed a check of their markets within maxCheckAge.
+func (s *Server) readiness(now time.Time) Readiness {
+	var failures []string
+	for _, conn := range s.conns {
+		if !Connected(conn.Conn) {
+			failures = append(failures, fmt.Sprintf("%s connection is %s", conn.Name, conn.Conn.GetState()))
+		}
+	}
+
+	for _, svc := range s.services {
+		status := svc.Status()
+		account := "subaccount " + status.SubaccountID
+		switch {
+		case len(status.Markets) == 0:
+			failures = append(failures, "markets of "+account+" not loaded")
+		case status.LastCheck.IsZero():
+			failures = append(failures, "no complete check of the markets of "+account+" yet")
+		case now.Sub(status.LastCheck) > s.maxCheckAge:
+			failures = append(failures, fmt.Sprintf("last complete check of the markets of %s is %s old",
+				account, now.Sub(status.LastCheck).Truncate(time.Second)))
+		}
+		if len(status.Markets) > 0 && !status.GrantsValid {
+			failures = append(failures, "grants of "+status.Granter+" to the keys of "+account+" missing or expired")
+		}
+	}
+
+	return Readiness{Ready: len(failures) == 0, Failures: failures}
+}
+
+func (s *Server) status() StatusReport {
+	report := StatusReport{
+		Connections: make(map[string]string, len(s.conns)),
+		Services:    make([]service.Status, 0, len(s.services)),
+	}
+	for _, conn := range s.conns {
+		report.Connections[conn.Name] = conn.Conn.GetState().String()
+	}
+	for _, svc := range s.services {
+		report.Services = append(report.Services, svc.Status())
+	}
+	return report
+}
+
+func (s *Server) serveHealth(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (s *Server) serveReadiness(w http.ResponseWriter, _ *http.Request) {
+	readiness := s.readiness(time.Now())
+	code := http.StatusOK
+	if !readiness.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, code, readiness)
+}
+
+func (s *Server) serveStatus(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, http.StatusOK, s.status())
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		s.logger.WithError(err).Warningln("Failed to write the response")
+	}
+}
@@ -0,0 +1,114 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/service"
+)
+
+type mockConn struct {
+	state    connectivity.State
+	connects int
+}
+
+func (c *mockConn) GetState() connectivity.State {
+	return c.state
+}
+
+func (c *mockConn) Connect() {
+	c.connects++
+}
+
+type mockService struct {
+	status service.Status
+}
+
+func (s *mockService) Status() service.Status {
+	return s.status
+}
+
+func TestReadinessChecksTheConnectionsAndTheServices(t *testing.T) {
+	now := time.Now()
+	chainConn := &mockConn{state: connectivity.Ready}
+	exchangeConn := &mockConn{state: connectivity.Ready}
+	svc := &mockService{status: service.Status{
+		SubaccountID: "0x01",
+		Granter:      "inj1granter",
+		Markets:      []service.MarketStatus{{ID: "0xmarket"}},
+		GrantsValid:  true,
+		LastCheck:    now.Add(-10 * time.Second),
+	}}
+	server := NewServer(":0", time.Minute, []NamedConn{
+		{Name: "chain gRPC", Conn: chainConn},
+		{Name: "exchange gRPC", Conn: exchangeConn},
+	}, []StatusSource{svc})
+
+	assert.Equal(t, Readiness{Ready: true}, server.readiness(now))
+
+	exchangeConn.state = connectivity.TransientFailure
+	svc.status.GrantsValid = false
+	svc.status.LastCheck = now.Add(-2 * time.Minute)
+	assert.Equal(t, Readiness{Failures: []string{
+		"exchange gRPC connection is TRANSIENT_FAILURE",
+		"last complete check of the markets of subaccount 0x01 is 2m0s old",
+		"grants of inj1granter to the keys of subaccount 0x01 missing or expired",
+	}}, server.readiness(now))
+
+	svc.status = service.Status{SubaccountID: "0x01"}
+	exchangeConn.state = connectivity.Ready
+	assert.Equal(t, Readiness{Failures: []string{
+		"markets of subaccount 0x01 not loaded",
+	}}, server.readiness(now))
+}
+
+func TestIdleConnectionsAreReachable(t *testing.T) {
+	conn := &mockConn{state: connectivity.Idle}
+	assert.True(t, Connected(conn))
+	assert.Equal(t, 1, conn.connects)
+
+	server := NewServer(":0", time.Minute, []NamedConn{{Name: "chain gRPC", Conn: conn}}, nil)
+	assert.Equal(t, Readiness{Ready: true}, server.readiness(time.Now()))
+	assert.Equal(t, 2, conn.connects)
+
+	conn.state = connectivity.Connecting
+	assert.False(t, Connected(conn))
+	assert.Equal(t, 2, conn.connects)
+}
+
+func TestEndpoints(t *testing.T) {
+	svc := &mockService{status: service.Status{SubaccountID: "0x01"}}
+	server := NewServer(":0", time.Minute, []NamedConn{
+		{Name: "chain gRPC", Conn: &mockConn{state: connectivity.Ready}},
+	}, []StatusSource{svc})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/healthz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/readyz").Code)
+
+	svc.status.Markets = []service.MarketStatus{{ID: "0xmarket", MaxOrderNotional: "none"}}
+	svc.status.GrantsValid = true
+	svc.status.LastCheck = time.Now()
+	assert.Equal(t, http.StatusOK, serve("/readyz").Code)
+
+	response := serve("/status")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+
+	var report StatusReport
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &report))
+	assert.Equal(t, map[string]string{"chain gRPC": "READY"}, report.Connections)
+	assert.Len(t, report.Services, 1)
+	assert.Equal(t, "none", report.Services[0].Markets[0].MaxOrderNotional)
+}
@@ -0,0 +1,202 @@
+// Package inventory tracks the net positions acquired by the liquidator through the liquidations
+// and enforces the exposure limits.
+package inventory
+
+import (
+	"sync"
+
+	"cosmossdk.io/math"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrMarketExposureExceeded = errors.New("market exposure limit exceeded")
+	ErrTotalExposureExceeded  = errors.New("total exposure limit exceeded")
+)
+
+// Position is the net position held in a market, in human readable units.
+type Position struct {
+	// Quantity is positive for long positions and negative for short positions
+	Quantity math.LegacyDec
+	// Price is the latest known price of the market, used to value the position
+	Price math.LegacyDec
+}
+
+// Exposure returns the absolute notional of the position.
+func (p Position) Exposure() math.LegacyDec {
+	if p.Quantity.IsNil() || p.Price.IsNil() {
+		return math.LegacyZeroDec()
+	}
+	return p.Quantity.Abs().Mul(p.Price)
+}
+
+// Limits are the maximum net exposures (notional in the quote asset) of the bot, summed over the subaccounts
+// it trades from. A zero or nil limit is not enforced.
+type Limits struct {
+	MaxMarketExposure math.LegacyDec
+	MaxTotalExposure  math.LegacyDec
+}
+
+// Inventory holds the net positions by subaccount ID and market ID. It is shared by the services of the
+// subaccounts, so the limits apply to their positions together. It is safe for concurrent use.
+type Inventory struct {
+	mux       sync.RWMutex
+	limits    Limits
+	positions map[string]map[string]Position
+}
+
+func New(limits Limits) *Inventory {
+	return &Inventory{
+		limits:    limits,
+		positions: make(map[string]map[string]Position),
+	}
+}
+
+// Limits returns the exposure limits.
+func (i *Inventory) Limits() Limits {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+
+	return i.limits
+}
+
+// SetLimits replaces the exposure limits, the positions beyond them are only prevented from growing.
+func (i *Inventory) SetLimits(limits Limits) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	i.limits = limits
+}
+
+// Sync replaces the positions of the subaccount with the ones held on chain.
+func (i *Inventory) Sync(subaccountID string, positions map[string]Position) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	synced := make(map[string]Position, len(positions))
+	for marketID, position := range positions {
+		synced[marketID] = position
+	}
+	i.positions[subaccountID] = synced
+}
+
+// Copy returns an independent inventory with the same limits and positions.
+func (i *Inventory) Copy() *Inventory {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+
+	copied := New(i.limits)
+	for subaccountID, positions := range i.positions {
+		copied.positions[subaccountID] = make(map[string]Position, len(positions))
+		for marketID, position := range positions {
+			copied.positions[subaccountID][marketID] = position
+		}
+	}
+	return copied
+}
+
+// Position returns the net position of the subaccount in the market.
+func (i *Inventory) Position(subaccountID, marketID string) Position {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+
+	return i.position(subaccountID, marketID)
+}
+
+// Positions returns the net positions of the subaccount by market ID.
+func (i *Inventory) Positions(subaccountID string) map[string]Position {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+
+	positions := make(map[string]Position, len(i.positions[subaccountID]))
+	for marketID, position := range i.positions[subaccountID] {
+		positions[marketID] = position
+	}
+	return positions
+}
+
+// TotalExposure returns the sum of the exposures of every subaccount and market.
+func (i *Inventory) TotalExposure() math.LegacyDec {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+
+	return i.totalExposure()
+}
+
+// Check returns an error if adding quantity (negative when selling) at price to the position of the subaccount
+// in the market grows the exposure beyond the limits. Trades reducing the exposure are always allowed.
+func (i *Inventory) Check(subaccountID, marketID string, quantity, price math.LegacyDec) error {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+
+	current := i.position(subaccountID, marketID)
+	current.Price = price
+	updated := Position{Quantity: current.Quantity.Add(quantity), Price: price}
+
+	if updated.Exposure().LTE(current.Exposure()) {
+		return nil
+	}
+
+	// the position of the subaccount is valued at price, the ones of the other subaccounts at their own price
+	others := i.marketExposure(marketID).Sub(i.position(subaccountID, marketID).Exposure())
+	if isLimited(i.limits.MaxMarketExposure) {
+		marketExposure := others.Add(updated.Exposure())
+		if marketExposure.GT(i.limits.MaxMarketExposure) {
+			return errors.Wrapf(ErrMarketExposureExceeded, "exposure would be %s (limit %s)", marketExposure.String(), i.limits.MaxMarketExposure.String())
+		}
+	}
+
+	if isLimited(i.limits.MaxTotalExposure) {
+		total := i.totalExposure().Sub(i.position(subaccountID, marketID).Exposure()).Add(updated.Exposure())
+		if total.GT(i.limits.MaxTotalExposure) {
+			return errors.Wrapf(ErrTotalExposureExceeded, "total exposure would be %s (limit %s)", total.String(), i.limits.MaxTotalExposure.String())
+		}
+	}
+
+	return nil
+}
+
+// Apply adds quantity (negative when selling) traded at price to the position of the subaccount in the market.
+func (i *Inventory) Apply(subaccountID, marketID string, quantity, price math.LegacyDec) Position {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	position := i.position(subaccountID, marketID)
+	position.Quantity = position.Quantity.Add(quantity)
+	position.Price = price
+	if i.positions[subaccountID] == nil {
+		i.positions[subaccountID] = make(map[string]Position)
+	}
+	i.positions[subaccountID][marketID] = position
+
+	return position
+}
+
+func (i *Inventory) position(subaccountID, marketID string) Position {
+	if position, found := i.positions[subaccountID][marketID]; found {
+		return position
+	}
+	return Position{Quantity: math.LegacyZeroDec(), Price: math.LegacyZeroDec()}
+}
+
+func (i *Inventory) marketExposure(marketID string) math.LegacyDec {
+	total := math.LegacyZeroDec()
+	for _, positions := range i.positions {
+		total = total.Add(positions[marketID].Exposure())
+	}
+	return total
+}
+
+func (i *Inventory) totalExposure() math.LegacyDec {
+	total := math.LegacyZeroDec()
+	for _, positions := range i.positions {
+		for _, position := range positions {
+			total = total.Add(position.Exposure())
+		}
+	}
+	return total
+}
+
+func isLimited(limit math.LegacyDec) bool {
+	return !limit.IsNil() && limit.IsPositive()
+}
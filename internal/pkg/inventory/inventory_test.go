@@ -0,0 +1,71 @@
+package inventory
+
+import (
+	"testing"
+
+	"cosmossdk.io/math"
+	"github.com/stretchr/testify/assert"
+)
+
+func dec(value string) math.LegacyDec {
+	return math.LegacyMustNewDecFromStr(value)
+}
+
+func TestCheckEnforcesTheMarketExposureLimit(t *testing.T) {
+	inventory := New(Limits{MaxMarketExposure: dec("50000")})
+	inventory.Apply("0x01", "btc", dec("1"), dec("30000"))
+
+	// 1.5 BTC at 30000 is 45000
+	assert.NoError(t, inventory.Check("0x01", "btc", dec("0.5"), dec("30000")))
+	// 2 BTC at 30000 is 60000
+	assert.ErrorIs(t, inventory.Check("0x01", "btc", dec("1"), dec("30000")), ErrMarketExposureExceeded)
+	// reducing or flipping to a smaller position is allowed
+	assert.NoError(t, inventory.Check("0x01", "btc", dec("-1.5"), dec("30000")))
+	// other markets have their own limit
+	assert.NoError(t, inventory.Check("0x01", "eth", dec("-20"), dec("2000")))
+}
+
+func TestCheckEnforcesTheTotalExposureLimit(t *testing.T) {
+	inventory := New(Limits{MaxTotalExposure: dec("100000")})
+	inventory.Sync("0x01", map[string]Position{
+		"btc": {Quantity: dec("2"), Price: dec("30000")},
+		"eth": {Quantity: dec("-10"), Price: dec("2000")},
+	})
+
+	assert.Equal(t, "80000.000000000000000000", inventory.TotalExposure().String())
+	assert.NoError(t, inventory.Check("0x01", "eth", dec("-10"), dec("2000")))
+	assert.ErrorIs(t, inventory.Check("0x01", "eth", dec("-11"), dec("2000")), ErrTotalExposureExceeded)
+	// exposure reductions are allowed even beyond the limit
+	assert.NoError(t, inventory.Check("0x01", "btc", dec("-1"), dec("30000")))
+}
+
+func TestCopyIsIndependent(t *testing.T) {
+	inventory := New(Limits{})
+	inventory.Apply("0x01", "btc", dec("1"), dec("30000"))
+
+	copied := inventory.Copy()
+	copied.Apply("0x01", "btc", dec("-3"), dec("31000"))
+
+	assert.Equal(t, "1.000000000000000000", inventory.Position("0x01", "btc").Quantity.String())
+	assert.Equal(t, "-2.000000000000000000", copied.Position("0x01", "btc").Quantity.String())
+	assert.Equal(t, "62000.000000000000000000", copied.Position("0x01", "btc").Exposure().String())
+}
+
+func TestLimitsApplyToEverySubaccount(t *testing.T) {
+	inventory := New(Limits{MaxMarketExposure: dec("50000"), MaxTotalExposure: dec("100000")})
+	inventory.Sync("0x01", map[string]Position{
+		"btc": {Quantity: dec("1"), Price: dec("30000")},
+	})
+	inventory.Apply("0x02", "eth", dec("-20"), dec("2000"))
+
+	// 1 BTC is held by the other subaccount, 2 BTC at 30000 is 60000
+	assert.ErrorIs(t, inventory.Check("0x02", "btc", dec("1"), dec("30000")), ErrMarketExposureExceeded)
+	assert.NoError(t, inventory.Check("0x02", "btc", dec("0.5"), dec("30000")))
+	// 70000 already held, 10 SOL at 4000 is 40000 more
+	assert.ErrorIs(t, inventory.Check("0x01", "sol", dec("10"), dec("4000")), ErrTotalExposureExceeded)
+
+	// a sync only replaces the positions of its subaccount
+	inventory.Sync("0x01", map[string]Position{})
+	assert.Equal(t, "-20.000000000000000000", inventory.Position("0x02", "eth").Quantity.String())
+	assert.Equal(t, "40000.000000000000000000", inventory.TotalExposure().String())
+}
@@ -0,0 +1,186 @@
+// Package pricing defines the policies used to choose the price of the liquidation orders.
+package pricing
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"cosmossdk.io/math"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	"github.com/pkg/errors"
+)
+
+const (
+	PolicyMark       = "mark"
+	PolicyMarkBuffer = "mark_buffer"
+	PolicyBankruptcy = "bankruptcy"
+	PolicyOrderbook  = "orderbook"
+)
+
+var bpsDenominator = math.LegacyNewDec(10000)
+
+// Request holds the information of the liquidated position needed to price the liquidation order.
+// All prices are in chain format.
+type Request struct {
+	MarketID string
+	// IsBuy is the direction of the liquidation order (buy when liquidating a long position)
+	IsBuy           bool
+	MarkPrice       math.LegacyDec
+	BankruptcyPrice math.LegacyDec
+}
+
+// Policy chooses the price of a liquidation order.
+type Policy interface {
+	Price(ctx context.Context, req Request) (math.LegacyDec, error)
+	// String returns the policy specification, as accepted by ParsePolicy
+	String() string
+}
+
+// OrderbookSource provides the derivative markets orderbooks (implemented by the exchange client).
+type OrderbookSource interface {
+	GetDerivativeOrderbookV2(ctx context.Context, marketId string) (*derivativeExchangePB.OrderbookV2Response, error)
+}
+
+// ParsePolicy creates the policy from its specification, with the format name[:bps].
+// The orderbook source is only used by the orderbook policy.
+func ParsePolicy(spec string, orderbooks OrderbookSource) (Policy, error) {
+	name, param, hasParam := strings.Cut(strings.TrimSpace(spec), ":")
+
+	var bps math.LegacyDec
+	if hasParam {
+		value, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid bps value in pricing policy %s", spec)
+		}
+		bps = math.LegacyNewDec(value)
+	} else {
+		bps = math.LegacyZeroDec()
+	}
+
+	switch name {
+	case PolicyMark:
+		if hasParam {
+			return nil, errors.Errorf("pricing policy %s does not accept parameters", name)
+		}
+		return MarkPolicy{}, nil
+	case PolicyMarkBuffer:
+		if !hasParam {
+			return nil, errors.Errorf("pricing policy %s requires the bps buffer (%s:<bps>)", name, name)
+		}
+		return MarkBufferPolicy{BufferBps: bps}, nil
+	case PolicyBankruptcy:
+		return BankruptcyPolicy{BufferBps: bps}, nil
+	case PolicyOrderbook:
+		if hasParam {
+			return nil, errors.Errorf("pricing policy %s does not accept parameters", name)
+		}
+		if orderbooks == nil {
+			return nil, errors.Errorf("pricing policy %s requires an orderbook source", name)
+		}
+		return OrderbookPolicy{Orderbooks: orderbooks}, nil
+	default:
+		return nil, errors.Errorf("unknown pricing policy %s", spec)
+	}
+}
+
+// MarkPolicy prices the order at the mark price.
+type MarkPolicy struct{}
+
+func (MarkPolicy) Price(_ context.Context, req Request) (math.LegacyDec, error) {
+	return req.MarkPrice, nil
+}
+
+func (MarkPolicy) String() string {
+	return PolicyMark
+}
+
+// MarkBufferPolicy prices the order at the mark price improved by the buffer in our favor
+// (below the mark for buys, above it for sells). A negative buffer prices the order past the mark.
+type MarkBufferPolicy struct {
+	BufferBps math.LegacyDec
+}
+
+func (p MarkBufferPolicy) Price(_ context.Context, req Request) (math.LegacyDec, error) {
+	return applyBps(req.MarkPrice, p.BufferBps, !req.IsBuy), nil
+}
+
+func (p MarkBufferPolicy) String() string {
+	return PolicyMarkBuffer + ":" + p.BufferBps.TruncateInt().String()
+}
+
+// BankruptcyPolicy prices the order at the worst price the chain uses for the liquidation market order
+// (the bankruptcy price, or the mark price if the position has negative equity), moved by the buffer
+// towards the mark price to absorb rounding and price changes.
+type BankruptcyPolicy struct {
+	BufferBps math.LegacyDec
+}
+
+func (p BankruptcyPolicy) Price(_ context.Context, req Request) (math.LegacyDec, error) {
+	if req.BankruptcyPrice.IsNil() || !req.BankruptcyPrice.IsPositive() {
+		return math.LegacyDec{}, errors.New("bankruptcy price not available")
+	}
+
+	worstPrice := req.BankruptcyPrice
+	// a buy order takes over a long position, that has negative equity when the mark is below the bankruptcy price
+	hasNegativeEquity := (req.IsBuy && req.MarkPrice.LT(req.BankruptcyPrice)) || (!req.IsBuy && req.MarkPrice.GT(req.BankruptcyPrice))
+	if hasNegativeEquity {
+		worstPrice = req.MarkPrice
+	}
+
+	return applyBps(worstPrice, p.BufferBps, req.IsBuy), nil
+}
+
+func (p BankruptcyPolicy) String() string {
+	return PolicyBankruptcy + ":" + p.BufferBps.TruncateInt().String()
+}
+
+// OrderbookPolicy prices the order at the best level of the opposite side of the market orderbook
+// (best sell for buys, best buy for sells), falling back to the mark price when that side is empty.
+type OrderbookPolicy struct {
+	Orderbooks OrderbookSource
+}
+
+func (p OrderbookPolicy) Price(ctx context.Context, req Request) (math.LegacyDec, error) {
+	resp, err := p.Orderbooks.GetDerivativeOrderbookV2(ctx, req.MarketID)
+	if err != nil {
+		return math.LegacyDec{}, errors.Wrapf(err, "failed to get the orderbook of market %s", req.MarketID)
+	}
+
+	levels := resp.GetOrderbook().GetBuys()
+	if req.IsBuy {
+		levels = resp.GetOrderbook().GetSells()
+	}
+
+	bestPrice := math.LegacyDec{}
+	for _, level := range levels {
+		price, err := math.LegacyNewDecFromStr(level.Price)
+		if err != nil {
+			return math.LegacyDec{}, errors.Wrapf(err, "invalid orderbook price %s", level.Price)
+		}
+
+		isBetter := bestPrice.IsNil() || (req.IsBuy && price.LT(bestPrice)) || (!req.IsBuy && price.GT(bestPrice))
+		if isBetter {
+			bestPrice = price
+		}
+	}
+
+	if bestPrice.IsNil() {
+		return req.MarkPrice, nil
+	}
+
+	return bestPrice, nil
+}
+
+func (OrderbookPolicy) String() string {
+	return PolicyOrderbook
+}
+
+// applyBps moves the price up (or down) by the bps amount.
+func applyBps(price, bps math.LegacyDec, up bool) math.LegacyDec {
+	delta := price.Mul(bps).Quo(bpsDenominator)
+	if up {
+		return price.Add(delta)
+	}
+	return price.Sub(delta)
+}
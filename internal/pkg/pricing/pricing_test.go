@@ -0,0 +1,123 @@
+package pricing
+
+import (
+	"context"
+	"testing"
+
+	"cosmossdk.io/math"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticOrderbooks struct {
+	orderbook *derivativeExchangePB.DerivativeLimitOrderbookV2
+}
+
+func (o staticOrderbooks) GetDerivativeOrderbookV2(_ context.Context, _ string) (*derivativeExchangePB.OrderbookV2Response, error) {
+	return &derivativeExchangePB.OrderbookV2Response{Orderbook: o.orderbook}, nil
+}
+
+func TestPolicies(t *testing.T) {
+	orderbooks := staticOrderbooks{
+		orderbook: &derivativeExchangePB.DerivativeLimitOrderbookV2{
+			Buys: []*derivativeExchangePB.PriceLevel{
+				{Price: "29900", Quantity: "1"},
+				{Price: "29950", Quantity: "1"},
+			},
+			Sells: []*derivativeExchangePB.PriceLevel{
+				{Price: "30100", Quantity: "1"},
+				{Price: "30050", Quantity: "1"},
+			},
+		},
+	}
+
+	tests := []struct {
+		spec     string
+		req      Request
+		expected string
+	}{
+		{
+			spec:     "mark",
+			req:      Request{IsBuy: true, MarkPrice: math.LegacyNewDec(30000)},
+			expected: "30000",
+		},
+		{
+			spec:     "mark_buffer:50",
+			req:      Request{IsBuy: true, MarkPrice: math.LegacyNewDec(30000)},
+			expected: "29850",
+		},
+		{
+			spec:     "mark_buffer:50",
+			req:      Request{IsBuy: false, MarkPrice: math.LegacyNewDec(30000)},
+			expected: "30150",
+		},
+		{
+			spec:     "mark_buffer:-10",
+			req:      Request{IsBuy: true, MarkPrice: math.LegacyNewDec(30000)},
+			expected: "30030",
+		},
+		{
+			spec:     "bankruptcy:100",
+			req:      Request{IsBuy: true, MarkPrice: math.LegacyNewDec(30000), BankruptcyPrice: math.LegacyNewDec(29000)},
+			expected: "29290",
+		},
+		{
+			spec:     "bankruptcy",
+			req:      Request{IsBuy: false, MarkPrice: math.LegacyNewDec(30000), BankruptcyPrice: math.LegacyNewDec(31000)},
+			expected: "31000",
+		},
+		{
+			spec:     "bankruptcy:100",
+			req:      Request{IsBuy: true, MarkPrice: math.LegacyNewDec(28000), BankruptcyPrice: math.LegacyNewDec(29000)},
+			expected: "28280",
+		},
+		{
+			spec:     "orderbook",
+			req:      Request{IsBuy: true, MarkPrice: math.LegacyNewDec(30000)},
+			expected: "30050",
+		},
+		{
+			spec:     "orderbook",
+			req:      Request{IsBuy: false, MarkPrice: math.LegacyNewDec(30000)},
+			expected: "29950",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			policy, err := ParsePolicy(tt.spec, orderbooks)
+			assert.NoError(t, err)
+
+			price, err := policy.Price(context.Background(), tt.req)
+			assert.NoError(t, err)
+			assert.Equal(t, math.LegacyMustNewDecFromStr(tt.expected).String(), price.String())
+		})
+	}
+}
+
+func TestOrderbookPolicyFallsBackToMarkPrice(t *testing.T) {
+	policy := OrderbookPolicy{Orderbooks: staticOrderbooks{orderbook: &derivativeExchangePB.DerivativeLimitOrderbookV2{}}}
+
+	price, err := policy.Price(context.Background(), Request{IsBuy: true, MarkPrice: math.LegacyNewDec(30000)})
+
+	assert.NoError(t, err)
+	assert.Equal(t, math.LegacyNewDec(30000), price)
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for _, spec := range []string{"unknown", "mark:10", "mark_buffer", "mark_buffer:abc", "orderbook:5"} {
+		_, err := ParsePolicy(spec, staticOrderbooks{})
+		assert.Error(t, err, spec)
+	}
+
+	_, err := ParsePolicy("orderbook", nil)
+	assert.Error(t, err)
+}
+
+func TestPolicyString(t *testing.T) {
+	for _, spec := range []string{"mark", "mark_buffer:25", "bankruptcy:0", "orderbook"} {
+		policy, err := ParsePolicy(spec, staticOrderbooks{})
+		assert.NoError(t, err)
+		assert.Equal(t, spec, policy.String())
+	}
+}
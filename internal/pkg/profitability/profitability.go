@@ -0,0 +1,77 @@
+// Package profitability estimates the expected PnL of a liquidation before it is broadcast.
+package profitability
+
+import (
+	"cosmossdk.io/math"
+)
+
+// Inputs holds the values needed to estimate a liquidation PnL. All amounts are in chain format
+// and denominated in the market quote asset.
+type Inputs struct {
+	// IsBuy is the direction of the liquidation order (buy when liquidating a long position)
+	IsBuy      bool
+	Quantity   math.LegacyDec
+	OrderPrice math.LegacyDec
+	MarkPrice  math.LegacyDec
+	// PositionPayout is the liquidated position effective margin, that is split between the
+	// liquidator and the insurance fund when positive
+	PositionPayout            math.LegacyDec
+	LiquidatorRewardShareRate math.LegacyDec
+	// TradingFeeRate is the fee rate paid by the liquidation order, with the fee discounts applied
+	TradingFeeRate math.LegacyDec
+	GasCost        math.LegacyDec
+}
+
+// Estimate is the expected result of a liquidation.
+type Estimate struct {
+	// Reward is the liquidator share of the liquidated position payout
+	Reward math.LegacyDec
+	// FillPnl is the value of the acquired position at the mark price compared with the order price
+	FillPnl    math.LegacyDec
+	TradingFee math.LegacyDec
+	GasCost    math.LegacyDec
+	Profit     math.LegacyDec
+}
+
+// EstimatePnl computes the expected PnL of the liquidation.
+func EstimatePnl(in Inputs) Estimate {
+	reward := math.LegacyZeroDec()
+	if in.PositionPayout.IsPositive() {
+		reward = in.PositionPayout.Mul(in.LiquidatorRewardShareRate)
+	}
+
+	// buying below the mark (or selling above it) is an immediate gain on the acquired position
+	fillPnl := in.MarkPrice.Sub(in.OrderPrice).Mul(in.Quantity)
+	if !in.IsBuy {
+		fillPnl = fillPnl.Neg()
+	}
+
+	tradingFee := in.OrderPrice.Mul(in.Quantity).Mul(in.TradingFeeRate)
+	profit := reward.Add(fillPnl).Sub(tradingFee).Sub(in.GasCost)
+
+	return Estimate{
+		Reward:     reward,
+		FillPnl:    fillPnl,
+		TradingFee: tradingFee,
+		GasCost:    in.GasCost,
+		Profit:     profit,
+	}
+}
+
+// DiscountedFeeRate applies the fee tier discount to the market fee rate.
+func DiscountedFeeRate(feeRate, discountRate math.LegacyDec) math.LegacyDec {
+	if discountRate.IsNil() {
+		return feeRate
+	}
+	return feeRate.Mul(math.LegacyOneDec().Sub(discountRate))
+}
+
+// GasCostInQuote converts the gas used by a transaction to the quote asset, in chain format.
+// gasPrice is in the fee token base units, feeTokenPrice is the price of one fee token in quote
+// and the decimals are the ones of both tokens.
+func GasCostInQuote(gas uint64, gasPrice math.LegacyDec, feeTokenPrice math.LegacyDec, feeTokenDecimals, quoteDecimals int64) math.LegacyDec {
+	feeInBaseUnits := gasPrice.MulInt64(int64(gas))
+	feeTokens := feeInBaseUnits.Quo(math.LegacyNewDec(10).Power(uint64(feeTokenDecimals)))
+
+	return feeTokens.Mul(feeTokenPrice).Mul(math.LegacyNewDec(10).Power(uint64(quoteDecimals)))
+}
@@ -0,0 +1,105 @@
+package profitability
+
+import (
+	"testing"
+
+	"cosmossdk.io/math"
+	"github.com/stretchr/testify/assert"
+)
+
+func dec(value string) math.LegacyDec {
+	return math.LegacyMustNewDecFromStr(value)
+}
+
+func TestEstimatePnl(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       Inputs
+		expected Estimate
+	}{
+		{
+			name: "buy below mark with positive payout",
+			in: Inputs{
+				IsBuy:                     true,
+				Quantity:                  dec("2"),
+				OrderPrice:                dec("29900"),
+				MarkPrice:                 dec("30000"),
+				PositionPayout:            dec("400"),
+				LiquidatorRewardShareRate: dec("0.5"),
+				TradingFeeRate:            dec("0.001"),
+				GasCost:                   dec("5"),
+			},
+			expected: Estimate{
+				Reward:     dec("200"),
+				FillPnl:    dec("200"),
+				TradingFee: dec("59.8"),
+				GasCost:    dec("5"),
+				Profit:     dec("335.2"),
+			},
+		},
+		{
+			name: "sell at mark with negative payout",
+			in: Inputs{
+				IsBuy:                     false,
+				Quantity:                  dec("1"),
+				OrderPrice:                dec("30000"),
+				MarkPrice:                 dec("30000"),
+				PositionPayout:            dec("-100"),
+				LiquidatorRewardShareRate: dec("0.5"),
+				TradingFeeRate:            dec("0.0005"),
+				GasCost:                   dec("2"),
+			},
+			expected: Estimate{
+				Reward:     dec("0"),
+				FillPnl:    dec("0"),
+				TradingFee: dec("15"),
+				GasCost:    dec("2"),
+				Profit:     dec("-17"),
+			},
+		},
+		{
+			name: "sell below mark loses on the fill",
+			in: Inputs{
+				IsBuy:                     false,
+				Quantity:                  dec("1"),
+				OrderPrice:                dec("29000"),
+				MarkPrice:                 dec("30000"),
+				PositionPayout:            dec("3000"),
+				LiquidatorRewardShareRate: dec("0.5"),
+				TradingFeeRate:            dec("0"),
+				GasCost:                   dec("0"),
+			},
+			expected: Estimate{
+				Reward:     dec("1500"),
+				FillPnl:    dec("-1000"),
+				TradingFee: dec("0"),
+				GasCost:    dec("0"),
+				Profit:     dec("500"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := EstimatePnl(tt.in)
+
+			assert.Equal(t, tt.expected.Reward.String(), estimate.Reward.String(), "reward")
+			assert.Equal(t, tt.expected.FillPnl.String(), estimate.FillPnl.String(), "fill pnl")
+			assert.Equal(t, tt.expected.TradingFee.String(), estimate.TradingFee.String(), "trading fee")
+			assert.Equal(t, tt.expected.GasCost.String(), estimate.GasCost.String(), "gas cost")
+			assert.Equal(t, tt.expected.Profit.String(), estimate.Profit.String(), "profit")
+		})
+	}
+}
+
+func TestDiscountedFeeRate(t *testing.T) {
+	assert.Equal(t, dec("0.0008").String(), DiscountedFeeRate(dec("0.001"), dec("0.2")).String())
+	assert.Equal(t, dec("0.001").String(), DiscountedFeeRate(dec("0.001"), math.LegacyDec{}).String())
+}
+
+func TestGasCostInQuote(t *testing.T) {
+	// 400000 gas at 160000000inj with INJ at 25 USDT (6 decimals): 0.000064 INJ = 0.0016 USDT
+	cost := GasCostInQuote(400000, dec("160000000"), dec("25"), 18, 6)
+
+	assert.Equal(t, dec("1600").String(), cost.String())
+}
@@ -0,0 +1,116 @@
+// Package scoring ranks the liquidation candidates, so the most valuable liquidations are processed first.
+package scoring
+
+import (
+	"strings"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	CriterionProfit    = "profit"
+	CriterionNotional  = "notional"
+	CriterionShortfall = "shortfall"
+	CriterionAge       = "age"
+)
+
+// Weights are the factors applied to each criterion. The score is the weighted sum of the criteria.
+type Weights struct {
+	Profit    math.LegacyDec
+	Notional  math.LegacyDec
+	Shortfall math.LegacyDec
+	// Age is applied to the seconds the position has been in the liquidable set
+	Age math.LegacyDec
+}
+
+// DefaultWeights ranks the candidates by expected profit only.
+func DefaultWeights() Weights {
+	return Weights{
+		Profit:    math.LegacyOneDec(),
+		Notional:  math.LegacyZeroDec(),
+		Shortfall: math.LegacyZeroDec(),
+		Age:       math.LegacyZeroDec(),
+	}
+}
+
+// ParseWeights parses comma separated criterion=weight entries. The criteria not included weigh zero,
+// and an empty specification returns the default weights.
+func ParseWeights(spec string) (Weights, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultWeights(), nil
+	}
+
+	weights := Weights{
+		Profit:    math.LegacyZeroDec(),
+		Notional:  math.LegacyZeroDec(),
+		Shortfall: math.LegacyZeroDec(),
+		Age:       math.LegacyZeroDec(),
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		criterion, value, found := strings.Cut(entry, "=")
+		if !found {
+			return weights, errors.Errorf("invalid scoring weight entry %s", entry)
+		}
+		weight, err := math.LegacyNewDecFromStr(strings.TrimSpace(value))
+		if err != nil {
+			return weights, errors.Wrapf(err, "invalid weight in scoring entry %s", entry)
+		}
+
+		switch strings.TrimSpace(criterion) {
+		case CriterionProfit:
+			weights.Profit = weight
+		case CriterionNotional:
+			weights.Notional = weight
+		case CriterionShortfall:
+			weights.Shortfall = weight
+		case CriterionAge:
+			weights.Age = weight
+		default:
+			return weights, errors.Errorf("unknown scoring criterion %s", criterion)
+		}
+	}
+
+	return weights, nil
+}
+
+// String returns the weights specification, as accepted by ParseWeights.
+func (w Weights) String() string {
+	return strings.Join([]string{
+		CriterionProfit + "=" + formatDec(w.Profit),
+		CriterionNotional + "=" + formatDec(w.Notional),
+		CriterionShortfall + "=" + formatDec(w.Shortfall),
+		CriterionAge + "=" + formatDec(w.Age),
+	}, ",")
+}
+
+// Inputs are the criteria of a liquidation candidate. Amounts are human readable, in the market quote asset.
+type Inputs struct {
+	Profit          math.LegacyDec
+	Notional        math.LegacyDec
+	MarginShortfall math.LegacyDec
+	// Age is the time since the position was first seen liquidable
+	Age time.Duration
+}
+
+// Score returns the weighted sum of the candidate criteria.
+func (w Weights) Score(in Inputs) math.LegacyDec {
+	ageSeconds := math.LegacyNewDec(int64(in.Age / time.Millisecond)).QuoInt64(1000)
+
+	return w.Profit.Mul(in.Profit).
+		Add(w.Notional.Mul(in.Notional)).
+		Add(w.Shortfall.Mul(in.MarginShortfall)).
+		Add(w.Age.Mul(ageSeconds))
+}
+
+func formatDec(value math.LegacyDec) string {
+	return decimal.RequireFromString(value.String()).String()
+}
@@ -0,0 +1,39 @@
+package scoring
+
+import (
+	"testing"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWeights(t *testing.T) {
+	weights, err := ParseWeights("")
+	assert.NoError(t, err)
+	assert.Equal(t, "profit=1,notional=0,shortfall=0,age=0", weights.String())
+
+	weights, err = ParseWeights("profit=1, notional=0.001,age=0.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "profit=1,notional=0.001,shortfall=0,age=0.5", weights.String())
+
+	for _, spec := range []string{"profit", "profit=abc", "size=1"} {
+		_, err = ParseWeights(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestScoreIsTheWeightedSumOfTheCriteria(t *testing.T) {
+	weights, err := ParseWeights("profit=1,notional=0.001,shortfall=2,age=0.5")
+	assert.NoError(t, err)
+
+	score := weights.Score(Inputs{
+		Profit:          math.LegacyMustNewDecFromStr("45"),
+		Notional:        math.LegacyMustNewDecFromStr("15000"),
+		MarginShortfall: math.LegacyMustNewDecFromStr("10"),
+		Age:             30 * time.Second,
+	})
+
+	// 45 + 15 + 20 + 15
+	assert.Equal(t, math.LegacyMustNewDecFromStr("95"), score)
+}
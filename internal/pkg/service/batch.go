@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/metrics"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	"github.com/cosmos/gogoproto/proto"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/telemetry"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/txtracker"
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+)
+
+// BatchSettings configures how the liquidations found in one detection round are grouped into transactions.
+type BatchSettings struct {
+	// MaxMessages is the maximum number of liquidations included in one transaction
+	MaxMessages int
+	// MaxGas is the maximum estimated gas of one transaction (no limit when zero)
+	MaxGas uint64
+	// GasPerMessage is the gas expected to be used by each liquidation
+	GasPerMessage uint64
+	// MaxInFlight is the maximum number of transactions awaiting their confirmation
+	MaxInFlight int
+}
+
+// pendingLiquidation is a liquidation ready to be broadcast.
+type pendingLiquidation struct {
+	position *derivativeExchangePB.DerivativePosition
+	market   core.DerivativeMarket
+	msg      exchangetypes.MsgLiquidatePosition
+	// gasLimit is the gas limit computed from the liquidation simulation (zero when not simulated)
+	gasLimit uint64
+	// collateral is the quote balance (in chain format) used by the liquidation order
+	collateral math.LegacyDec
+	// expectedProfit is the profit estimate (in chain format) of the liquidation
+	expectedProfit math.LegacyDec
+	// detectedAt is when the service received the liquidable position from the detector
+	detectedAt time.Time
+}
+
+// key identifies the liquidated position.
+func (l pendingLiquidation) key() string {
+	return l.msg.MarketId + "/" + l.msg.SubaccountId
+}
+
+// estimatedGas returns the gas expected to be used by the liquidation.
+func (s *liquidatorSvc) estimatedGas(liquidation pendingLiquidation) uint64 {
+	if liquidation.gasLimit > 0 {
+		return liquidation.gasLimit
+	}
+	return s.batchSettings.GasPerMessage
+}
+
+// runSummary counts the liquidations processed by the service, reported when it stops.
+type runSummary struct {
+	transactions atomic.Int64
+	liquidated   atomic.Int64
+	failed       atomic.Int64
+	abandoned    atomic.Int64
+	unconfirmed  atomic.Int64
+	inFlight     atomic.Int64
+	dryRun       atomic.Int64
+}
+
+func (r *runSummary) fields() log.Fields {
+	return log.Fields{
+		"transactions": r.transactions.Load(),
+		"liquidated":   r.liquidated.Load(),
+		"failed":       r.failed.Load(),
+		"abandoned":    r.abandoned.Load(),
+		"unconfirmed":  r.unconfirmed.Load(),
+		"in_flight":    r.inFlight.Load(),
+		"dry_run":      r.dryRun.Load(),
+	}
+}
+
+// broadcastLiquidations sends the liquidations grouped in as few transactions as the batch limits allow.
+// The transactions are broadcast in order before returning, up to MaxInFlight of them awaiting their
+// confirmation, and their outcome is handled in the background. The batches not sent yet when ctx is
+// cancelled are abandoned.
+func (s *liquidatorSvc) broadcastLiquidations(ctx context.Context, liquidations []pendingLiquidation) {
+	s.pipeline.track(liquidations)
+	for _, liquidation := range liquidations {
+		telemetry.LiquidationAttempted(liquidation.market.Id)
+	}
+	for _, batch := range s.splitInBatches(liquidations) {
+		s.broadcastBatch(ctx, batch)
+	}
+}
+
+// splitInBatches groups the liquidations respecting the max messages and max gas per transaction.
+func (s *liquidatorSvc) splitInBatches(liquidations []pendingLiquidation) [][]pendingLiquidation {
+	maxMessages := s.batchSettings.MaxMessages
+	if maxMessages < 1 {
+		maxMessages = 1
+	}
+
+	var batches [][]pendingLiquidation
+	var batch []pendingLiquidation
+	var batchGas uint64
+
+	for _, liquidation := range liquidations {
+		liquidationGas := s.estimatedGas(liquidation)
+		exceedsGas := s.batchSettings.MaxGas > 0 && batchGas+liquidationGas > s.batchSettings.MaxGas
+		if len(batch) > 0 && (len(batch) >= maxMessages || exceedsGas) {
+			batches = append(batches, batch)
+			batch = nil
+			batchGas = 0
+		}
+
+		batch = append(batch, liquidation)
+		batchGas += liquidationGas
+	}
+
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+// broadcastBatch broadcasts the batch in one transaction, and waits for its confirmation in the background.
+func (s *liquidatorSvc) broadcastBatch(ctx context.Context, batch []pendingLiquidation) {
+	if ctx.Err() != nil || !s.pipeline.acquire(ctx, s.batchSettings.MaxInFlight) {
+		s.summary.abandoned.Add(int64(len(batch)))
+		s.pipeline.untrack(batch)
+		s.resolveAttempts(batch...)
+		s.logger.Warningf("Abandoning %d liquidations on shutdown", len(batch))
+		return
+	}
+
+	s.summary.transactions.Add(1)
+	s.summary.inFlight.Add(int64(len(batch)))
+	tx, result := s.sendBatch(batch)
+
+	s.pipeline.running.Add(1)
+	go func() {
+		defer s.pipeline.running.Done()
+
+		if tx != nil {
+			result = s.waitTx(ctx, tx)
+		}
+		s.pipeline.release()
+		s.summary.inFlight.Add(-int64(len(batch)))
+
+		s.handleBatchResult(ctx, batch, result)
+	}()
+}
+
+// handleBatchResult reports the batch outcome. When the transaction fails because of one liquidation, that
+// liquidation is dropped and the rest of the batch is sent again. If the failed liquidation is unknown the
+// batch is split in two halves that are retried separately, so a single failing liquidation does not block
+// the others.
+func (s *liquidatorSvc) handleBatchResult(ctx context.Context, batch []pendingLiquidation, result txtracker.Result) {
+	switch result.Outcome {
+	case txtracker.OutcomeSuccess:
+		s.reportOutcome(batch, result)
+		s.hedgeLiquidations(ctx, batch)
+		return
+	case txtracker.OutcomeTimeout:
+		// the transaction state is unknown, it is not retried to avoid sending the liquidations twice
+		s.reportOutcome(batch, result)
+		return
+	case txtracker.OutcomeAuthzMissing:
+		// every liquidation of the batch fails the same way
+		s.reportOutcome(batch, result)
+		return
+	}
+
+	if len(batch) == 1 {
+		s.reportOutcome(batch, result)
+		return
+	}
+
+	failedIndex := result.MessageIndex
+	if result.Outcome != txtracker.OutcomeOutOfGas && failedIndex >= 0 && failedIndex < len(batch) {
+		s.reportOutcome(batch[failedIndex:failedIndex+1], result)
+
+		remaining := make([]pendingLiquidation, 0, len(batch)-1)
+		remaining = append(remaining, batch[:failedIndex]...)
+		remaining = append(remaining, batch[failedIndex+1:]...)
+		s.broadcastBatch(ctx, remaining)
+		return
+	}
+
+	s.logger.WithField("outcome", result.Outcome).Warningf("Failed broadcasting a batch of %d liquidations, retrying them in two halves", len(batch))
+
+	middle := len(batch) / 2
+	s.broadcastBatch(ctx, batch[:middle])
+	s.broadcastBatch(ctx, batch[middle:])
+}
+
+// sendBatch broadcasts the batch transaction. It returns the transaction accepted in the mempool, or the
+// failure when the transaction is rejected before.
+func (s *liquidatorSvc) sendBatch(batch []pendingLiquidation) (*sentTx, txtracker.Result) {
+	var gasLimit uint64
+	for _, liquidation := range batch {
+		gasLimit += liquidation.gasLimit
+	}
+
+	return s.broadcast(gasLimit, liquidationMessages(batch)...)
+}
+
+// broadcast sends the messages in one transaction, signed by the next key of the pool, without waiting for its
+// inclusion. When using a granter account each message is wrapped in an authz execution by that key. It returns
+// the transaction accepted in the mempool, or the failure when the transaction is rejected before. The gas limit
+// is estimated by simulation when zero.
+func (s *liquidatorSvc) broadcast(gasLimit uint64, msgs ...sdktypes.Msg) (*sentTx, txtracker.Result) {
+	sg, err := s.pickSigner()
+	if err != nil {
+		metrics.ReportClosureFuncError("PickSigner", s.svcTags)
+		return nil, txtracker.ResultFromError(err)
+	}
+
+	metrics.ReportClosureFuncCall("BroadcastMsg", s.svcTags)
+	doneFn := metrics.ReportClosureFuncTiming("BroadcastMsg", s.svcTags)
+	res, err := sg.broadcastTx(gasLimit, s.wrapForSigner(sg.client, msgs)...)
+	doneFn()
+
+	if err != nil {
+		metrics.ReportClosureFuncError("BroadcastMsg", s.svcTags)
+		return nil, txtracker.ResultFromError(err)
+	}
+	if res.TxResponse.Code != 0 {
+		// rejected by the mempool checks
+		return nil, txtracker.ResultFromResponse(res.TxResponse)
+	}
+
+	sg.pending.Add(1)
+	return &sentTx{hash: res.TxResponse.TxHash, signer: sg}, txtracker.Result{}
+}
+
+// waitTx follows the transaction until it is included in a block.
+func (s *liquidatorSvc) waitTx(ctx context.Context, tx *sentTx) txtracker.Result {
+	defer tx.signer.pending.Add(-1)
+
+	// the confirmation is awaited even on shutdown, Close bounds the wait with the drain timeout
+	result := s.txTracker.Wait(context.WithoutCancel(ctx), tx.hash)
+	telemetry.GasUsed(result.GasUsed)
+	return result
+}
+
+// broadcastAndWait broadcasts the messages in one transaction and follows it until it is included in a block.
+func (s *liquidatorSvc) broadcastAndWait(ctx context.Context, gasLimit uint64, msgs ...sdktypes.Msg) txtracker.Result {
+	tx, result := s.broadcast(gasLimit, msgs...)
+	if tx == nil {
+		return result
+	}
+	return s.waitTx(ctx, tx)
+}
+
+// reportOutcome reports the transaction outcome of each liquidation in logs, metrics and the run summary.
+func (s *liquidatorSvc) reportOutcome(liquidations []pendingLiquidation, result txtracker.Result) {
+	s.pipeline.untrack(liquidations)
+	s.resolveAttempts(liquidations...)
+
+	for _, liquidation := range liquidations {
+		s.status.recordOutcome(OutcomeStatus{
+			Time:         time.Now(),
+			MarketID:     liquidation.market.Id,
+			SubaccountID: liquidation.msg.SubaccountId,
+			TxHash:       result.TxHash,
+			Outcome:      result.Outcome,
+		})
+		metrics.ReportClosureFuncStatus("LiquidationOutcome", marketTags(s.svcTags, liquidation.market.Id).With("outcome", string(result.Outcome)))
+		telemetry.LiquidationOutcome(liquidation.market.Id, string(result.Outcome))
+
+		outcomeLog := s.logger.WithFields(log.Fields{
+			"market":     liquidation.market.Ticker,
+			"subaccount": liquidation.msg.SubaccountId,
+			"tx_hash":    result.TxHash,
+			"outcome":    result.Outcome,
+		})
+
+		switch result.Outcome {
+		case txtracker.OutcomeSuccess:
+			s.summary.liquidated.Add(1)
+			s.recordFill(liquidation)
+			s.reportExecuted(liquidation)
+			outcomeLog.WithFields(log.Fields{
+				"height":   result.Height,
+				"gas_used": result.GasUsed,
+			}).Infoln("Position liquidated")
+		case txtracker.OutcomeTimeout:
+			s.summary.unconfirmed.Add(1)
+			outcomeLog.Warningln("Liquidation transaction not confirmed in time, its state is unknown")
+		default:
+			s.summary.failed.Add(1)
+			outcomeLog.WithField("log", result.Log).Warningln("Liquidation failed")
+		}
+	}
+}
+
+// reportExecuted exports the notional, expected profit and detection to inclusion time of an executed liquidation.
+func (s *liquidatorSvc) reportExecuted(liquidation pendingLiquidation) {
+	orderNotional := 0.0
+	if liquidation.msg.Order != nil {
+		quantity, price := inventoryChange(liquidation.market, liquidation.msg.Order)
+		orderNotional = quantity.Abs().Mul(price).MustFloat64()
+	}
+	expectedProfit := 0.0
+	if !liquidation.expectedProfit.IsNil() {
+		expectedProfit, _ = liquidation.market.MarginFromChainFormat(liquidation.expectedProfit).Float64()
+	}
+	var detectionToInclusion time.Duration
+	if !liquidation.detectedAt.IsZero() {
+		detectionToInclusion = time.Since(liquidation.detectedAt)
+	}
+
+	telemetry.LiquidationExecuted(liquidation.market.Id, orderNotional, expectedProfit, detectionToInclusion)
+}
+
+// createBatchMessages returns the messages of the batch transaction. When using a granter account each
+// liquidation is wrapped in its own authz execution message.
+func (s *liquidatorSvc) createBatchMessages(batch []pendingLiquidation) []sdktypes.Msg {
+	return s.wrapForGranter(liquidationMessages(batch))
+}
+
+func liquidationMessages(batch []pendingLiquidation) []sdktypes.Msg {
+	msgs := make([]sdktypes.Msg, 0, len(batch))
+	for i := range batch {
+		msgs = append(msgs, &batch[i].msg)
+	}
+	return msgs
+}
+
+// wrapForGranter wraps each message in an authz execution message when using a granter account.
+func (s *liquidatorSvc) wrapForGranter(msgs []sdktypes.Msg) []sdktypes.Msg {
+	return s.wrapForSigner(s.chainClient, msgs)
+}
+
+// wrapForSigner wraps each message in its own authz execution message, executed by the signer client key,
+// when using a granter account. The authz module does not report the index of a failed inner message, so
+// one message per execution keeps the failed message index of the transaction meaningful.
+func (s *liquidatorSvc) wrapForSigner(signer chainclient.ChainClient, msgs []sdktypes.Msg) []sdktypes.Msg {
+	if s.granterPublicAddress == "" {
+		return msgs
+	}
+
+	grantee := signer.FromAddress().String()
+	execMsgs := make([]sdktypes.Msg, 0, len(msgs))
+	for _, msg := range msgs {
+		msgBytes, _ := proto.Marshal(msg)
+		execMsgs = append(execMsgs, &authz.MsgExec{
+			Grantee: grantee,
+			Msgs: []*codectypes.Any{{
+				TypeUrl: sdktypes.MsgTypeURL(msg),
+				Value:   msgBytes,
+			}},
+		})
+	}
+
+	return execMsgs
+}
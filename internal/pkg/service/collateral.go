@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+
+	"cosmossdk.io/math"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// collateralBalances holds the available balances (by denom, in chain format) of the trading subaccount
+// during a liquidation round. The balances are reduced by the collateral of every liquidation order.
+type collateralBalances map[string]math.LegacyDec
+
+func (b collateralBalances) available(denom string) math.LegacyDec {
+	if balance, found := b[denom]; found {
+		return balance
+	}
+	return math.LegacyZeroDec()
+}
+
+func (b collateralBalances) consume(denom string, amount math.LegacyDec) {
+	b[denom] = b.available(denom).Sub(amount)
+}
+
+// loadCollateral fetches the available deposits of the subaccount used for the liquidation orders.
+func (s *liquidatorSvc) loadCollateral(ctx context.Context) (collateralBalances, error) {
+	resp, err := s.chainClient.FetchSubaccountDeposits(ctx, s.tradingSubaccountID().Hex())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the subaccount deposits")
+	}
+
+	balances := make(collateralBalances, len(resp.Deposits))
+	for denom, deposit := range resp.Deposits {
+		if deposit != nil && !deposit.AvailableBalance.IsNil() {
+			balances[denom] = deposit.AvailableBalance
+		}
+	}
+
+	return balances, nil
+}
+
+// tradingSubaccountID returns the subaccount used for the liquidation orders.
+func (s *liquidatorSvc) tradingSubaccountID() common.Hash {
+	if s.granterPublicAddress != "" {
+		return s.granterSubaccountID
+	}
+	return s.subaccountID
+}
+
+// orderCollateral returns the balance needed by a 1x leverage order: its margin and the trading fee.
+func orderCollateral(quantity, price, feeRate math.LegacyDec) math.LegacyDec {
+	return quantity.Mul(price).Mul(math.LegacyOneDec().Add(feeRate))
+}
+
+// fundableQuantity returns the part of quantity that the available balance can collateralize, rounded down
+// to the market quantity tick. Zero is returned when not even one tick can be funded.
+func fundableQuantity(quantity, price, feeRate, available, minQuantityTick math.LegacyDec) math.LegacyDec {
+	if orderCollateral(quantity, price, feeRate).LTE(available) {
+		return quantity
+	}
+	if !available.IsPositive() {
+		return math.LegacyZeroDec()
+	}
+
+	maxQuantity := available.Quo(orderCollateral(math.LegacyOneDec(), price, feeRate))
+	return maxQuantity.Quo(minQuantityTick).TruncateDec().Mul(minQuantityTick)
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/dedup"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+)
+
+func attemptKey(position *derivativeExchangePB.DerivativePosition) dedup.Key {
+	return dedup.Key{
+		MarketID:     position.MarketId,
+		SubaccountID: position.SubaccountId,
+		Direction:    position.Direction,
+	}
+}
+
+// suppressedAttempt returns the state of the previous liquidation attempt of the position when it
+// suppresses a new one.
+func (s *liquidatorSvc) suppressedAttempt(position *derivativeExchangePB.DerivativePosition) (dedup.State, bool) {
+	if s.dedup == nil {
+		return "", false
+	}
+	return s.dedup.Check(attemptKey(position))
+}
+
+// beginAttempts records the liquidations as pending attempts.
+func (s *liquidatorSvc) beginAttempts(liquidations ...pendingLiquidation) {
+	if s.dedup == nil {
+		return
+	}
+	for _, liquidation := range liquidations {
+		s.dedup.Begin(attemptKey(liquidation.position))
+	}
+}
+
+// resolveAttempts starts the cool-down of the liquidations whose outcome is known.
+func (s *liquidatorSvc) resolveAttempts(liquidations ...pendingLiquidation) {
+	if s.dedup == nil {
+		return
+	}
+	for _, liquidation := range liquidations {
+		s.dedup.Resolve(attemptKey(liquidation.position))
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/InjectiveLabs/metrics"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	log "github.com/xlab/suplog"
+)
+
+const (
+	// DetectionModePoll detects liquidable positions polling the indexer LiquidablePositions API.
+	DetectionModePoll = "poll"
+	// DetectionModeStream detects liquidable positions recomputing margins from the chain stream updates.
+	DetectionModeStream = "stream"
+)
+
+// Detector finds the positions eligible for liquidation in a set of markets.
+type Detector interface {
+	// Run reports batches of liquidable positions to the candidates channel until the context
+	// is cancelled (returning nil) or the detector fails (returning the error). An empty batch is
+	// reported after each complete check of the markets.
+	Run(ctx context.Context, markets []core.DerivativeMarket, candidates chan<- []*derivativeExchangePB.DerivativePosition) error
+}
+
+// fallbackDetector runs the primary detector and switches to the fallback one
+// during retryDelay every time the primary detector fails.
+type fallbackDetector struct {
+	primary    Detector
+	fallback   Detector
+	retryDelay time.Duration
+
+	logger  log.Logger
+	svcTags metrics.Tags
+}
+
+// NewFallbackDetector returns a detector that uses the fallback detector while the primary one is failing.
+func NewFallbackDetector(primary Detector, fallback Detector, retryDelay time.Duration) Detector {
+	return &fallbackDetector{
+		primary:    primary,
+		fallback:   fallback,
+		retryDelay: retryDelay,
+
+		logger: log.WithField("svc", "liquidator").WithField("detector", "fallback"),
+		svcTags: metrics.Tags{
+			"svc": svcName,
+		},
+	}
+}
+
+func (d *fallbackDetector) Run(ctx context.Context, markets []core.DerivativeMarket, candidates chan<- []*derivativeExchangePB.DerivativePosition) error {
+	for {
+		err := d.primary.Run(ctx, markets, candidates)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		metrics.ReportClosureFuncError("PrimaryDetector", d.svcTags)
+		d.logger.WithError(err).Warningf("Primary detector failed, using the fallback detector for %s", d.retryDelay)
+
+		fallbackCtx, cancel := context.WithTimeout(ctx, d.retryDelay)
+		err = d.fallback.Run(fallbackCtx, markets, candidates)
+		cancel()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sleepCtx waits for the duration, returning false if the context is cancelled before.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// sendCandidates delivers the positions to the candidates channel, returning false if the context is cancelled before.
+func sendCandidates(ctx context.Context, candidates chan<- []*derivativeExchangePB.DerivativePosition, positions []*derivativeExchangePB.DerivativePosition) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case candidates <- positions:
+		return true
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/InjectiveLabs/metrics"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	"github.com/InjectiveLabs/sdk-go/client/exchange"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	log "github.com/xlab/suplog"
+)
+
+type pollDetector struct {
+	exchangeClient exchange.ExchangeClient
+	interval       time.Duration
+
+	logger  log.Logger
+	svcTags metrics.Tags
+}
+
+// NewPollDetector returns a detector that polls the indexer LiquidablePositions API for every market each interval.
+func NewPollDetector(exchangeClient exchange.ExchangeClient, interval time.Duration) Detector {
+	return &pollDetector{
+		exchangeClient: exchangeClient,
+		interval:       interval,
+
+		logger: log.WithField("svc", "liquidator").WithField("detector", DetectionModePoll),
+		svcTags: metrics.Tags{
+			"svc": svcName,
+		},
+	}
+}
+
+func (d *pollDetector) Run(ctx context.Context, markets []core.DerivativeMarket, candidates chan<- []*derivativeExchangePB.DerivativePosition) error {
+	for {
+		complete := true
+		for _, market := range markets {
+			positions, err := d.liquidablePositions(ctx, market)
+			if err != nil {
+				complete = false
+				continue
+			}
+			if len(positions) > 0 && !sendCandidates(ctx, candidates, positions) {
+				return nil
+			}
+		}
+		// a round with failed requests is not a complete check
+		if complete && !sendCandidates(ctx, candidates, nil) {
+			return nil
+		}
+
+		if !sleepCtx(ctx, d.interval) {
+			return nil
+		}
+	}
+}
+
+func (d *pollDetector) liquidablePositions(ctx context.Context, market core.DerivativeMarket) ([]*derivativeExchangePB.DerivativePosition, error) {
+	tags := marketTags(d.svcTags, market.Id)
+
+	metrics.ReportClosureFuncCall("LiquidablePositions", tags)
+	defer metrics.ReportClosureFuncTiming("LiquidablePositions", tags)()
+
+	req := derivativeExchangePB.LiquidablePositionsRequest{
+		MarketId: market.Id,
+	}
+	resp, err := d.exchangeClient.GetDerivativeLiquidablePositions(ctx, &req)
+	if err != nil {
+		metrics.ReportClosureFuncError("LiquidablePositions", tags)
+		d.logger.WithField("market", market.Ticker).Warning("Failed to get liquidable positions")
+		return nil, err
+	}
+
+	return resp.Positions, nil
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/eligibility"
+	"github.com/InjectiveLabs/metrics"
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+	chainstreamtypes "github.com/InjectiveLabs/sdk-go/chain/stream/types"
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	"github.com/InjectiveLabs/sdk-go/client/exchange"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+)
+
+// streamMarketState is the local copy of the market values needed to check the positions margin.
+type streamMarketState struct {
+	market core.DerivativeMarket
+	state  *eligibility.MarketState
+}
+
+type streamDetector struct {
+	chainClient    chainclient.ChainClient
+	exchangeClient exchange.ExchangeClient
+
+	markets   map[string]*streamMarketState
+	positions map[string]map[string]*exchangetypes.Position
+	// oraclePrices are the latest prices by oracle symbol, streamed or derived from a market mark price
+	oraclePrices map[string]math.LegacyDec
+
+	logger  log.Logger
+	svcTags metrics.Tags
+}
+
+// NewStreamDetector returns a detector that keeps the markets positions, mark prices and funding updated
+// from the chain stream and the exchange market stream, and recomputes their margin every time a position,
+// an oracle price or a market changes. The markets are only fetched from the chain for the initial snapshot.
+func NewStreamDetector(chainClient chainclient.ChainClient, exchangeClient exchange.ExchangeClient) Detector {
+	return &streamDetector{
+		chainClient:    chainClient,
+		exchangeClient: exchangeClient,
+
+		logger: log.WithField("svc", "liquidator").WithField("detector", DetectionModeStream),
+		svcTags: metrics.Tags{
+			"svc": svcName,
+		},
+	}
+}
+
+func (d *streamDetector) Run(ctx context.Context, markets []core.DerivativeMarket, candidates chan<- []*derivativeExchangePB.DerivativePosition) error {
+	d.markets = make(map[string]*streamMarketState, len(markets))
+	d.positions = make(map[string]map[string]*exchangetypes.Position, len(markets))
+	d.oraclePrices = make(map[string]math.LegacyDec)
+
+	marketIDs := make([]string, 0, len(markets))
+	symbols := make(map[string]struct{}, 2*len(markets))
+	for _, market := range markets {
+		d.markets[market.Id] = &streamMarketState{market: market}
+		d.positions[market.Id] = make(map[string]*exchangetypes.Position)
+		marketIDs = append(marketIDs, market.Id)
+		symbols[market.OracleBase] = struct{}{}
+		symbols[market.OracleQuote] = struct{}{}
+	}
+	oracleSymbols := make([]string, 0, len(symbols))
+	for symbol := range symbols {
+		oracleSymbols = append(oracleSymbols, symbol)
+	}
+
+	// the streams are closed when the detector stops
+	streamCtx, cancelStreams := context.WithCancel(ctx)
+	defer cancelStreams()
+
+	// subscribe before taking the snapshot, the streams send full positions and markets so replaying them is harmless
+	chainStream, err := d.chainClient.ChainStream(streamCtx, chainstreamtypes.StreamRequest{
+		PositionsFilter: &chainstreamtypes.PositionsFilter{
+			MarketIds:     marketIDs,
+			SubaccountIds: []string{"*"},
+		},
+		OraclePriceFilter: &chainstreamtypes.OraclePriceFilter{
+			Symbol: oracleSymbols,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to subscribe to the chain stream")
+	}
+	// the chain stream does not send the market funding, the exchange market stream does
+	marketStream, err := d.exchangeClient.StreamDerivativeMarket(streamCtx, marketIDs)
+	if err != nil {
+		return errors.Wrap(err, "failed to subscribe to the derivative markets stream")
+	}
+
+	for marketID := range d.markets {
+		if err := d.refreshMarket(ctx, marketID); err != nil {
+			return err
+		}
+	}
+
+	if err := d.loadPositions(ctx); err != nil {
+		return err
+	}
+
+	chainUpdates := make(chan *chainstreamtypes.StreamResponse)
+	marketUpdates := make(chan *derivativeExchangePB.StreamMarketResponse)
+	streamErrs := make(chan error, 2)
+	go forwardStream(streamCtx, chainStream.Recv, chainUpdates, streamErrs, "chain stream interrupted")
+	go forwardStream(streamCtx, marketStream.Recv, marketUpdates, streamErrs, "derivative markets stream interrupted")
+
+	for {
+		var updatedMarkets map[string]struct{}
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-streamErrs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		case resp := <-chainUpdates:
+			updatedMarkets = d.applyPositions(resp.Positions)
+			for marketID := range d.applyOraclePrices(ctx, resp.OraclePrices) {
+				updatedMarkets[marketID] = struct{}{}
+			}
+		case resp := <-marketUpdates:
+			updatedMarkets = d.applyMarket(resp.Market)
+		}
+
+		for marketID := range updatedMarkets {
+			positions := d.liquidablePositions(marketID)
+			if len(positions) > 0 && !sendCandidates(ctx, candidates, positions) {
+				return nil
+			}
+		}
+		if !sendCandidates(ctx, candidates, nil) {
+			return nil
+		}
+	}
+}
+
+// forwardStream sends the messages received from a stream to messages until the stream fails or ctx is done.
+func forwardStream[T any](ctx context.Context, recv func() (T, error), messages chan<- T, errs chan<- error, failure string) {
+	for {
+		msg, err := recv()
+		if err != nil {
+			errs <- errors.Wrap(err, failure)
+			return
+		}
+
+		select {
+		case messages <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refreshMarket loads the market mark price, maintenance margin ratio and funding from the chain.
+func (d *streamDetector) refreshMarket(ctx context.Context, marketID string) error {
+	state := d.markets[marketID]
+	tags := marketTags(d.svcTags, marketID)
+
+	resp, err := d.chainClient.FetchChainDerivativeMarket(ctx, marketID)
+	metrics.ReportClosureFuncCall("FetchChainDerivativeMarket", tags)
+	if err != nil {
+		metrics.ReportClosureFuncError("FetchChainDerivativeMarket", tags)
+		return errors.Wrapf(err, "failed to fetch derivative market %s", marketID)
+	}
+	if resp.Market == nil || resp.Market.Market == nil {
+		return errors.Errorf("derivative market %s not found in chain", marketID)
+	}
+
+	marketState := eligibility.MarketStateFromChain(resp.Market)
+	state.state = &marketState
+
+	return nil
+}
+
+// loadPositions takes the snapshot of the open positions in the detector markets.
+func (d *streamDetector) loadPositions(ctx context.Context) error {
+	resp, err := d.chainClient.FetchChainPositions(ctx)
+	metrics.ReportClosureFuncCall("FetchChainPositions", d.svcTags)
+	if err != nil {
+		metrics.ReportClosureFuncError("FetchChainPositions", d.svcTags)
+		return errors.Wrap(err, "failed to fetch the chain positions")
+	}
+
+	for _, derivativePosition := range resp.State {
+		marketPositions, found := d.positions[derivativePosition.MarketId]
+		if !found || derivativePosition.Position == nil {
+			continue
+		}
+		marketPositions[derivativePosition.SubaccountId] = derivativePosition.Position.Copy()
+	}
+
+	return nil
+}
+
+// applyPositions updates the local positions with the stream updates, returning the markets that changed.
+func (d *streamDetector) applyPositions(positions []*chainstreamtypes.Position) map[string]struct{} {
+	updatedMarkets := make(map[string]struct{})
+
+	for _, position := range positions {
+		marketPositions, found := d.positions[position.MarketId]
+		if !found {
+			continue
+		}
+
+		if position.Quantity.IsNil() || position.Quantity.IsZero() {
+			delete(marketPositions, position.SubaccountId)
+			continue
+		}
+
+		marketPositions[position.SubaccountId] = &exchangetypes.Position{
+			IsLong:                 position.IsLong,
+			Quantity:               position.Quantity,
+			EntryPrice:             position.EntryPrice,
+			Margin:                 position.Margin,
+			CumulativeFundingEntry: position.CumulativeFundingEntry,
+		}
+		updatedMarkets[position.MarketId] = struct{}{}
+	}
+
+	return updatedMarkets
+}
+
+// applyOraclePrices records the streamed oracle prices and reprices the markets using the updated symbols,
+// returning the repriced markets.
+func (d *streamDetector) applyOraclePrices(ctx context.Context, oraclePrices []*chainstreamtypes.OraclePrice) map[string]struct{} {
+	updatedSymbols := make(map[string]struct{}, len(oraclePrices))
+	for _, oraclePrice := range oraclePrices {
+		if oraclePrice.Price.IsNil() || !oraclePrice.Price.IsPositive() {
+			continue
+		}
+		d.oraclePrices[oraclePrice.Symbol] = oraclePrice.Price
+		updatedSymbols[oraclePrice.Symbol] = struct{}{}
+	}
+
+	updatedMarkets := make(map[string]struct{})
+	for marketID, state := range d.markets {
+		_, baseUpdated := updatedSymbols[state.market.OracleBase]
+		_, quoteUpdated := updatedSymbols[state.market.OracleQuote]
+		if !baseUpdated && !quoteUpdated {
+			continue
+		}
+
+		if err := d.updateMarkPrice(ctx, state); err != nil {
+			d.logger.WithError(err).Warningln("Failed to update the mark price of market", marketID)
+			continue
+		}
+		updatedMarkets[marketID] = struct{}{}
+	}
+
+	return updatedMarkets
+}
+
+// updateMarkPrice computes the market mark price from the oracle prices of its base and quote symbols, like
+// the chain does. A symbol without a streamed price yet, like a quote symbol whose price seldom changes, gets
+// its price derived once from the mark price fetched from the chain.
+func (d *streamDetector) updateMarkPrice(ctx context.Context, state *streamMarketState) error {
+	scale := math.LegacyNewDec(10).Power(uint64(state.market.OracleScaleFactor))
+	basePrice, baseKnown := d.oraclePrices[state.market.OracleBase]
+	quotePrice, quoteKnown := d.oraclePrices[state.market.OracleQuote]
+
+	if baseKnown && quoteKnown {
+		state.state.MarkPrice = basePrice.Mul(scale).Quo(quotePrice)
+		return nil
+	}
+
+	if err := d.refreshMarket(ctx, state.market.Id); err != nil {
+		return err
+	}
+	ratio := state.state.MarkPrice.Quo(scale)
+	if !ratio.IsPositive() {
+		return errors.Errorf("derivative market %s has no mark price", state.market.Id)
+	}
+	if baseKnown {
+		d.oraclePrices[state.market.OracleQuote] = basePrice.Quo(ratio)
+	} else if quoteKnown {
+		d.oraclePrices[state.market.OracleBase] = ratio.Mul(quotePrice)
+	}
+
+	return nil
+}
+
+// applyMarket updates the maintenance margin ratio and funding of a market from the exchange market stream,
+// returning the market when it changed.
+func (d *streamDetector) applyMarket(market *derivativeExchangePB.DerivativeMarketInfo) map[string]struct{} {
+	updatedMarkets := make(map[string]struct{})
+	if market == nil {
+		return updatedMarkets
+	}
+	state, found := d.markets[market.MarketId]
+	if !found || state.state == nil {
+		return updatedMarkets
+	}
+
+	if maintenanceMarginRatio, err := math.LegacyNewDecFromStr(market.MaintenanceMarginRatio); err == nil {
+		state.state.MaintenanceMarginRatio = maintenanceMarginRatio
+	} else {
+		d.logger.WithError(err).Warningln("Ignoring the invalid maintenance margin ratio of market", market.MarketId)
+	}
+
+	if funding := market.PerpetualMarketFunding; funding != nil && state.state.CumulativeFunding != nil {
+		if cumulativeFunding, err := math.LegacyNewDecFromStr(funding.CumulativeFunding); err == nil {
+			state.state.CumulativeFunding = &cumulativeFunding
+		} else {
+			d.logger.WithError(err).Warningln("Ignoring the invalid cumulative funding of market", market.MarketId)
+		}
+	}
+
+	updatedMarkets[market.MarketId] = struct{}{}
+	return updatedMarkets
+}
+
+// liquidablePositions returns the market positions whose liquidation price has been reached by the mark price.
+func (d *streamDetector) liquidablePositions(marketID string) []*derivativeExchangePB.DerivativePosition {
+	state := d.markets[marketID]
+	if state.state == nil {
+		return nil
+	}
+
+	var liquidable []*derivativeExchangePB.DerivativePosition
+	for subaccountID, position := range d.positions[marketID] {
+		result := eligibility.Evaluate(eligibility.PositionFromChain(position), *state.state)
+		if !result.IsLiquidable {
+			continue
+		}
+
+		direction := "long"
+		if !position.IsLong {
+			direction = "short"
+		}
+
+		liquidable = append(liquidable, &derivativeExchangePB.DerivativePosition{
+			Ticker:           state.market.Ticker,
+			MarketId:         marketID,
+			SubaccountId:     subaccountID,
+			Direction:        direction,
+			Quantity:         position.Quantity.String(),
+			EntryPrice:       position.EntryPrice.String(),
+			Margin:           position.Margin.String(),
+			LiquidationPrice: result.LiquidationPrice.String(),
+			MarkPrice:        state.state.MarkPrice.String(),
+		})
+	}
+
+	return liquidable
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/profitability"
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+)
+
+// DryRunRecord is a liquidation the bot would have broadcast, written as one JSON line by the dry run mode.
+// Quantities, prices and amounts are human readable (in base and quote asset).
+type DryRunRecord struct {
+	Time           time.Time `json:"time"`
+	MarketID       string    `json:"market_id"`
+	Ticker         string    `json:"ticker"`
+	SubaccountID   string    `json:"subaccount_id"`
+	Direction      string    `json:"direction"`
+	PricingPolicy  string    `json:"pricing_policy"`
+	Quantity       string    `json:"quantity"`
+	Price          string    `json:"price"`
+	MarkPrice      string    `json:"mark_price"`
+	Notional       string    `json:"notional"`
+	Margin         string    `json:"margin"`
+	Reward         string    `json:"reward"`
+	FillPnl        string    `json:"fill_pnl"`
+	TradingFee     string    `json:"trading_fee"`
+	GasCost        string    `json:"gas_cost"`
+	ExpectedProfit string    `json:"expected_profit"`
+	Simulation     string    `json:"simulation,omitempty"`
+	SimulatedGas   uint64    `json:"simulated_gas,omitempty"`
+	GasLimit       uint64    `json:"gas_limit,omitempty"`
+	// MessageType is the type of the message that would have been broadcast (the authz execution when using a granter)
+	MessageType string                              `json:"message_type"`
+	Liquidation *exchangetypes.MsgLiquidatePosition `json:"liquidation"`
+}
+
+// DryRunRecorder writes the dry run records as JSON lines.
+type DryRunRecorder struct {
+	mux     sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewDryRunRecorder(w io.Writer) *DryRunRecorder {
+	return &DryRunRecorder{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+func (r *DryRunRecorder) Record(record DryRunRecord) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if err := r.encoder.Encode(record); err != nil {
+		return errors.Wrap(err, "failed to write the dry run record")
+	}
+	return nil
+}
+
+// recordDryRun records the liquidation instead of broadcasting it.
+func (s *liquidatorSvc) recordDryRun(
+	liquidation pendingLiquidation,
+	policyName string,
+	estimate profitability.Estimate,
+	simulation string,
+	simulatedGas uint64,
+) {
+	market := liquidation.market
+	order := liquidation.msg.Order
+	msg := s.createBatchMessages([]pendingLiquidation{liquidation})[0]
+
+	quantity := market.QuantityFromChainFormat(order.OrderInfo.Quantity)
+	price := market.PriceFromChainFormat(order.OrderInfo.Price)
+
+	record := DryRunRecord{
+		Time:           time.Now().UTC(),
+		MarketID:       market.Id,
+		Ticker:         market.Ticker,
+		SubaccountID:   liquidation.position.SubaccountId,
+		Direction:      liquidation.position.Direction,
+		PricingPolicy:  policyName,
+		Quantity:       quantity.String(),
+		Price:          price.String(),
+		MarkPrice:      market.PriceFromChainFormat(math.LegacyMustNewDecFromStr(liquidation.position.MarkPrice)).String(),
+		Notional:       quantity.Mul(price).String(),
+		Margin:         market.MarginFromChainFormat(order.Margin).String(),
+		Reward:         market.MarginFromChainFormat(estimate.Reward).String(),
+		FillPnl:        market.MarginFromChainFormat(estimate.FillPnl).String(),
+		TradingFee:     market.MarginFromChainFormat(estimate.TradingFee).String(),
+		GasCost:        market.MarginFromChainFormat(estimate.GasCost).String(),
+		ExpectedProfit: market.MarginFromChainFormat(estimate.Profit).String(),
+		Simulation:     simulation,
+		SimulatedGas:   simulatedGas,
+		GasLimit:       liquidation.gasLimit,
+		MessageType:    sdktypes.MsgTypeURL(msg),
+		Liquidation:    &liquidation.msg,
+	}
+
+	s.summary.dryRun.Add(1)
+	s.logger.WithFields(log.Fields{
+		"market":          record.Ticker,
+		"subaccount":      record.SubaccountID,
+		"quantity":        record.Quantity,
+		"price":           record.Price,
+		"notional":        record.Notional,
+		"expected_profit": record.ExpectedProfit,
+		"message_type":    record.MessageType,
+	}).Infoln("Dry run: liquidation not broadcast")
+
+	if err := s.dryRunRecorder.Record(record); err != nil {
+		s.logger.WithError(err).Warningln("Failed to record the dry run liquidation")
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/InjectiveLabs/metrics"
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/grants"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/telemetry"
+)
+
+// GrantSettings configures the checks of the authz grants when using a granter account.
+type GrantSettings struct {
+	// CheckInterval is the time between two checks of the grants (not checked while running when zero)
+	CheckInterval time.Duration
+	// Monitor warns when the grants get close to their expiration (no warnings when nil)
+	Monitor *grants.Monitor
+}
+
+// requiredMsgTypes returns the message types the grantee keys execute for the granter with the
+// configured features.
+func (s *liquidatorSvc) requiredMsgTypes() []string {
+	return RequiredMsgTypes(s.marketsAssistant, s.unwindSettings, s.hedgeSettings)
+}
+
+// RequiredMsgTypes returns the message types executed for the granter with the unwind and hedge settings.
+func RequiredMsgTypes(marketsAssistant chainclient.MarketsAssistant, unwindSettings UnwindSettings, hedgeSettings HedgeSettings) []string {
+	msgTypes := []string{grants.MsgLiquidatePosition}
+
+	if unwindSettings.Policy != nil {
+		msgTypes = append(msgTypes, grants.MsgCreateDerivativeMarketOrder, grants.MsgBatchUpdateOrders)
+	}
+
+	hasDerivativeHedge, hasSpotHedge := false, false
+	for _, hedge := range hedgeSettings.Markets {
+		if _, isDerivative := marketsAssistant.AllDerivativeMarkets()[hedge.MarketID]; isDerivative {
+			hasDerivativeHedge = true
+		} else {
+			hasSpotHedge = true
+		}
+	}
+	if hasDerivativeHedge && unwindSettings.Policy == nil {
+		msgTypes = append(msgTypes, grants.MsgCreateDerivativeMarketOrder)
+	}
+	if hasSpotHedge {
+		msgTypes = append(msgTypes, grants.MsgCreateSpotMarketOrder)
+	}
+
+	return msgTypes
+}
+
+// errGrantsUnchecked is returned by checkGrants when a grant query failed, the grant being neither known valid
+// nor invalid.
+var errGrantsUnchecked = errors.New("grants not checked")
+
+// checkGrants fetches the grants of every signing key, reports their time to expiry and warns when they
+// get close to it. It returns an error when a grant is missing or expired, or errGrantsUnchecked when a
+// grant could not be fetched.
+func (s *liquidatorSvc) checkGrants(ctx context.Context) error {
+	if s.granterPublicAddress == "" {
+		return nil
+	}
+
+	now := time.Now()
+	var invalid []grants.Grant
+	var fetchErrs []error
+	for _, sg := range s.signerPool().signers {
+		for _, msgType := range s.requiredMsgTypes() {
+			tags := metrics.Tags{"grantee": sg.address, "msg_type": msgType}
+			for key, value := range s.svcTags {
+				tags[key] = value
+			}
+
+			grant, err := grants.Fetch(ctx, s.chainClient, s.granterPublicAddress, sg.address, msgType)
+			if err != nil {
+				metrics.ReportClosureFuncError("CheckGrant", tags)
+				s.logger.WithError(err).Warningln("Failed to check the grant")
+				fetchErrs = append(fetchErrs, err)
+				continue
+			}
+
+			grantLog := s.logger.WithFields(log.Fields{
+				"granter":  s.granterPublicAddress,
+				"grantee":  sg.address,
+				"msg_type": msgType,
+			})
+			if !grant.Valid(now) {
+				metrics.ReportClosureFuncError("CheckGrant", tags)
+				grantLog.Errorln("Grant missing or expired, the liquidations fail until it is renewed")
+				invalid = append(invalid, grant)
+				continue
+			}
+
+			left, expires := grant.TimeToExpiry(now)
+			if !expires {
+				continue
+			}
+			metrics.CustomReport(func(statter metrics.Statter, tagSpec []string) {
+				_ = statter.Gauge("grant.time_to_expiry", left.Seconds(), tagSpec, 1)
+			}, tags)
+			telemetry.GrantTimeToExpiry(s.granterPublicAddress, sg.address, msgType, left)
+
+			if s.grantSettings.Monitor == nil {
+				continue
+			}
+			if threshold, crossed := s.grantSettings.Monitor.Crossed(grant); crossed {
+				grantLog.WithField("expiration", grant.Expiration.UTC().Format(time.RFC3339)).
+					Warningf("Grant expires in less than %s, it has to be renewed", threshold)
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		return errors.Errorf("%d grants from %s missing or expired (first: %s to %s)",
+			len(invalid), s.granterPublicAddress, invalid[0].MsgType, invalid[0].Grantee)
+	}
+	if len(fetchErrs) > 0 {
+		return errors.Wrapf(errGrantsUnchecked, "%d queries of the grants from %s failed (first: %v)",
+			len(fetchErrs), s.granterPublicAddress, fetchErrs[0])
+	}
+	return nil
+}
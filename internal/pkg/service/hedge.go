@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/metrics"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/txtracker"
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Hedge is the market used to hedge the positions acquired in a liquidated market.
+type Hedge struct {
+	// MarketID is a derivative or spot market
+	MarketID string
+	// Ratio is the hedge order quantity relative to the liquidation order quantity
+	Ratio math.LegacyDec
+}
+
+// HedgeSettings configures the hedge of the executed liquidations.
+type HedgeSettings struct {
+	// Markets are the hedges by liquidated market ID
+	Markets map[string]Hedge
+	// MaxSlippageBps is the maximum distance of the hedge orders worst price from the hedge market price
+	MaxSlippageBps math.LegacyDec
+}
+
+// hedgeLiquidations sends the market orders hedging the executed liquidations, in one transaction broadcast
+// right after the liquidations one. Only the liquidations in markets with a configured hedge are hedged.
+func (s *liquidatorSvc) hedgeLiquidations(ctx context.Context, liquidations []pendingLiquidation) {
+	// the liquidations are executed, their hedge is sent even on shutdown
+	ctx = context.WithoutCancel(ctx)
+
+	var msgs []sdktypes.Msg
+	var hedgeMarketIDs []string
+	for _, liquidation := range liquidations {
+		hedge, found := s.hedgeSettings.Markets[liquidation.market.Id]
+		if !found {
+			continue
+		}
+
+		msg, err := s.createHedgeMessage(ctx, liquidation, hedge)
+		if err != nil {
+			metrics.ReportClosureFuncError("CreateHedgeOrder", marketTags(s.svcTags, hedge.MarketID))
+			s.logger.WithError(err).Warningf("Failed to create the hedge order of the %s liquidation of %s",
+				liquidation.market.Ticker, liquidation.msg.SubaccountId)
+			continue
+		}
+
+		msgs = append(msgs, msg)
+		hedgeMarketIDs = append(hedgeMarketIDs, hedge.MarketID)
+	}
+	if len(msgs) == 0 {
+		return
+	}
+
+	var result txtracker.Result
+	var gasLimit uint64
+	var err error
+	if s.simulationSettings.Enabled {
+		_, gasLimit, err = s.simulateMessages(s.wrapForGranter(msgs)...)
+	}
+	if err != nil {
+		result = txtracker.ResultFromError(err)
+	} else {
+		result = s.broadcastAndWait(ctx, gasLimit, msgs...)
+	}
+
+	for _, marketID := range hedgeMarketIDs {
+		metrics.ReportClosureFuncStatus("HedgeOrder", marketTags(s.svcTags, marketID).With("outcome", string(result.Outcome)))
+	}
+
+	hedgeLog := s.logger.WithFields(log.Fields{
+		"orders":  len(hedgeMarketIDs),
+		"tx_hash": result.TxHash,
+		"outcome": result.Outcome,
+	})
+	if result.Outcome != txtracker.OutcomeSuccess {
+		hedgeLog.WithField("log", result.Log).Warningln("Hedge orders failed, the liquidated positions are not hedged")
+		return
+	}
+	hedgeLog.Infoln("Liquidated positions hedged")
+}
+
+// createHedgeMessage builds the market order in the hedge market offsetting the position acquired by the liquidation.
+func (s *liquidatorSvc) createHedgeMessage(ctx context.Context, liquidation pendingLiquidation, hedge Hedge) (sdktypes.Msg, error) {
+	acquired, _ := inventoryChange(liquidation.market, liquidation.msg.Order)
+	isBuy := acquired.IsNegative()
+	quantity := decimal.RequireFromString(acquired.Abs().Mul(hedge.Ratio).String())
+	senderAddress := s.tradingAccountAddress()
+
+	orderType := exchangetypes.OrderType_SELL
+	if isBuy {
+		orderType = exchangetypes.OrderType_BUY
+	}
+
+	if market, found := s.marketsAssistant.AllDerivativeMarkets()[hedge.MarketID]; found {
+		marketResp, err := s.chainClient.FetchChainDerivativeMarket(ctx, market.Id)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to fetch the hedge market")
+		}
+		if marketResp.Market == nil || marketResp.Market.MarkPrice.IsNil() {
+			return nil, errors.Errorf("hedge market %s has no mark price", market.Id)
+		}
+
+		price := hedgeWorstPrice(marketResp.Market.MarkPrice, isBuy, s.hedgeSettings.MaxSlippageBps)
+		order := s.chainClient.CreateDerivativeOrder(
+			s.tradingSubaccountID(),
+			&chainclient.DerivativeOrderData{
+				OrderType:    orderType,
+				Quantity:     quantity,
+				Price:        market.PriceFromChainFormat(price),
+				Leverage:     decimal.RequireFromString("1"),
+				FeeRecipient: senderAddress,
+				MarketId:     market.Id,
+				Cid:          uuid.NewString(),
+			},
+			s.marketsAssistant,
+		)
+
+		return &exchangetypes.MsgCreateDerivativeMarketOrder{
+			Sender: senderAddress,
+			Order:  *order,
+		}, nil
+	}
+
+	if market, found := s.marketsAssistant.AllSpotMarkets()[hedge.MarketID]; found {
+		resp, err := s.chainClient.FetchSpotMidPriceAndTOB(ctx, market.Id)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to fetch the hedge market price")
+		}
+		if resp.MidPrice == nil || resp.MidPrice.IsNil() {
+			return nil, errors.Errorf("hedge market %s has no mid price", market.Id)
+		}
+
+		price := hedgeWorstPrice(*resp.MidPrice, isBuy, s.hedgeSettings.MaxSlippageBps)
+		order := s.chainClient.CreateSpotOrder(
+			s.tradingSubaccountID(),
+			&chainclient.SpotOrderData{
+				OrderType:    orderType,
+				Quantity:     quantity,
+				Price:        market.PriceFromChainFormat(price),
+				FeeRecipient: senderAddress,
+				MarketId:     market.Id,
+				Cid:          uuid.NewString(),
+			},
+			s.marketsAssistant,
+		)
+
+		return &exchangetypes.MsgCreateSpotMarketOrder{
+			Sender: senderAddress,
+			Order:  *order,
+		}, nil
+	}
+
+	return nil, errors.Errorf("hedge market %s not found", hedge.MarketID)
+}
+
+// hedgeWorstPrice returns the worst price accepted by a hedge market order, slippageBps away from the market price.
+func hedgeWorstPrice(price math.LegacyDec, isBuy bool, slippageBps math.LegacyDec) math.LegacyDec {
+	slippage := slippageBps.QuoInt64(10000)
+	if !isBuy {
+		slippage = slippage.Neg()
+	}
+	return price.Mul(math.LegacyOneDec().Add(slippage))
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/metrics"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/inventory"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/telemetry"
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+)
+
+// syncInventory replaces the tracked inventory with the positions held by the trading subaccount on chain.
+func (s *liquidatorSvc) syncInventory(ctx context.Context) error {
+	states, err := s.fetchTradingPositions(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.updateInventory(states)
+	return nil
+}
+
+// fetchTradingPositions returns the positions held on chain by the subaccount used for the liquidation orders.
+func (s *liquidatorSvc) fetchTradingPositions(ctx context.Context) ([]exchangetypes.DerivativePosition, error) {
+	resp, err := s.chainClient.FetchChainSubaccountPositions(ctx, s.tradingSubaccountID().Hex())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the subaccount positions")
+	}
+	return resp.State, nil
+}
+
+// updateInventory replaces the tracked inventory with the positions held on chain.
+func (s *liquidatorSvc) updateInventory(states []exchangetypes.DerivativePosition) {
+	allMarkets := s.marketsAssistant.AllDerivativeMarkets()
+	positions := make(map[string]inventory.Position, len(states))
+	for _, state := range states {
+		market, found := allMarkets[state.MarketId]
+		if !found || state.Position == nil {
+			s.logger.Debugf("Ignoring the inventory position in unknown market %s", state.MarketId)
+			continue
+		}
+
+		quantity := humanDec(market.QuantityFromChainFormat(state.Position.Quantity))
+		if !state.Position.IsLong {
+			quantity = quantity.Neg()
+		}
+
+		// keep the latest price seen by the bot, the chain only provides the entry price
+		price := s.inventory.Position(s.tradingSubaccountID().Hex(), state.MarketId).Price
+		if !price.IsPositive() {
+			price = humanDec(market.PriceFromChainFormat(state.Position.EntryPrice))
+		}
+
+		positions[state.MarketId] = inventory.Position{Quantity: quantity, Price: price}
+	}
+
+	s.inventory.Sync(s.tradingSubaccountID().Hex(), positions)
+	s.reportInventory()
+}
+
+// inventoryChange returns the net position change (human readable) caused by the liquidation order.
+func inventoryChange(market core.DerivativeMarket, order *exchangetypes.DerivativeOrder) (quantity, price math.LegacyDec) {
+	quantity = humanDec(market.QuantityFromChainFormat(order.OrderInfo.Quantity))
+	if !order.IsBuy() {
+		quantity = quantity.Neg()
+	}
+	return quantity, humanDec(market.PriceFromChainFormat(order.OrderInfo.Price))
+}
+
+// recordFill adds the position acquired by an executed liquidation to the inventory.
+func (s *liquidatorSvc) recordFill(liquidation pendingLiquidation) {
+	if s.inventory == nil {
+		return
+	}
+
+	quantity, price := inventoryChange(liquidation.market, liquidation.msg.Order)
+	position := s.inventory.Apply(s.tradingSubaccountID().Hex(), liquidation.market.Id, quantity, price)
+	s.logger.Debugf("Inventory of %s is now %s", liquidation.market.Ticker, position.Quantity.String())
+	s.reportInventory()
+}
+
+// reportInventory reports the net position and exposure of every market of the trading subaccount, and
+// their total exposure.
+func (s *liquidatorSvc) reportInventory() {
+	subaccountID := s.tradingSubaccountID().Hex()
+	positions := s.inventory.Positions(subaccountID)
+	total := math.LegacyZeroDec()
+	for _, position := range positions {
+		total = total.Add(position.Exposure())
+	}
+
+	metrics.CustomReport(func(statter metrics.Statter, tagSpec []string) {
+		for marketID, position := range positions {
+			marketTagSpec := metrics.JoinTags(marketTags(s.svcTags, marketID))
+			_ = statter.Gauge("inventory.net_quantity", position.Quantity.MustFloat64(), marketTagSpec, 1)
+			_ = statter.Gauge("inventory.exposure", position.Exposure().MustFloat64(), marketTagSpec, 1)
+		}
+		_ = statter.Gauge("inventory.total_exposure", total.MustFloat64(), tagSpec, 1)
+	}, s.svcTags)
+
+	for marketID, position := range positions {
+		telemetry.MarketInventory(marketID, position.Quantity.MustFloat64(), position.Exposure().MustFloat64())
+	}
+	telemetry.TotalExposure(subaccountID, total.MustFloat64())
+}
+
+// humanDec converts a human readable decimal into a LegacyDec, rounding it to the LegacyDec precision.
+func humanDec(value decimal.Decimal) math.LegacyDec {
+	return math.LegacyMustNewDecFromStr(value.StringFixed(math.LegacyPrecision))
+}
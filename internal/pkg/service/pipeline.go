@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// txPipeline bounds the liquidation transactions awaiting their confirmation, and tracks the positions they
+// liquidate so a position is never in two transactions at once.
+type txPipeline struct {
+	initOnce sync.Once
+	slots    chan struct{}
+	// pending counts the acquired slots
+	pending atomic.Int64
+
+	mu        sync.Mutex
+	positions map[string]pendingLiquidation
+
+	// running counts the batches whose outcome is not handled yet
+	running sync.WaitGroup
+}
+
+// acquire waits for one of the maxInFlight transaction slots. It returns false when ctx is cancelled first.
+func (p *txPipeline) acquire(ctx context.Context, maxInFlight int) bool {
+	p.initOnce.Do(func() {
+		if maxInFlight < 1 {
+			maxInFlight = 1
+		}
+		p.slots = make(chan struct{}, maxInFlight)
+	})
+
+	select {
+	case p.slots <- struct{}{}:
+		p.pending.Add(1)
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *txPipeline) release() {
+	p.pending.Add(-1)
+	<-p.slots
+}
+
+// pendingTxs returns the number of transactions awaiting their confirmation.
+func (p *txPipeline) pendingTxs() int {
+	return int(p.pending.Load())
+}
+
+// track adds the liquidations to the in flight set.
+func (p *txPipeline) track(liquidations []pendingLiquidation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.positions == nil {
+		p.positions = make(map[string]pendingLiquidation)
+	}
+	for _, liquidation := range liquidations {
+		p.positions[liquidation.key()] = liquidation
+	}
+}
+
+// untrack removes the liquidations, whose outcome is known, from the in flight set.
+func (p *txPipeline) untrack(liquidations []pendingLiquidation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, liquidation := range liquidations {
+		delete(p.positions, liquidation.key())
+	}
+}
+
+// isInFlight reports whether a liquidation of the position is in flight.
+func (p *txPipeline) isInFlight(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, found := p.positions[key]
+	return found
+}
+
+// inFlight returns the liquidations in flight.
+func (p *txPipeline) inFlight() []pendingLiquidation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	liquidations := make([]pendingLiquidation, 0, len(p.positions))
+	for _, liquidation := range p.positions {
+		liquidations = append(liquidations, liquidation)
+	}
+	return liquidations
+}
+
+// wait blocks until the outcome of every broadcast batch is handled.
+func (p *txPipeline) wait() {
+	p.running.Wait()
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/sdk-go/client"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/eligibility"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/profitability"
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+)
+
+const (
+	feeParamsRefreshInterval     = time.Hour
+	feeTokenPriceRefreshInterval = time.Minute
+)
+
+// ProfitSettings configures the profit estimate of the liquidation candidates. The minimum profit of the
+// profitability gate is a reloadable parameter.
+type ProfitSettings struct {
+	// EstimatedGas is the gas expected to be used by a liquidation transaction
+	EstimatedGas uint64
+	// FeeTokenMarketID is the INJ spot market (quoted in the derivative markets quote asset) used
+	// to value the gas. Gas is not considered when empty.
+	FeeTokenMarketID string
+}
+
+// profitState caches the chain values used to estimate the liquidations profit.
+type profitState struct {
+	liquidatorRewardShareRate math.LegacyDec
+	takerDiscountRate         math.LegacyDec
+	feeParamsUpdatedAt        time.Time
+
+	feeTokenPrice          math.LegacyDec
+	feeTokenDecimals       int64
+	feeTokenPriceUpdatedAt time.Time
+}
+
+// estimateProfit returns the expected PnL of liquidating the position with an order of quantity at price.
+func (s *liquidatorSvc) estimateProfit(
+	ctx context.Context,
+	position *derivativeExchangePB.DerivativePosition,
+	market core.DerivativeMarket,
+	result eligibility.Result,
+	price math.LegacyDec,
+	quantity math.LegacyDec,
+) (profitability.Estimate, error) {
+	if err := s.refreshFeeParams(ctx); err != nil {
+		return profitability.Estimate{}, err
+	}
+
+	gasCost, err := s.gasCostInQuote(ctx, market)
+	if err != nil {
+		return profitability.Estimate{}, err
+	}
+
+	return profitability.EstimatePnl(profitability.Inputs{
+		IsBuy:                     position.Direction != "short",
+		Quantity:                  quantity,
+		OrderPrice:                price,
+		MarkPrice:                 math.LegacyMustNewDecFromStr(position.MarkPrice),
+		PositionPayout:            result.EffectiveMargin,
+		LiquidatorRewardShareRate: s.profit.liquidatorRewardShareRate,
+		TradingFeeRate: profitability.DiscountedFeeRate(
+			math.LegacyMustNewDecFromStr(market.TakerFeeRate.String()),
+			s.profit.takerDiscountRate,
+		),
+		GasCost: gasCost,
+	}), nil
+}
+
+// isProfitable checks the estimate against the minimum profit configured for the market.
+func (s *liquidatorSvc) isProfitable(estimate profitability.Estimate, market core.DerivativeMarket) bool {
+	minProfit, enabled := s.minProfitForMarket(market.Id)
+	if !enabled {
+		return true
+	}
+
+	return estimate.Profit.GTE(math.LegacyMustNewDecFromStr(minProfit.Shift(market.QuoteToken.Decimals).String()))
+}
+
+// minProfitForMarket returns the minimum profit configured for the market, or the default one. The gate
+// is disabled when none is configured.
+func (s *liquidatorSvc) minProfitForMarket(marketID string) (decimal.Decimal, bool) {
+	if minProfit, found := s.marketMinProfit[marketID]; found {
+		return minProfit, true
+	}
+	return s.minProfit.Decimal, s.minProfit.Valid
+}
+
+// refreshFeeParams loads the liquidator reward share and the fee discount of the account paying the orders.
+func (s *liquidatorSvc) refreshFeeParams(ctx context.Context) error {
+	if time.Since(s.profit.feeParamsUpdatedAt) < feeParamsRefreshInterval {
+		return nil
+	}
+
+	queryClient := exchangetypes.NewQueryClient(s.chainClient.QueryClient())
+	paramsResp, err := queryClient.QueryExchangeParams(ctx, &exchangetypes.QueryExchangeParamsRequest{})
+	if err != nil {
+		return errors.Wrap(err, "failed to query the exchange params")
+	}
+
+	takerDiscountRate := math.LegacyZeroDec()
+	feeDiscountResp, err := s.chainClient.FetchFeeDiscountAccountInfo(ctx, s.tradingAccountAddress())
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch the fee discount account info")
+	}
+	if feeDiscountResp.AccountInfo != nil && !feeDiscountResp.AccountInfo.TakerDiscountRate.IsNil() {
+		takerDiscountRate = feeDiscountResp.AccountInfo.TakerDiscountRate
+	}
+
+	s.profit.liquidatorRewardShareRate = paramsResp.Params.LiquidatorRewardShareRate
+	s.profit.takerDiscountRate = takerDiscountRate
+	s.profit.feeParamsUpdatedAt = time.Now()
+
+	s.logger.Infof("Using liquidator reward share rate %s and fee tier %d (taker discount %s)",
+		s.profit.liquidatorRewardShareRate.String(), feeDiscountResp.TierLevel, takerDiscountRate.String())
+
+	return nil
+}
+
+// gasCostInQuote values the estimated gas of a liquidation in the market quote asset (chain format).
+func (s *liquidatorSvc) gasCostInQuote(ctx context.Context, market core.DerivativeMarket) (math.LegacyDec, error) {
+	if s.profitSettings.FeeTokenMarketID == "" {
+		return math.LegacyZeroDec(), nil
+	}
+
+	if time.Since(s.profit.feeTokenPriceUpdatedAt) > feeTokenPriceRefreshInterval {
+		spotMarket, found := s.marketsAssistant.AllSpotMarkets()[s.profitSettings.FeeTokenMarketID]
+		if !found {
+			return math.LegacyDec{}, errors.Errorf("fee token spot market %s not found", s.profitSettings.FeeTokenMarketID)
+		}
+
+		resp, err := s.chainClient.FetchSpotMidPriceAndTOB(ctx, spotMarket.Id)
+		if err != nil {
+			return math.LegacyDec{}, errors.Wrap(err, "failed to fetch the fee token price")
+		}
+		if resp.MidPrice == nil || resp.MidPrice.IsNil() {
+			return math.LegacyDec{}, errors.Errorf("fee token spot market %s has no mid price", spotMarket.Id)
+		}
+
+		s.profit.feeTokenPrice = math.LegacyMustNewDecFromStr(spotMarket.PriceFromChainFormat(*resp.MidPrice).String())
+		s.profit.feeTokenDecimals = int64(spotMarket.BaseToken.Decimals)
+		s.profit.feeTokenPriceUpdatedAt = time.Now()
+	}
+
+	return profitability.GasCostInQuote(
+		s.profitSettings.EstimatedGas,
+		math.LegacyNewDec(client.DefaultGasPrice),
+		s.profit.feeTokenPrice,
+		s.profit.feeTokenDecimals,
+		int64(market.QuoteToken.Decimals),
+	), nil
+}
+
+// tradingAccountAddress returns the address of the account owning the subaccount used for the liquidation orders.
+func (s *liquidatorSvc) tradingAccountAddress() string {
+	if s.granterPublicAddress != "" {
+		return s.granterPublicAddress
+	}
+	return s.chainClient.FromAddress().String()
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/metrics"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/eligibility"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/pricing"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/profitability"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/scoring"
+	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+)
+
+// candidate is a position confirmed liquidable, with its liquidation order priced and scored.
+type candidate struct {
+	position *derivativeExchangePB.DerivativePosition
+	market   core.DerivativeMarket
+	result   eligibility.Result
+	policy   pricing.Policy
+	price    math.LegacyDec
+	// quantity is the order quantity allowed by the market limits, before the collateral check
+	quantity math.LegacyDec
+	estimate profitability.Estimate
+	score    math.LegacyDec
+}
+
+// rankCandidates confirms, prices and scores the liquidable positions, and returns them by descending score.
+func (s *liquidatorSvc) rankCandidates(
+	ctx context.Context,
+	positions []*derivativeExchangePB.DerivativePosition,
+	marketsByID map[string]core.DerivativeMarket,
+) []candidate {
+	now := time.Now()
+	marketStates := make(map[string]eligibility.MarketState)
+	liquidableSince := make(map[string]time.Time, len(positions))
+	var candidates []candidate
+
+	for _, position := range positions {
+		if ctx.Err() != nil {
+			break
+		}
+
+		market, found := marketsByID[position.MarketId]
+		if !found {
+			s.logger.Warningf("Skipping position %s from unknown market", position.String())
+			continue
+		}
+
+		key := positionKey(position)
+		if s.pipeline.isInFlight(key) {
+			if firstSeen, seen := s.liquidableSince[key]; seen {
+				liquidableSince[key] = firstSeen
+			}
+			s.reportSkipped(market.Id, "in_flight")
+			s.logger.Debugf("Skipping position %s with a liquidation in flight", position.String())
+			continue
+		}
+		if state, suppressed := s.suppressedAttempt(position); suppressed {
+			if firstSeen, seen := s.liquidableSince[key]; seen {
+				liquidableSince[key] = firstSeen
+			}
+			s.reportSkipped(market.Id, string(state))
+			s.logger.Debugf("Skipping position %s whose previous liquidation attempt is %s", position.String(), state)
+			continue
+		}
+
+		result, err := s.confirmEligibility(ctx, position, marketStates)
+		if err != nil {
+			metrics.ReportClosureFuncError("ConfirmEligibility", marketTags(s.svcTags, market.Id))
+			s.logger.WithError(err).Warningf("Failed to confirm the eligibility of position %s", position.String())
+			continue
+		}
+		if !result.IsLiquidable {
+			s.reportSkipped(market.Id, "not_liquidable")
+			s.logger.Infof("Skipping position %s that is not liquidable in chain (liquidation price %s, distance %s)",
+				position.String(), result.LiquidationPrice.String(), result.DistanceToLiquidation.String())
+			continue
+		}
+
+		firstSeen, seen := s.liquidableSince[key]
+		if !seen {
+			firstSeen = now
+		}
+		liquidableSince[key] = firstSeen
+
+		policy := s.pricingForMarket(market.Id)
+		price, err := policy.Price(ctx, pricing.Request{
+			MarketID:        market.Id,
+			IsBuy:           position.Direction != "short",
+			MarkPrice:       math.LegacyMustNewDecFromStr(position.MarkPrice),
+			BankruptcyPrice: result.BankruptcyPrice,
+		})
+		if err != nil {
+			metrics.ReportClosureFuncError("PriceLiquidationOrder", marketTags(s.svcTags, market.Id))
+			s.logger.WithError(err).Warningf("Failed to price the liquidation order for position %s with policy %s", position.String(), policy.String())
+			continue
+		}
+
+		quantity := s.orderQuantity(position, market, price)
+		estimate, err := s.estimateProfit(ctx, position, market, result, price, quantity)
+		if err != nil {
+			metrics.ReportClosureFuncError("EstimateProfit", marketTags(s.svcTags, market.Id))
+			s.logger.WithError(err).Warningf("Failed to estimate the liquidation profit for position %s", position.String())
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			position: position,
+			market:   market,
+			result:   result,
+			policy:   policy,
+			price:    price,
+			quantity: quantity,
+			estimate: estimate,
+			score: s.scoringWeights.Score(scoring.Inputs{
+				Profit:          humanDec(market.MarginFromChainFormat(estimate.Profit)),
+				Notional:        humanDec(market.MarginFromChainFormat(quantity.Mul(price))),
+				MarginShortfall: humanDec(market.MarginFromChainFormat(result.MarginShortfall())),
+				Age:             now.Sub(firstSeen),
+			}),
+		})
+	}
+
+	// the positions not liquidable anymore leave the liquidable set
+	s.liquidableSince = liquidableSince
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score.GT(candidates[j].score)
+	})
+
+	return candidates
+}
+
+func positionKey(position *derivativeExchangePB.DerivativePosition) string {
+	return position.MarketId + "/" + position.SubaccountId
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/inventory"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/pricing"
+)
+
+// Parameters are the liquidation settings that can be reloaded while the service runs.
+type Parameters struct {
+	MarketIDs      []string
+	DefaultLimits  MarketLimits
+	MarketLimits   map[string]MarketLimits
+	DefaultPricing pricing.Policy
+	MarketPricing  map[string]pricing.Policy
+	// MinProfit is the minimum expected profit (in quote asset) for a liquidation to be broadcast, the
+	// profitability gate being disabled when not valid
+	MinProfit decimal.NullDecimal
+	// MarketMinProfit overrides the minimum profit by market
+	MarketMinProfit map[string]decimal.Decimal
+	// ExposureLimits are the limits of the inventory, shared by the services
+	ExposureLimits inventory.Limits
+}
+
+// Reload validates the parameters and hands them to the service loop, that applies them between two
+// iterations. Invalid parameters are rejected and the current ones stay active.
+func (s *liquidatorSvc) Reload(params Parameters) error {
+	if _, err := ResolveMarkets(s.marketsAssistant, params.MarketIDs); err != nil {
+		return errors.Wrap(err, "invalid market IDs")
+	}
+	if params.DefaultPricing == nil {
+		return errors.New("no default pricing policy")
+	}
+
+	s.pendingParams.Store(&params)
+	select {
+	case s.reloads <- struct{}{}:
+	default:
+		// a reload is already signalled, it applies the latest parameters
+	}
+	return nil
+}
+
+// parameters returns the active parameters.
+func (s *liquidatorSvc) parameters() Parameters {
+	return Parameters{
+		MarketIDs:       s.marketIDs,
+		DefaultLimits:   s.defaultLimits,
+		MarketLimits:    s.marketLimits,
+		DefaultPricing:  s.defaultPricing,
+		MarketPricing:   s.marketPricing,
+		MinProfit:       s.minProfit,
+		MarketMinProfit: s.marketMinProfit,
+		ExposureLimits:  s.inventoryLimits(),
+	}
+}
+
+func (s *liquidatorSvc) inventoryLimits() inventory.Limits {
+	if s.inventory == nil {
+		return inventory.Limits{}
+	}
+	return s.inventory.Limits()
+}
+
+// applyParameters swaps the active parameters for the pending ones and logs the changes. It returns the
+// markets to check, and whether they changed. Only used by the service loop.
+func (s *liquidatorSvc) applyParameters() ([]core.DerivativeMarket, bool, error) {
+	params := s.pendingParams.Swap(nil)
+	if params == nil {
+		return nil, false, nil
+	}
+
+	markets, err := ResolveMarkets(s.marketsAssistant, params.MarketIDs)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "invalid market IDs")
+	}
+
+	changes := s.parameters().diff(*params)
+	if len(changes) == 0 {
+		s.logger.Infoln("Parameters reloaded without changes")
+		return markets, false, nil
+	}
+
+	marketsChanged := strings.Join(s.marketIDs, ",") != strings.Join(params.MarketIDs, ",")
+	s.marketIDs = params.MarketIDs
+	s.defaultLimits = params.DefaultLimits
+	s.marketLimits = params.MarketLimits
+	s.defaultPricing = params.DefaultPricing
+	s.marketPricing = params.MarketPricing
+	s.minProfit = params.MinProfit
+	s.marketMinProfit = params.MarketMinProfit
+	if s.inventory != nil {
+		s.inventory.SetLimits(params.ExposureLimits)
+	}
+
+	for _, change := range changes {
+		s.logger.WithField("change", change).Infoln("Parameter changed")
+	}
+	s.logger.Infof("Parameters reloaded with %d changes", len(changes))
+
+	return markets, marketsChanged, nil
+}
+
+// diff describes the changes from p to next, the per market settings being compared with their defaults
+// applied.
+func (p Parameters) diff(next Parameters) []string {
+	var changes []string
+	changed := func(name, from, to string) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", name, from, to))
+		}
+	}
+
+	changed("market IDs", strings.Join(p.MarketIDs, ","), strings.Join(next.MarketIDs, ","))
+	changed("max order amount", FormatLimit(p.DefaultLimits.MaxOrderAmount), FormatLimit(next.DefaultLimits.MaxOrderAmount))
+	changed("max order notional", FormatLimit(p.DefaultLimits.MaxOrderNotional), FormatLimit(next.DefaultLimits.MaxOrderNotional))
+	changed("pricing policy", formatPolicy(p.DefaultPricing), formatPolicy(next.DefaultPricing))
+	changed("min profit", formatMinProfit(p.MinProfit), formatMinProfit(next.MinProfit))
+	changed("max market exposure", formatExposureLimit(p.ExposureLimits.MaxMarketExposure), formatExposureLimit(next.ExposureLimits.MaxMarketExposure))
+	changed("max total exposure", formatExposureLimit(p.ExposureLimits.MaxTotalExposure), formatExposureLimit(next.ExposureLimits.MaxTotalExposure))
+
+	marketIDs := make(map[string]struct{})
+	for _, settings := range []Parameters{p, next} {
+		for marketID := range settings.MarketLimits {
+			marketIDs[marketID] = struct{}{}
+		}
+		for marketID := range settings.MarketPricing {
+			marketIDs[marketID] = struct{}{}
+		}
+		for marketID := range settings.MarketMinProfit {
+			marketIDs[marketID] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(marketIDs))
+	for marketID := range marketIDs {
+		sorted = append(sorted, marketID)
+	}
+	sort.Strings(sorted)
+
+	for _, marketID := range sorted {
+		from, to := p.limitsForMarket(marketID), next.limitsForMarket(marketID)
+		changed("max order amount of "+marketID, FormatLimit(from.MaxOrderAmount), FormatLimit(to.MaxOrderAmount))
+		changed("max order notional of "+marketID, FormatLimit(from.MaxOrderNotional), FormatLimit(to.MaxOrderNotional))
+		changed("pricing policy of "+marketID, formatPolicy(p.pricingForMarket(marketID)), formatPolicy(next.pricingForMarket(marketID)))
+		changed("min profit of "+marketID, formatMinProfit(p.minProfitForMarket(marketID)), formatMinProfit(next.minProfitForMarket(marketID)))
+	}
+
+	return changes
+}
+
+func (p Parameters) limitsForMarket(marketID string) MarketLimits {
+	if limits, found := p.MarketLimits[marketID]; found {
+		return limits
+	}
+	return p.DefaultLimits
+}
+
+func (p Parameters) pricingForMarket(marketID string) pricing.Policy {
+	if policy, found := p.MarketPricing[marketID]; found {
+		return policy
+	}
+	return p.DefaultPricing
+}
+
+func (p Parameters) minProfitForMarket(marketID string) decimal.NullDecimal {
+	if minProfit, found := p.MarketMinProfit[marketID]; found {
+		return decimal.NullDecimal{Decimal: minProfit, Valid: true}
+	}
+	return p.MinProfit
+}
+
+// FormatLimit formats a sizing limit, the unset limits being the max sortable value.
+func FormatLimit(limit math.LegacyDec) string {
+	if limit.IsNil() || limit.GTE(math.LegacyMaxSortableDec) {
+		return "none"
+	}
+	return limit.String()
+}
+
+func formatPolicy(policy pricing.Policy) string {
+	if policy == nil {
+		return "none"
+	}
+	return policy.String()
+}
+
+func formatMinProfit(minProfit decimal.NullDecimal) string {
+	if !minProfit.Valid {
+		return "none"
+	}
+	return minProfit.Decimal.String()
+}
+
+// formatExposureLimit formats an exposure limit, the zero limits not being enforced.
+func formatExposureLimit(limit math.LegacyDec) string {
+	if limit.IsNil() || !limit.IsPositive() {
+		return "none"
+	}
+	return limit.String()
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+var expectedSequenceRegexp = regexp.MustCompile(`account sequence mismatch, expected (\d+)`)
+
+// accountSequence is the sequence of the account signing the transactions. It is managed locally, so several
+// transactions can wait for their confirmation while the next ones are signed.
+type accountSequence struct {
+	mu          sync.Mutex
+	initialized bool
+	accNum      uint64
+	seq         uint64
+}
+
+// broadcastTx signs the messages with the next sequence of the signer account and broadcasts the transaction
+// without waiting for its inclusion. The gas limit is estimated by simulation when zero. The transactions of
+// an account are signed one at a time, and its sequence is resynced when the chain reports a mismatch.
+func (sg *signer) broadcastTx(gasLimit uint64, msgs ...sdktypes.Msg) (*txtypes.BroadcastTxResponse, error) {
+	sg.sequence.mu.Lock()
+	defer sg.sequence.mu.Unlock()
+
+	sg.initSequence()
+
+	res, err := sg.signAndBroadcast(gasLimit, msgs...)
+	if isSequenceMismatch(res, err) {
+		if syncErr := sg.resyncSequence(res, err); syncErr != nil {
+			return nil, errors.Wrap(syncErr, "failed to sync the account sequence")
+		}
+		res, err = sg.signAndBroadcast(gasLimit, msgs...)
+	}
+
+	if err == nil && res.TxResponse != nil && res.TxResponse.Code == 0 {
+		sg.sequence.seq++
+	}
+
+	return res, err
+}
+
+// simulateTx simulates the messages in a transaction with the next sequence of the signer account. The chain
+// client simulation uses the client own sequence, which the transactions signed here do not advance. The
+// simulation is retried with the sequence expected by the node when it does not match.
+func (sg *signer) simulateTx(msgs ...sdktypes.Msg) (*txtypes.SimulateResponse, error) {
+	sg.sequence.mu.Lock()
+	sg.initSequence()
+	accNum, seq := sg.sequence.accNum, sg.sequence.seq
+	sg.sequence.mu.Unlock()
+
+	res, err := sg.simulate(accNum, seq, msgs...)
+	if err != nil && strings.Contains(err.Error(), "account sequence mismatch") {
+		if expected, found := expectedSequence(err.Error()); found {
+			res, err = sg.simulate(accNum, expected, msgs...)
+		}
+	}
+
+	return res, err
+}
+
+func (sg *signer) simulate(accNum, seq uint64, msgs ...sdktypes.Msg) (*txtypes.SimulateResponse, error) {
+	clientCtx := sg.client.ClientContext()
+	txf := chainclient.NewTxFactory(clientCtx).WithAccountNumber(accNum).WithSequence(seq)
+
+	txBytes, err := txf.BuildSimTx(msgs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build the simulation transaction")
+	}
+
+	return txtypes.NewServiceClient(sg.client.QueryClient()).Simulate(context.Background(), &txtypes.SimulateRequest{TxBytes: txBytes})
+}
+
+func (sg *signer) initSequence() {
+	if !sg.sequence.initialized {
+		sg.sequence.accNum, sg.sequence.seq = sg.client.GetAccNonce()
+		sg.sequence.initialized = true
+	}
+}
+
+func (sg *signer) signAndBroadcast(gasLimit uint64, msgs ...sdktypes.Msg) (*txtypes.BroadcastTxResponse, error) {
+	clientCtx := sg.client.ClientContext().WithSimulation(gasLimit == 0)
+
+	txBytes, err := sg.client.BuildSignedTx(clientCtx, sg.sequence.accNum, sg.sequence.seq, gasLimit, msgs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build the signed transaction")
+	}
+
+	return sg.client.AsyncBroadcastSignedTx(txBytes)
+}
+
+// resyncSequence sets the sequence expected by the chain. The mismatch error includes it, counting the
+// transactions still in the mempool, otherwise the sequence of the account in the last block is used.
+func (sg *signer) resyncSequence(res *txtypes.BroadcastTxResponse, err error) error {
+	mismatch := ""
+	if err != nil {
+		mismatch = err.Error()
+	} else {
+		mismatch = res.TxResponse.RawLog
+	}
+
+	if seq, found := expectedSequence(mismatch); found {
+		sg.logger.Debugf("Account sequence resynced from %d to %d", sg.sequence.seq, seq)
+		sg.sequence.seq = seq
+		return nil
+	}
+
+	clientCtx := sg.client.ClientContext()
+	accNum, seq, err := clientCtx.AccountRetriever.GetAccountNumberSequence(clientCtx, clientCtx.GetFromAddress())
+	if err != nil {
+		return err
+	}
+	sg.logger.Debugf("Account sequence resynced from %d to %d", sg.sequence.seq, seq)
+	sg.sequence.accNum, sg.sequence.seq = accNum, seq
+
+	return nil
+}
+
+func isSequenceMismatch(res *txtypes.BroadcastTxResponse, err error) bool {
+	if err != nil {
+		return strings.Contains(err.Error(), "account sequence mismatch")
+	}
+	return res != nil && res.TxResponse != nil && strings.Contains(res.TxResponse.RawLog, "account sequence mismatch")
+}
+
+// expectedSequence returns the sequence expected by the chain in an account sequence mismatch error.
+func expectedSequence(mismatch string) (uint64, bool) {
+	matches := expectedSequenceRegexp.FindStringSubmatch(mismatch)
+	if len(matches) < 2 {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(matches[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
@@ -3,13 +3,15 @@ package service
 import (
 	"context"
 	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"cosmossdk.io/math"
 
 	"github.com/InjectiveLabs/sdk-go/client/core"
 	"github.com/InjectiveLabs/sdk-go/client/exchange"
-	"github.com/cosmos/cosmos-sdk/x/authz"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
@@ -17,30 +19,111 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/xlab/suplog"
 
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/dedup"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/eligibility"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/inventory"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/pricing"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/scoring"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/telemetry"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/txtracker"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/unwind"
 	"github.com/InjectiveLabs/metrics"
 	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
 	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
 	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
 	metaPB "github.com/InjectiveLabs/sdk-go/exchange/meta_rpc/pb"
-	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
 	sdktypes "github.com/cosmos/cosmos-sdk/types"
 )
 
+// AllMarkets is the market ID wildcard that selects every active perpetual and expiry market.
+const AllMarkets = "all"
+
+const svcName = "liquidator_bot"
+
 type Service interface {
-	Start() error
+	// Start runs the service until ctx is cancelled
+	Start(ctx context.Context) error
+	// Close waits for the in-flight liquidations (up to the drain timeout) and reports the run summary
 	Close()
+	// Reload replaces the liquidation parameters between two iterations of the service loop
+	Reload(params Parameters) error
+	// Status returns a snapshot of the service state
+	Status() Status
+}
+
+// MarketLimits holds the sizing limits applied to the liquidation orders of a market.
+type MarketLimits struct {
+	MaxOrderAmount   math.LegacyDec
+	MaxOrderNotional math.LegacyDec
+}
+
+// Config is the liquidation configuration of a service. The services liquidating with different accounts
+// have their own configuration.
+type Config struct {
+	Parameters
+	SubaccountID         common.Hash
+	GranterPublicAddress string
+	GranterSubaccountID  common.Hash
+	Profit               ProfitSettings
+	Batch                BatchSettings
+	DrainTimeout         time.Duration
+	Simulation           SimulationSettings
+	Unwind               UnwindSettings
+	Hedge                HedgeSettings
+	ScoringWeights       scoring.Weights
+	Signer               SignerSettings
+	Grant                GrantSettings
 }
 
 type liquidatorSvc struct {
 	chainClient          chainclient.ChainClient
 	exchangeClient       exchange.ExchangeClient
 	marketsAssistant     chainclient.MarketsAssistant
-	marketID             string
+	detector             Detector
+	marketIDs            []string
 	subaccountID         common.Hash
 	granterPublicAddress string
 	granterSubaccountID  common.Hash
-	maxOrderAmount       math.LegacyDec
-	maxOrderNotional     math.LegacyDec
+	defaultLimits        MarketLimits
+	marketLimits         map[string]MarketLimits
+	defaultPricing       pricing.Policy
+	marketPricing        map[string]pricing.Policy
+	profitSettings       ProfitSettings
+	minProfit            decimal.NullDecimal
+	marketMinProfit      map[string]decimal.Decimal
+	batchSettings        BatchSettings
+	drainTimeout         time.Duration
+	txTracker            *txtracker.Tracker
+	simulationSettings   SimulationSettings
+	dryRunRecorder       *DryRunRecorder
+	inventory            *inventory.Inventory
+	unwindSettings       UnwindSettings
+	hedgeSettings        HedgeSettings
+	scoringWeights       scoring.Weights
+	dedup                *dedup.Cache
+	profit               profitState
+
+	// liquidableSince is when each position of the liquidable set was first seen, only used by the service loop
+	liquidableSince map[string]time.Time
+	// unwindOrders are the resting unwind limit orders by market, only used by the service loop
+	unwindOrders map[string]unwind.Order
+
+	// signers are the keys signing the transactions, by default the chain client key only
+	signers        *SignerPool
+	signersOnce    sync.Once
+	signerSettings SignerSettings
+
+	grantSettings GrantSettings
+
+	// pendingParams are the reloaded parameters not applied yet, signalled on reloads
+	pendingParams atomic.Pointer[Parameters]
+	reloads       chan struct{}
+
+	pipeline txPipeline
+	started  atomic.Bool
+	stopped  chan struct{}
+	summary  runSummary
+	status   statusState
 
 	logger  log.Logger
 	svcTags metrics.Tags
@@ -50,76 +133,452 @@ func NewService(
 	chainClient chainclient.ChainClient,
 	exchangeClient exchange.ExchangeClient,
 	marketsAssistant chainclient.MarketsAssistant,
-	marketID string,
-	subaccountID common.Hash,
-	granterPublicAddress string,
-	granterSubaccountID common.Hash,
-	maxOrderAmount math.LegacyDec,
-	maxOrderNotional math.LegacyDec,
-
+	detector Detector,
+	txTracker *txtracker.Tracker,
+	dryRunRecorder *DryRunRecorder,
+	inventory *inventory.Inventory,
+	dedup *dedup.Cache,
+	signers *SignerPool,
+	config Config,
 ) Service {
 	return &liquidatorSvc{
 		logger: log.WithField("svc", "liquidator"),
 		svcTags: metrics.Tags{
-			"svc": "liquidator_bot",
+			"svc": svcName,
 		},
 
 		chainClient:          chainClient,
 		exchangeClient:       exchangeClient,
 		marketsAssistant:     marketsAssistant,
-		marketID:             marketID,
-		subaccountID:         subaccountID,
-		granterPublicAddress: granterPublicAddress,
-		granterSubaccountID:  granterSubaccountID,
-		maxOrderAmount:       maxOrderAmount,
-		maxOrderNotional:     maxOrderNotional,
+		detector:             detector,
+		marketIDs:            config.MarketIDs,
+		subaccountID:         config.SubaccountID,
+		granterPublicAddress: config.GranterPublicAddress,
+		granterSubaccountID:  config.GranterSubaccountID,
+		defaultLimits:        config.DefaultLimits,
+		marketLimits:         config.MarketLimits,
+		defaultPricing:       config.DefaultPricing,
+		marketPricing:        config.MarketPricing,
+		profitSettings:       config.Profit,
+		minProfit:            config.MinProfit,
+		marketMinProfit:      config.MarketMinProfit,
+		batchSettings:        config.Batch,
+		drainTimeout:         config.DrainTimeout,
+		txTracker:            txTracker,
+		simulationSettings:   config.Simulation,
+		dryRunRecorder:       dryRunRecorder,
+		inventory:            inventory,
+		unwindSettings:       config.Unwind,
+		hedgeSettings:        config.Hedge,
+		scoringWeights:       config.ScoringWeights,
+		dedup:                dedup,
+		signers:              signers,
+		signerSettings:       config.Signer,
+		grantSettings:        config.Grant,
+		unwindOrders:         make(map[string]unwind.Order),
+		reloads:              make(chan struct{}, 1),
+		stopped:              make(chan struct{}),
 	}
 }
 
-func (s *liquidatorSvc) Start() (err error) {
+func (s *liquidatorSvc) Start(ctx context.Context) (err error) {
+	s.started.Store(true)
+	defer close(s.stopped)
 	defer s.panicRecover(&err)
+	// the service stops once the outcome of the broadcast liquidations is known
+	defer s.pipeline.wait()
 
 	s.logger.Infoln("Service starts")
 
-	// main bot loop
-
-	ctx := context.Background()
 	resp, err := s.exchangeClient.GetVersion(ctx, &metaPB.VersionRequest{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get the Exchange API version")
+	}
 	s.logger.Infof("Connected to Exchange API %s (build %s)", resp.Version, resp.Build["BuildDate"])
 
+	markets, err := s.resolveMarkets()
+	if err != nil {
+		return err
+	}
+	s.logger.Infof("Checking liquidations for %d markets", len(markets))
+	s.status.setMarkets(s.marketStatuses(markets))
+
+	marketsByID := make(map[string]core.DerivativeMarket, len(markets))
+	for _, market := range markets {
+		marketsByID[market.Id] = market
+	}
+
+	// a missing grant would only show up as failed liquidations, so an unchecked one stops the service too
+	if err := s.checkGrants(ctx); err != nil {
+		return errors.Wrap(err, "refusing to start")
+	}
+	s.status.setGrantsValid(true)
+
+	candidates := make(chan []*derivativeExchangePB.DerivativePosition)
+	detectorErr := make(chan error, 1)
+	runDetector := func(markets []core.DerivativeMarket) context.CancelFunc {
+		detectorCtx, cancelDetector := context.WithCancel(ctx)
+		go func() {
+			detectorErr <- s.detector.Run(detectorCtx, markets, candidates)
+		}()
+		return cancelDetector
+	}
+	cancelDetector := runDetector(markets)
+	defer func() {
+		cancelDetector()
+	}()
+
+	var unwindTicks <-chan time.Time
+	if s.unwindSettings.Policy != nil {
+		s.logger.Infof("Unwinding the liquidated positions with policy %s", s.unwindSettings.Policy.String())
+		unwindTicker := time.NewTicker(s.unwindSettings.Interval)
+		defer unwindTicker.Stop()
+		unwindTicks = unwindTicker.C
+	}
+
+	if signers := s.signerPool().signers; len(signers) > 1 {
+		s.logger.Infof("Signing the transactions with %d keys selected by %s", len(signers), s.signerSettings.Selection)
+	}
+
+	var grantTicks <-chan time.Time
+	if s.granterPublicAddress != "" && s.grantSettings.CheckInterval > 0 {
+		grantTicker := time.NewTicker(s.grantSettings.CheckInterval)
+		defer grantTicker.Stop()
+		grantTicks = grantTicker.C
+	}
+
+	var balanceTicks <-chan time.Time
+	if !s.signerSettings.MinBalance.IsNil() && s.signerSettings.MinBalance.IsPositive() {
+		s.checkSignerBalances(ctx)
+		balanceTicker := time.NewTicker(s.signerSettings.BalanceCheckInterval)
+		defer balanceTicker.Stop()
+		balanceTicks = balanceTicker.C
+	}
+
+	// main bot loop
 	for {
-		req := derivativeExchangePB.LiquidablePositionsRequest{
-			MarketId: s.marketID,
+		select {
+		case positions := <-candidates:
+			if len(positions) == 0 {
+				s.status.checked(time.Now())
+				continue
+			}
+			start := time.Now()
+			s.liquidatePositions(ctx, positions, marketsByID)
+			s.status.cycled(CycleStatus{Start: start, Duration: time.Since(start), Candidates: len(positions)})
+		case <-balanceTicks:
+			s.checkSignerBalances(ctx)
+		case <-grantTicks:
+			// the bot keeps running, the grants may be renewed while it waits
+			err := s.checkGrants(ctx)
+			if errors.Is(err, errGrantsUnchecked) {
+				// the failed queries are warned, the grants keep their last known state
+				continue
+			}
+			if err != nil {
+				s.logger.WithError(err).Errorln("Grants check failed")
+			}
+			s.status.setGrantsValid(err == nil)
+		case <-unwindTicks:
+			s.unwindInventory(ctx, marketsByID)
+		case <-s.reloads:
+			reloaded, marketsChanged, err := s.applyParameters()
+			if err != nil {
+				s.logger.WithError(err).Errorln("Reloaded parameters rejected, the current ones stay active")
+				continue
+			}
+			if reloaded != nil {
+				s.status.setMarkets(s.marketStatuses(reloaded))
+			}
+			if !marketsChanged {
+				continue
+			}
+
+			// the markets dropped stay known to unwind their inventory
+			for _, market := range reloaded {
+				marketsByID[market.Id] = market
+			}
+			cancelDetector()
+			if err := <-detectorErr; err != nil && ctx.Err() == nil {
+				s.logger.WithError(err).Warningln("Detector failed while restarting for the reloaded markets")
+			}
+			cancelDetector = runDetector(reloaded)
+			s.logger.Infof("Checking liquidations for %d markets", len(reloaded))
+		case err := <-detectorErr:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "liquidable positions detector stopped")
+		case <-ctx.Done():
+			s.logger.Infoln("Service stops")
+			return nil
 		}
-		resp, err := s.exchangeClient.GetDerivativeLiquidablePositions(ctx, &req)
-		metrics.ReportClosureFuncCall("LiquidablePositions", s.svcTags)
+	}
+}
 
-		if err != nil {
-			metrics.ReportClosureFuncError("LiquidablePositions", s.svcTags)
-			s.logger.Warning("Failed to get liquidable positions")
+// resolveMarkets returns the derivative markets of the configured market IDs.
+func (s *liquidatorSvc) resolveMarkets() ([]core.DerivativeMarket, error) {
+	return ResolveMarkets(s.marketsAssistant, s.marketIDs)
+}
+
+// ResolveMarkets expands the market IDs (or the AllMarkets wildcard) into the derivative markets known
+// by the markets assistant.
+func ResolveMarkets(marketsAssistant chainclient.MarketsAssistant, marketIDs []string) ([]core.DerivativeMarket, error) {
+	allMarkets := marketsAssistant.AllDerivativeMarkets()
+
+	var markets []core.DerivativeMarket
+	for _, marketID := range marketIDs {
+		if marketID == AllMarkets {
+			markets = markets[:0]
+			for _, market := range allMarkets {
+				if market.Status == "active" {
+					markets = append(markets, market)
+				}
+			}
+			sort.Slice(markets, func(i, j int) bool {
+				return markets[i].Id < markets[j].Id
+			})
+			break
+		}
+
+		market, found := allMarkets[marketID]
+		if !found {
+			return nil, errors.Errorf("derivative market %s not found", marketID)
+		}
+		markets = append(markets, market)
+	}
+
+	if len(markets) == 0 {
+		return nil, errors.New("no derivative markets configured")
+	}
+
+	return markets, nil
+}
+
+func (s *liquidatorSvc) liquidatePositions(
+	ctx context.Context,
+	positions []*derivativeExchangePB.DerivativePosition,
+	marketsByID map[string]core.DerivativeMarket,
+) {
+	if len(positions) == 0 {
+		return
+	}
+	detectedAt := time.Now()
+	for _, position := range positions {
+		telemetry.CandidatesSeen(position.MarketId, 1)
+	}
+
+	if s.dedup != nil {
+		s.dedup.Prune()
+	}
+
+	balances, err := s.loadCollateral(ctx)
+	if err != nil {
+		metrics.ReportClosureFuncError("LoadCollateral", s.svcTags)
+		s.logger.WithError(err).Warningf("Skipping %d liquidation candidates, the trading subaccount deposits are not available", len(positions))
+		return
+	}
 
-			metrics.ReportClosureFuncTiming("LiquidablePositions", s.svcTags)
-			time.Sleep(10 * time.Second)
+	if err := s.syncInventory(ctx); err != nil {
+		metrics.ReportClosureFuncError("SyncInventory", s.svcTags)
+		s.logger.WithError(err).Warningln("Failed to sync the inventory, using the tracked positions")
+	}
+	// the liquidations of the round are added to a copy, the inventory is only updated once they are executed
+	roundInventory := s.inventory.Copy()
+	tradingSubaccountID := s.tradingSubaccountID().Hex()
+
+	// the liquidations still in flight keep their collateral and exposure until their outcome is known
+	for _, liquidation := range s.pipeline.inFlight() {
+		inventoryQuantity, inventoryPrice := inventoryChange(liquidation.market, liquidation.msg.Order)
+		roundInventory.Apply(tradingSubaccountID, liquidation.market.Id, inventoryQuantity, inventoryPrice)
+		if !liquidation.collateral.IsNil() {
+			balances.consume(liquidation.market.QuoteToken.Denom, liquidation.collateral)
+		}
+	}
+
+	var liquidations []pendingLiquidation
+
+	// the candidates are processed by descending score, so the collateral and the exposure limits go to the best ones first
+	for _, c := range s.rankCandidates(ctx, positions, marketsByID) {
+		if ctx.Err() != nil {
+			break
+		}
+
+		position, market, result, policy, price := c.position, c.market, c.result, c.policy, c.price
+		quantity := c.quantity
+		feeRate := math.LegacyMustNewDecFromStr(market.TakerFeeRate.String())
+		available := balances.available(market.QuoteToken.Denom)
+		fundable := fundableQuantity(quantity, price, feeRate, available, math.LegacyMustNewDecFromStr(market.MinQuantityTickSize.String()))
+		if !fundable.IsPositive() {
+			s.reportSkipped(market.Id, "insufficient_collateral")
+			s.logger.Warningf("Skipping position %s, the available balance of %s %s cannot margin the minimum order quantity",
+				position.String(), market.MarginFromChainFormat(available).String(), market.QuoteToken.Symbol)
 			continue
 		}
+		if fundable.LT(quantity) {
+			s.logger.Infof("Scaling down the liquidation order quantity of position %s from %s to %s to fit the available balance",
+				position.String(), quantity.String(), fundable.String())
+			quantity = fundable
+		}
 
-		positions := resp.Positions
-		market := s.marketsAssistant.AllDerivativeMarkets()[s.marketID]
+		liquidation := s.createPendingLiquidation(position, market, price, quantity)
 
-		if len(positions) > 0 {
-			for _, position := range positions {
-				msg := s.createLiquidationMessage(position, market)
+		inventoryQuantity, inventoryPrice := inventoryChange(market, liquidation.msg.Order)
+		if err := roundInventory.Check(tradingSubaccountID, market.Id, inventoryQuantity, inventoryPrice); err != nil {
+			s.reportSkipped(market.Id, "exposure_limit")
+			s.logger.WithError(err).Warningf("Skipping position %s, its liquidation would grow the %s inventory of %s beyond the exposure limits",
+				position.String(), market.Ticker, roundInventory.Position(tradingSubaccountID, market.Id).Quantity.String())
+			continue
+		}
 
-				if _, err := s.chainClient.SyncBroadcastMsg(msg); err != nil {
-					metrics.ReportClosureFuncError("SyncBroadcastMsg", s.svcTags)
-					s.logger.Errorf("Failed liquidating position %s with error %s", position.String(), err.Error())
-				}
+		estimate := c.estimate
+		if !quantity.Equal(c.quantity) {
+			if estimate, err = s.estimateProfit(ctx, position, market, result, price, quantity); err != nil {
+				metrics.ReportClosureFuncError("EstimateProfit", marketTags(s.svcTags, market.Id))
+				s.logger.WithError(err).Warningf("Failed to estimate the liquidation profit for position %s", position.String())
+				continue
+			}
+		}
+
+		decisionLog := s.logger.WithFields(log.Fields{
+			"market":           market.Ticker,
+			"subaccount":       position.SubaccountId,
+			"margin_ratio":     result.MarginRatio.String(),
+			"margin_shortfall": result.MarginShortfall().String(),
+			"liquidation":      result.LiquidationPrice.String(),
+			"bankruptcy":       result.BankruptcyPrice.String(),
+			"pricing":          policy.String(),
+			"price":            price.String(),
+			"quantity":         quantity.String(),
+			"collateral":       available.String(),
+			"inventory":        roundInventory.Position(tradingSubaccountID, market.Id).Quantity.String(),
+			"reward":           estimate.Reward.String(),
+			"fill_pnl":         estimate.FillPnl.String(),
+			"trading_fee":      estimate.TradingFee.String(),
+			"gas_cost":         estimate.GasCost.String(),
+			"expected_profit":  estimate.Profit.String(),
+			"score":            c.score.String(),
+			"scoring":          s.scoringWeights.String(),
+		})
+
+		if !s.isProfitable(estimate, market) {
+			s.reportSkipped(market.Id, "unprofitable")
+			minProfit, _ := s.minProfitForMarket(market.Id)
+			decisionLog.Infof("Skipping position below the minimum profit of %s %s", minProfit.String(), market.QuoteToken.Symbol)
+			continue
+		}
+
+		var simulation string
+		var simulatedGas uint64
+		if s.simulationSettings.Enabled {
+			gasUsed, gasLimit, err := s.simulateLiquidation(liquidation)
+			if err != nil {
+				result := txtracker.ResultFromError(err)
+				s.reportSkipped(market.Id, "simulation_"+string(result.Outcome))
+				decisionLog.WithFields(log.Fields{
+					"simulation": result.Outcome,
+					"revert":     result.Log,
+				}).Infoln("Skipping position whose liquidation fails in simulation")
+				continue
 			}
+
+			liquidation.gasLimit = gasLimit
+			simulation = string(txtracker.OutcomeSuccess)
+			simulatedGas = gasUsed
+			decisionLog = decisionLog.WithFields(log.Fields{
+				"simulation":    txtracker.OutcomeSuccess,
+				"simulated_gas": gasUsed,
+				"gas_limit":     gasLimit,
+			})
+		}
+
+		decisionLog.Infoln("Liquidating position")
+
+		liquidation.collateral = orderCollateral(quantity, price, feeRate)
+		liquidation.expectedProfit = estimate.Profit
+		liquidation.detectedAt = detectedAt
+		balances.consume(market.QuoteToken.Denom, liquidation.collateral)
+		roundInventory.Apply(tradingSubaccountID, market.Id, inventoryQuantity, inventoryPrice)
+
+		s.beginAttempts(liquidation)
+
+		if s.dryRunRecorder != nil {
+			s.recordDryRun(liquidation, policy.String(), estimate, simulation, simulatedGas)
+			// nothing is broadcast, the cool-down starts right away
+			s.resolveAttempts(liquidation)
+			continue
 		}
 
-		metrics.ReportClosureFuncTiming("LiquidablePositions", s.svcTags)
-		time.Sleep(10 * time.Second)
+		liquidations = append(liquidations, liquidation)
+	}
+
+	s.broadcastLiquidations(ctx, liquidations)
+}
+
+// confirmEligibility recomputes the position margin with the chain state instead of trusting the detector.
+// The candidate quantity and mark price are updated with the chain values. Positions already closed are
+// reported as not liquidable.
+func (s *liquidatorSvc) confirmEligibility(
+	ctx context.Context,
+	position *derivativeExchangePB.DerivativePosition,
+	marketStates map[string]eligibility.MarketState,
+) (eligibility.Result, error) {
+	marketState, found := marketStates[position.MarketId]
+	if !found {
+		marketResp, err := s.chainClient.FetchChainDerivativeMarket(ctx, position.MarketId)
+		if err != nil {
+			return eligibility.Result{}, errors.Wrap(err, "failed to fetch the chain market")
+		}
+		if marketResp.Market == nil || marketResp.Market.Market == nil {
+			return eligibility.Result{}, errors.Errorf("market %s not found in chain", position.MarketId)
+		}
+
+		marketState = eligibility.MarketStateFromChain(marketResp.Market)
+		marketStates[position.MarketId] = marketState
+	}
+
+	positionResp, err := s.chainClient.FetchChainSubaccountPositionInMarket(ctx, position.SubaccountId, position.MarketId)
+	if err != nil {
+		return eligibility.Result{}, errors.Wrap(err, "failed to fetch the chain position")
+	}
+	if positionResp.State == nil || positionResp.State.Quantity.IsNil() || !positionResp.State.Quantity.IsPositive() {
+		return eligibility.Result{}, nil
+	}
+
+	result := eligibility.Evaluate(eligibility.PositionFromChain(positionResp.State), marketState)
+
+	position.Quantity = positionResp.State.Quantity.String()
+	position.MarkPrice = marketState.MarkPrice.String()
+	position.LiquidationPrice = result.LiquidationPrice.String()
+
+	return result, nil
+}
+
+// reportSkipped counts a liquidation candidate discarded for the reason.
+func (s *liquidatorSvc) reportSkipped(marketID string, reason string) {
+	metrics.ReportClosureFuncStatus("SkippedLiquidation", marketTags(s.svcTags, marketID).With("reason", reason))
+	telemetry.LiquidationSkipped(marketID, reason)
+}
+
+// marketTags returns a copy of the tags extended with the market the metric refers to.
+func marketTags(tags metrics.Tags, marketID string) metrics.Tags {
+	marketTags := make(metrics.Tags, len(tags)+1)
+	for k, v := range tags {
+		marketTags[k] = v
 	}
+	marketTags["market_id"] = marketID
+
+	return marketTags
+}
+
+// pricingForMarket returns the pricing policy configured for the market, or the default one.
+func (s *liquidatorSvc) pricingForMarket(marketID string) pricing.Policy {
+	return s.parameters().pricingForMarket(marketID)
+}
+
+// limitsForMarket returns the sizing limits configured for the market, or the default ones.
+func (s *liquidatorSvc) limitsForMarket(marketID string) MarketLimits {
+	return s.parameters().limitsForMarket(marketID)
 }
 
 func (s *liquidatorSvc) panicRecover(err *error) {
@@ -136,33 +595,56 @@ func (s *liquidatorSvc) panicRecover(err *error) {
 }
 
 func (s *liquidatorSvc) Close() {
-	// graceful shutdown if needed
+	if s.started.Load() {
+		select {
+		case <-s.stopped:
+		case <-time.After(s.drainTimeout):
+			s.logger.Warningf("Drain timeout of %s elapsed with %d liquidations in flight, their state is unknown",
+				s.drainTimeout.String(), s.summary.inFlight.Load())
+		}
+	}
+
+	s.logger.WithFields(s.summary.fields()).Infoln("Service stopped")
+}
+
+// createLiquidationMessage builds the liquidation message for the position, with the liquidation order
+// priced at price (in chain format).
+func (s *liquidatorSvc) createLiquidationMessage(
+	position *derivativeExchangePB.DerivativePosition,
+	market core.DerivativeMarket,
+	price math.LegacyDec,
+) sdktypes.Msg {
+	liquidation := s.createPendingLiquidation(position, market, price, s.orderQuantity(position, market, price))
+	return s.createBatchMessages([]pendingLiquidation{liquidation})[0]
 }
 
-func (s *liquidatorSvc) createLiquidationMessage(position *derivativeExchangePB.DerivativePosition, market core.DerivativeMarket) sdktypes.Msg {
-	var msg sdktypes.Msg
+// createPendingLiquidation builds the liquidation of the position with an order of quantity (in chain format),
+// sent by the granter account when configured.
+func (s *liquidatorSvc) createPendingLiquidation(
+	position *derivativeExchangePB.DerivativePosition,
+	market core.DerivativeMarket,
+	price math.LegacyDec,
+	quantity math.LegacyDec,
+) pendingLiquidation {
+	var msg exchangetypes.MsgLiquidatePosition
 	if s.granterPublicAddress == "" {
-		liquidatePositionMessage := s.createLiquidatePositionMessage(position, market, s.chainClient.FromAddress().String(), s.subaccountID)
-		msg = &liquidatePositionMessage
+		msg = s.createLiquidatePositionMessage(position, market, price, quantity, s.chainClient.FromAddress().String(), s.subaccountID)
 	} else {
-		liquidatePositionMessage := s.createLiquidatePositionMessage(position, market, s.granterPublicAddress, s.granterSubaccountID)
-		liquidationMessageBytes, _ := liquidatePositionMessage.Marshal()
-		liquidationMsgAsAny := &codectypes.Any{
-			TypeUrl: sdktypes.MsgTypeURL(&liquidatePositionMessage),
-			Value:   liquidationMessageBytes,
-		}
-		msg = &authz.MsgExec{
-			Grantee: s.chainClient.FromAddress().String(),
-			Msgs:    []*codectypes.Any{liquidationMsgAsAny},
-		}
+		msg = s.createLiquidatePositionMessage(position, market, price, quantity, s.granterPublicAddress, s.granterSubaccountID)
 	}
 
-	return msg
+	return pendingLiquidation{
+		position: position,
+		market:   market,
+		msg:      msg,
+	}
 }
 
 func (s *liquidatorSvc) createLiquidatePositionMessage(
 	position *derivativeExchangePB.DerivativePosition,
 	market core.DerivativeMarket,
+	price math.LegacyDec,
+	orderAmount math.LegacyDec,
 	senderAddress string,
 	senderSubaccountID common.Hash,
 ) exchangetypes.MsgLiquidatePosition {
@@ -172,17 +654,12 @@ func (s *liquidatorSvc) createLiquidatePositionMessage(
 		orderType = exchangetypes.OrderType_SELL
 	}
 
-	candidateOrderAmountFromMaxNotional := s.maxOrderNotional.Quo(math.LegacyMustNewDecFromStr(position.MarkPrice))
-	fullLiquidationOrderAmount := math.LegacyMustNewDecFromStr(position.Quantity)
-	orderAmount := math.LegacyMinDec(candidateOrderAmountFromMaxNotional, fullLiquidationOrderAmount)
-	orderAmount = math.LegacyMinDec(orderAmount, s.maxOrderAmount)
-
 	order := s.chainClient.CreateDerivativeOrder(
 		senderSubaccountID,
 		&chainclient.DerivativeOrderData{
 			OrderType:    orderType,
 			Quantity:     market.QuantityFromChainFormat(orderAmount),
-			Price:        market.PriceFromChainFormat(math.LegacyMustNewDecFromStr(position.MarkPrice)),
+			Price:        market.PriceFromChainFormat(price),
 			Leverage:     decimal.RequireFromString("1"),
 			FeeRecipient: senderAddress,
 			MarketId:     market.Id,
@@ -200,3 +677,17 @@ func (s *liquidatorSvc) createLiquidatePositionMessage(
 
 	return msg
 }
+
+// orderQuantity returns the liquidation order quantity (in chain format), capped by the market limits.
+func (s *liquidatorSvc) orderQuantity(
+	position *derivativeExchangePB.DerivativePosition,
+	market core.DerivativeMarket,
+	price math.LegacyDec,
+) math.LegacyDec {
+	limits := s.limitsForMarket(market.Id)
+	candidateOrderAmountFromMaxNotional := limits.MaxOrderNotional.Quo(price)
+	fullLiquidationOrderAmount := math.LegacyMustNewDecFromStr(position.Quantity)
+	orderAmount := math.LegacyMinDec(candidateOrderAmountFromMaxNotional, fullLiquidationOrderAmount)
+
+	return math.LegacyMinDec(orderAmount, limits.MaxOrderAmount)
+}
@@ -1,20 +1,37 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
 	"cosmossdk.io/math"
 
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/dedup"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/eligibility"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/grants"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/inventory"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/pricing"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/profitability"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/txtracker"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/unwind"
+
 	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+	chainstreamtypes "github.com/InjectiveLabs/sdk-go/chain/stream/types"
 	"github.com/InjectiveLabs/sdk-go/client/chain"
+	"github.com/InjectiveLabs/sdk-go/client/core"
 	"github.com/InjectiveLabs/sdk-go/client/exchange"
 	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
 	spotExchangePB "github.com/InjectiveLabs/sdk-go/exchange/spot_exchange_rpc/pb"
 	"github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/authz"
 	eth "github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	log "github.com/xlab/suplog"
 )
 
 func TestLiquidatePositionMessageWhenNotUsingDelegatedAccount(t *testing.T) {
@@ -50,12 +67,14 @@ func TestLiquidatePositionMessageWhenNotUsingDelegatedAccount(t *testing.T) {
 		chainClient:          &mockChain,
 		exchangeClient:       &mockExchange,
 		marketsAssistant:     marketAssistant,
-		marketID:             btcUsdtDerivativeMarketInfo.MarketId,
+		marketIDs:            []string{btcUsdtDerivativeMarketInfo.MarketId},
 		subaccountID:         granteeSubaccountID,
 		granterPublicAddress: granterPublicAddress,
 		granterSubaccountID:  granterSubaccountID,
-		maxOrderAmount:       math.LegacyMaxSortableDec,
-		maxOrderNotional:     math.LegacyMaxSortableDec,
+		defaultLimits: MarketLimits{
+			MaxOrderAmount:   math.LegacyMaxSortableDec,
+			MaxOrderNotional: math.LegacyMaxSortableDec,
+		},
 	}
 
 	market := marketAssistant.AllDerivativeMarkets()[btcUsdtDerivativeMarketInfo.MarketId]
@@ -67,7 +86,7 @@ func TestLiquidatePositionMessageWhenNotUsingDelegatedAccount(t *testing.T) {
 		MarkPrice:    "3400000000",
 	}
 
-	message := liquidatorService.createLiquidationMessage(&position, market)
+	message := liquidatorService.createLiquidationMessage(&position, market, math.LegacyMustNewDecFromStr(position.MarkPrice))
 	liquidationMessage := message.(*exchangetypes.MsgLiquidatePosition)
 
 	assert.Equal(t, granteePublicAddress, liquidationMessage.Sender)
@@ -119,12 +138,14 @@ func TestLiquidatePositionMessageWhenUsingDelegatedAccount(t *testing.T) {
 		chainClient:          &mockChain,
 		exchangeClient:       &mockExchange,
 		marketsAssistant:     marketAssistant,
-		marketID:             btcUsdtDerivativeMarketInfo.MarketId,
+		marketIDs:            []string{btcUsdtDerivativeMarketInfo.MarketId},
 		subaccountID:         granteeSubaccountID,
 		granterPublicAddress: granterPublicAddress,
 		granterSubaccountID:  granterSubaccountID,
-		maxOrderAmount:       math.LegacyMaxSortableDec,
-		maxOrderNotional:     math.LegacyMaxSortableDec,
+		defaultLimits: MarketLimits{
+			MaxOrderAmount:   math.LegacyMaxSortableDec,
+			MaxOrderNotional: math.LegacyMaxSortableDec,
+		},
 	}
 
 	market := marketAssistant.AllDerivativeMarkets()[btcUsdtDerivativeMarketInfo.MarketId]
@@ -136,7 +157,7 @@ func TestLiquidatePositionMessageWhenUsingDelegatedAccount(t *testing.T) {
 		MarkPrice:    "3400000000",
 	}
 
-	message := liquidatorService.createLiquidationMessage(&position, market)
+	message := liquidatorService.createLiquidationMessage(&position, market, math.LegacyMustNewDecFromStr(position.MarkPrice))
 	execMessage := message.(*authz.MsgExec)
 
 	assert.Equal(t, granteePublicAddress, execMessage.Grantee)
@@ -162,3 +183,1253 @@ func TestLiquidatePositionMessageWhenUsingDelegatedAccount(t *testing.T) {
 		liquidationMessage.Order.GetMargin(),
 	)
 }
+
+func TestResolveMarketsWithMarketIDsAndWildcard(t *testing.T) {
+	mockExchange := exchange.MockExchangeClient{}
+
+	btcUsdtDerivativeMarketInfo := createBTCUSDTDerivativeMarketInfo()
+	ethUsdtDerivativeMarketInfo := createETHUSDTExpiryDerivativeMarketInfo()
+
+	mockExchange.SpotMarketsResponses = append(mockExchange.SpotMarketsResponses, &spotExchangePB.MarketsResponse{
+		Markets: []*spotExchangePB.SpotMarketInfo{},
+	})
+	mockExchange.DerivativeMarketsResponses = append(mockExchange.DerivativeMarketsResponses, &derivativeExchangePB.MarketsResponse{
+		Markets: []*derivativeExchangePB.DerivativeMarketInfo{btcUsdtDerivativeMarketInfo, ethUsdtDerivativeMarketInfo},
+	})
+
+	marketAssistant, err := chain.NewMarketsAssistantInitializedFromChain(context.Background(), &mockExchange)
+	assert.NoError(t, err)
+
+	liquidatorService := liquidatorSvc{
+		marketsAssistant: marketAssistant,
+		marketIDs:        []string{ethUsdtDerivativeMarketInfo.MarketId},
+	}
+
+	markets, err := liquidatorService.resolveMarkets()
+	assert.NoError(t, err)
+	assert.Len(t, markets, 1)
+	assert.Equal(t, ethUsdtDerivativeMarketInfo.MarketId, markets[0].Id)
+
+	liquidatorService.marketIDs = []string{AllMarkets}
+	markets, err = liquidatorService.resolveMarkets()
+	assert.NoError(t, err)
+	assert.Len(t, markets, 2)
+
+	liquidatorService.marketIDs = []string{"0xunknown"}
+	_, err = liquidatorService.resolveMarkets()
+	assert.Error(t, err)
+}
+
+func TestLiquidatePositionMessageUsesMarketLimits(t *testing.T) {
+	granteePublicAddress := "inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku"
+	granteeSubaccountID := eth.HexToHash("0x00606da8ef76ca9c36616fa576d1c053bb0f7eb2000000000000000000000000")
+
+	mockChain := LocalMockChainClient{}
+	mockExchange := exchange.MockExchangeClient{}
+
+	address, _ := types.AccAddressFromBech32(granteePublicAddress)
+	mockChain.FromAddresses = append(mockChain.FromAddresses, address)
+
+	btcUsdtDerivativeMarketInfo := createBTCUSDTDerivativeMarketInfo()
+	mockExchange.SpotMarketsResponses = append(mockExchange.SpotMarketsResponses, &spotExchangePB.MarketsResponse{
+		Markets: []*spotExchangePB.SpotMarketInfo{},
+	})
+	mockExchange.DerivativeMarketsResponses = append(mockExchange.DerivativeMarketsResponses, &derivativeExchangePB.MarketsResponse{
+		Markets: []*derivativeExchangePB.DerivativeMarketInfo{btcUsdtDerivativeMarketInfo},
+	})
+
+	marketAssistant, err := chain.NewMarketsAssistantInitializedFromChain(context.Background(), &mockExchange)
+	assert.NoError(t, err)
+
+	liquidatorService := liquidatorSvc{
+		chainClient:      &mockChain,
+		exchangeClient:   &mockExchange,
+		marketsAssistant: marketAssistant,
+		marketIDs:        []string{btcUsdtDerivativeMarketInfo.MarketId},
+		subaccountID:     granteeSubaccountID,
+		defaultLimits: MarketLimits{
+			MaxOrderAmount:   math.LegacyMaxSortableDec,
+			MaxOrderNotional: math.LegacyMaxSortableDec,
+		},
+		marketLimits: map[string]MarketLimits{
+			btcUsdtDerivativeMarketInfo.MarketId: {
+				MaxOrderAmount:   math.LegacyMustNewDecFromStr("0.5"),
+				MaxOrderNotional: math.LegacyMaxSortableDec,
+			},
+		},
+	}
+
+	market := marketAssistant.AllDerivativeMarkets()[btcUsdtDerivativeMarketInfo.MarketId]
+	position := derivativeExchangePB.DerivativePosition{
+		MarketId:     market.Id,
+		SubaccountId: "positionSubaccountID",
+		Direction:    "long",
+		Quantity:     "2",
+		MarkPrice:    "3400000000",
+	}
+
+	message := liquidatorService.createLiquidationMessage(&position, market, math.LegacyMustNewDecFromStr(position.MarkPrice))
+	liquidationMessage := message.(*exchangetypes.MsgLiquidatePosition)
+
+	assert.Equal(t, math.LegacyMustNewDecFromStr("0.5"), liquidationMessage.Order.OrderInfo.Quantity)
+}
+
+func TestStreamDetectorReportsLiquidablePositions(t *testing.T) {
+	marketID := "0x4ca0f92fc28be0c9761326016b5a1a2177dd6375558365116b5bdda9abc229ce"
+
+	detector := NewStreamDetector(&LocalMockChainClient{}, &exchange.MockExchangeClient{}).(*streamDetector)
+	detector.markets = map[string]*streamMarketState{
+		marketID: {
+			market: core.DerivativeMarket{Id: marketID, Ticker: "BTC/USDT PERP"},
+			state: &eligibility.MarketState{
+				MaintenanceMarginRatio: math.LegacyMustNewDecFromStr("0.025"),
+				MarkPrice:              math.LegacyMustNewDecFromStr("30000000000"),
+			},
+		},
+	}
+	detector.positions = map[string]map[string]*exchangetypes.Position{
+		marketID: {},
+	}
+
+	updatedMarkets := detector.applyPositions([]*chainstreamtypes.Position{
+		{
+			MarketId:     marketID,
+			SubaccountId: "undercollateralizedLong",
+			IsLong:       true,
+			Quantity:     math.LegacyMustNewDecFromStr("1"),
+			EntryPrice:   math.LegacyMustNewDecFromStr("32000000000"),
+			Margin:       math.LegacyMustNewDecFromStr("2000000000"),
+		},
+		{
+			MarketId:     marketID,
+			SubaccountId: "healthyLong",
+			IsLong:       true,
+			Quantity:     math.LegacyMustNewDecFromStr("1"),
+			EntryPrice:   math.LegacyMustNewDecFromStr("32000000000"),
+			Margin:       math.LegacyMustNewDecFromStr("5000000000"),
+		},
+		{
+			MarketId:     marketID,
+			SubaccountId: "undercollateralizedShort",
+			IsLong:       false,
+			Quantity:     math.LegacyMustNewDecFromStr("2"),
+			EntryPrice:   math.LegacyMustNewDecFromStr("28000000000"),
+			Margin:       math.LegacyMustNewDecFromStr("3000000000"),
+		},
+		{
+			MarketId:     "otherMarket",
+			SubaccountId: "ignored",
+			IsLong:       true,
+			Quantity:     math.LegacyMustNewDecFromStr("1"),
+			EntryPrice:   math.LegacyMustNewDecFromStr("32000000000"),
+			Margin:       math.LegacyMustNewDecFromStr("1"),
+		},
+	})
+
+	assert.Len(t, updatedMarkets, 1)
+	assert.Contains(t, updatedMarkets, marketID)
+
+	positions := detector.liquidablePositions(marketID)
+	liquidableSubaccounts := make(map[string]string)
+	for _, position := range positions {
+		liquidableSubaccounts[position.SubaccountId] = position.Direction
+	}
+
+	assert.Equal(t, map[string]string{
+		"undercollateralizedLong":  "long",
+		"undercollateralizedShort": "short",
+	}, liquidableSubaccounts)
+
+	detector.applyPositions([]*chainstreamtypes.Position{
+		{
+			MarketId:     marketID,
+			SubaccountId: "undercollateralizedLong",
+			Quantity:     math.LegacyZeroDec(),
+		},
+	})
+	assert.Len(t, detector.liquidablePositions(marketID), 1)
+}
+
+func TestStreamDetectorPricesTheMarketsFromTheOracleUpdates(t *testing.T) {
+	mockChain := &LocalMockChainClient{
+		MarkPrices: map[string]math.LegacyDec{"btcMarket": math.LegacyMustNewDecFromStr("30000000000")},
+	}
+	detector := NewStreamDetector(mockChain, &exchange.MockExchangeClient{}).(*streamDetector)
+	detector.oraclePrices = make(map[string]math.LegacyDec)
+	newMarket := func(id, base string) *streamMarketState {
+		return &streamMarketState{
+			market: core.DerivativeMarket{Id: id, OracleBase: base, OracleQuote: "USDT", OracleScaleFactor: 6},
+			state:  &eligibility.MarketState{MarkPrice: math.LegacyMustNewDecFromStr("1")},
+		}
+	}
+	detector.markets = map[string]*streamMarketState{
+		"btcMarket": newMarket("btcMarket", "BTC"),
+		"ethMarket": newMarket("ethMarket", "ETH"),
+	}
+
+	// the USDT price is not streamed yet, it is derived from the chain mark price
+	updatedMarkets := detector.applyOraclePrices(context.Background(), []*chainstreamtypes.OraclePrice{
+		{Symbol: "BTC", Price: math.LegacyMustNewDecFromStr("30000")},
+	})
+	assert.Equal(t, map[string]struct{}{"btcMarket": {}}, updatedMarkets)
+	assert.Equal(t, "30000000000.000000000000000000", detector.markets["btcMarket"].state.MarkPrice.String())
+	assert.Equal(t, "1.000000000000000000", detector.oraclePrices["USDT"].String())
+
+	// the next updates are priced locally
+	delete(mockChain.MarkPrices, "btcMarket")
+	updatedMarkets = detector.applyOraclePrices(context.Background(), []*chainstreamtypes.OraclePrice{
+		{Symbol: "BTC", Price: math.LegacyMustNewDecFromStr("27000")},
+		{Symbol: "ATOM", Price: math.LegacyMustNewDecFromStr("10")},
+	})
+	assert.Equal(t, map[string]struct{}{"btcMarket": {}}, updatedMarkets)
+	assert.Equal(t, "27000000000.000000000000000000", detector.markets["btcMarket"].state.MarkPrice.String())
+
+	// the quote symbol reprices every market using it
+	updatedMarkets = detector.applyOraclePrices(context.Background(), []*chainstreamtypes.OraclePrice{
+		{Symbol: "ETH", Price: math.LegacyMustNewDecFromStr("2000")},
+		{Symbol: "USDT", Price: math.LegacyMustNewDecFromStr("0.8")},
+	})
+	assert.Equal(t, map[string]struct{}{"btcMarket": {}, "ethMarket": {}}, updatedMarkets)
+	assert.Equal(t, "33750000000.000000000000000000", detector.markets["btcMarket"].state.MarkPrice.String())
+	assert.Equal(t, "2500000000.000000000000000000", detector.markets["ethMarket"].state.MarkPrice.String())
+}
+
+func TestStreamDetectorAppliesTheMarketUpdates(t *testing.T) {
+	cumulativeFunding := math.LegacyMustNewDecFromStr("100")
+	detector := NewStreamDetector(&LocalMockChainClient{}, &exchange.MockExchangeClient{}).(*streamDetector)
+	detector.markets = map[string]*streamMarketState{
+		"btcMarket": {
+			market: core.DerivativeMarket{Id: "btcMarket"},
+			state: &eligibility.MarketState{
+				MaintenanceMarginRatio: math.LegacyMustNewDecFromStr("0.025"),
+				MarkPrice:              math.LegacyMustNewDecFromStr("30000000000"),
+				CumulativeFunding:      &cumulativeFunding,
+			},
+		},
+	}
+
+	updatedMarkets := detector.applyMarket(&derivativeExchangePB.DerivativeMarketInfo{
+		MarketId:               "btcMarket",
+		MaintenanceMarginRatio: "0.05",
+		PerpetualMarketFunding: &derivativeExchangePB.PerpetualMarketFunding{CumulativeFunding: "150"},
+	})
+	assert.Equal(t, map[string]struct{}{"btcMarket": {}}, updatedMarkets)
+	state := detector.markets["btcMarket"].state
+	assert.Equal(t, "0.050000000000000000", state.MaintenanceMarginRatio.String())
+	assert.Equal(t, "150.000000000000000000", state.CumulativeFunding.String())
+	assert.Equal(t, "30000000000.000000000000000000", state.MarkPrice.String())
+
+	assert.Empty(t, detector.applyMarket(&derivativeExchangePB.DerivativeMarketInfo{MarketId: "otherMarket"}))
+}
+
+func TestLiquidatePositionMessageUsesOrderPrice(t *testing.T) {
+	granteePublicAddress := "inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku"
+	granteeSubaccountID := eth.HexToHash("0x00606da8ef76ca9c36616fa576d1c053bb0f7eb2000000000000000000000000")
+
+	mockChain := LocalMockChainClient{}
+	mockExchange := exchange.MockExchangeClient{}
+
+	address, _ := types.AccAddressFromBech32(granteePublicAddress)
+	mockChain.FromAddresses = append(mockChain.FromAddresses, address)
+
+	btcUsdtDerivativeMarketInfo := createBTCUSDTDerivativeMarketInfo()
+	mockExchange.SpotMarketsResponses = append(mockExchange.SpotMarketsResponses, &spotExchangePB.MarketsResponse{
+		Markets: []*spotExchangePB.SpotMarketInfo{},
+	})
+	mockExchange.DerivativeMarketsResponses = append(mockExchange.DerivativeMarketsResponses, &derivativeExchangePB.MarketsResponse{
+		Markets: []*derivativeExchangePB.DerivativeMarketInfo{btcUsdtDerivativeMarketInfo},
+	})
+
+	marketAssistant, err := chain.NewMarketsAssistantInitializedFromChain(context.Background(), &mockExchange)
+	assert.NoError(t, err)
+
+	liquidatorService := liquidatorSvc{
+		chainClient:      &mockChain,
+		exchangeClient:   &mockExchange,
+		marketsAssistant: marketAssistant,
+		marketIDs:        []string{btcUsdtDerivativeMarketInfo.MarketId},
+		subaccountID:     granteeSubaccountID,
+		defaultLimits: MarketLimits{
+			MaxOrderAmount:   math.LegacyMaxSortableDec,
+			MaxOrderNotional: math.LegacyMustNewDecFromStr("3300000000"),
+		},
+	}
+
+	market := marketAssistant.AllDerivativeMarkets()[btcUsdtDerivativeMarketInfo.MarketId]
+	position := derivativeExchangePB.DerivativePosition{
+		MarketId:     market.Id,
+		SubaccountId: "positionSubaccountID",
+		Direction:    "short",
+		Quantity:     "2",
+		MarkPrice:    "3400000000",
+	}
+
+	message := liquidatorService.createLiquidationMessage(&position, market, math.LegacyMustNewDecFromStr("3300000000"))
+	liquidationMessage := message.(*exchangetypes.MsgLiquidatePosition)
+
+	assert.Equal(t, exchangetypes.OrderType_SELL, liquidationMessage.Order.OrderType)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("3300000000"), liquidationMessage.Order.OrderInfo.Price)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("1"), liquidationMessage.Order.OrderInfo.Quantity)
+}
+
+func TestBroadcastLiquidationsSplitsBatchesByLimits(t *testing.T) {
+	mockChain := LocalMockChainClient{}
+	liquidatorService := liquidatorSvc{
+		chainClient: &mockChain,
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+		batchSettings: BatchSettings{
+			MaxMessages:   3,
+			MaxGas:        500000,
+			GasPerMessage: 200000,
+		},
+	}
+
+	var liquidations []pendingLiquidation
+	for _, subaccountID := range []string{"a", "b", "c", "d", "e"} {
+		liquidations = append(liquidations, pendingLiquidation{
+			position: &derivativeExchangePB.DerivativePosition{SubaccountId: subaccountID},
+			msg:      exchangetypes.MsgLiquidatePosition{SubaccountId: subaccountID},
+		})
+	}
+
+	liquidatorService.broadcastLiquidations(context.Background(), liquidations)
+	liquidatorService.pipeline.wait()
+
+	// the gas limit allows only 2 liquidations per transaction
+	assert.Len(t, mockChain.BroadcastedTxs, 3)
+	assert.Len(t, mockChain.BroadcastedTxs[0], 2)
+	assert.Len(t, mockChain.BroadcastedTxs[1], 2)
+	assert.Len(t, mockChain.BroadcastedTxs[2], 1)
+	assert.Len(t, mockChain.BroadcastedMessages, 5)
+}
+
+func TestBroadcastLiquidationsDropsTheFailedLiquidation(t *testing.T) {
+	mockChain := LocalMockChainClient{
+		FailingSubaccountIDs: map[string]bool{"c": true},
+	}
+	liquidatorService := liquidatorSvc{
+		chainClient: &mockChain,
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+		batchSettings: BatchSettings{
+			MaxMessages: 10,
+		},
+	}
+
+	liquidatorService.broadcastLiquidations(context.Background(), createPendingLiquidations("a", "b", "c", "d", "e"))
+	liquidatorService.pipeline.wait()
+
+	assert.ElementsMatch(t, []string{"a", "b", "d", "e"}, broadcastedSubaccounts(&mockChain))
+	// [a b c d e] -> [a b d e]
+	assert.Len(t, mockChain.BroadcastedTxs, 2)
+	assert.Equal(t, int64(4), liquidatorService.summary.liquidated.Load())
+	assert.Equal(t, int64(1), liquidatorService.summary.failed.Load())
+}
+
+func TestBroadcastLiquidationsDropsTheFailedLiquidationOfADelegatedBatch(t *testing.T) {
+	granteeAddress, _ := types.AccAddressFromBech32("inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku")
+	mockChain := LocalMockChainClient{
+		FromAddresses:        []types.AccAddress{granteeAddress},
+		FailingSubaccountIDs: map[string]bool{"c": true},
+	}
+	liquidatorService := liquidatorSvc{
+		chainClient:          &mockChain,
+		granterPublicAddress: "granterPublicAddress",
+		logger:               log.WithField("svc", "liquidator"),
+		txTracker:            txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+		batchSettings: BatchSettings{
+			MaxMessages: 10,
+		},
+	}
+
+	liquidatorService.broadcastLiquidations(context.Background(), createPendingLiquidations("a", "b", "c", "d", "e"))
+	liquidatorService.pipeline.wait()
+
+	assert.ElementsMatch(t, []string{"a", "b", "d", "e"}, broadcastedSubaccounts(&mockChain))
+	// [a b c d e] -> [a b d e], only the failed liquidation is blamed
+	assert.Len(t, mockChain.BroadcastedTxs, 2)
+	assert.Equal(t, int64(4), liquidatorService.summary.liquidated.Load())
+	assert.Equal(t, int64(1), liquidatorService.summary.failed.Load())
+}
+
+func TestBroadcastLiquidationsBisectsFailedBatches(t *testing.T) {
+	mockChain := LocalMockChainClient{
+		FailingSubaccountIDs:    map[string]bool{"c": true},
+		FailWithoutMessageIndex: true,
+	}
+	liquidatorService := liquidatorSvc{
+		chainClient: &mockChain,
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+		batchSettings: BatchSettings{
+			MaxMessages: 10,
+		},
+	}
+
+	liquidatorService.broadcastLiquidations(context.Background(), createPendingLiquidations("a", "b", "c", "d", "e"))
+	liquidatorService.pipeline.wait()
+
+	assert.ElementsMatch(t, []string{"a", "b", "d", "e"}, broadcastedSubaccounts(&mockChain))
+	// [a b c d e] -> [a b] + [c d e] -> [c] + [d e]
+	assert.Len(t, mockChain.BroadcastedTxs, 5)
+}
+
+func createPendingLiquidations(subaccountIDs ...string) []pendingLiquidation {
+	var liquidations []pendingLiquidation
+	for _, subaccountID := range subaccountIDs {
+		liquidations = append(liquidations, pendingLiquidation{
+			position: &derivativeExchangePB.DerivativePosition{SubaccountId: subaccountID},
+			msg:      exchangetypes.MsgLiquidatePosition{SubaccountId: subaccountID},
+		})
+	}
+	return liquidations
+}
+
+func broadcastedSubaccounts(mockChain *LocalMockChainClient) []string {
+	var subaccountIDs []string
+	for _, msg := range mockChain.BroadcastedMessages {
+		subaccountID, _ := liquidatedSubaccount(msg)
+		subaccountIDs = append(subaccountIDs, subaccountID)
+	}
+	return subaccountIDs
+}
+
+func TestBatchMessagesWhenUsingDelegatedAccount(t *testing.T) {
+	granteePublicAddress := "inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku"
+	address, _ := types.AccAddressFromBech32(granteePublicAddress)
+
+	mockChain := LocalMockChainClient{FromAddresses: []types.AccAddress{address}}
+	liquidatorService := liquidatorSvc{
+		chainClient:          &mockChain,
+		granterPublicAddress: "granterPublicAddress",
+	}
+
+	batch := []pendingLiquidation{
+		{msg: exchangetypes.MsgLiquidatePosition{Sender: "granterPublicAddress", SubaccountId: "a"}},
+		{msg: exchangetypes.MsgLiquidatePosition{Sender: "granterPublicAddress", SubaccountId: "b"}},
+	}
+
+	msgs := liquidatorService.createBatchMessages(batch)
+
+	// one authz execution per liquidation, so the failed message index identifies the liquidation
+	assert.Len(t, msgs, 2)
+	for i, subaccountID := range []string{"a", "b"} {
+		execMessage := msgs[i].(*authz.MsgExec)
+		assert.Equal(t, granteePublicAddress, execMessage.Grantee)
+		assert.Len(t, execMessage.Msgs, 1)
+
+		liquidationMessage := exchangetypes.MsgLiquidatePosition{}
+		assert.NoError(t, liquidationMessage.Unmarshal(execMessage.Msgs[0].GetValue()))
+		assert.Equal(t, subaccountID, liquidationMessage.SubaccountId)
+	}
+}
+
+func TestBroadcastLiquidationsAbandonedOnShutdown(t *testing.T) {
+	mockChain := LocalMockChainClient{}
+	liquidatorService := liquidatorSvc{
+		chainClient:   &mockChain,
+		logger:        log.WithField("svc", "liquidator"),
+		batchSettings: BatchSettings{MaxMessages: 1},
+		stopped:       make(chan struct{}),
+	}
+
+	liquidations := []pendingLiquidation{
+		{msg: exchangetypes.MsgLiquidatePosition{SubaccountId: "a"}},
+		{msg: exchangetypes.MsgLiquidatePosition{SubaccountId: "b"}},
+	}
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+	liquidatorService.broadcastLiquidations(ctx, liquidations)
+	liquidatorService.pipeline.wait()
+
+	assert.Empty(t, mockChain.BroadcastedTxs)
+	assert.Equal(t, int64(2), liquidatorService.summary.abandoned.Load())
+	assert.Equal(t, int64(0), liquidatorService.summary.transactions.Load())
+
+	// Close does not wait for a service that was never started
+	liquidatorService.Close()
+}
+
+func TestSimulatedLiquidationsUseTheSimulatedGasLimit(t *testing.T) {
+	mockChain := LocalMockChainClient{
+		SimulatedGas:         100000,
+		FailingSubaccountIDs: map[string]bool{"c": true},
+	}
+	liquidatorService := liquidatorSvc{
+		chainClient: &mockChain,
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+		batchSettings: BatchSettings{
+			MaxMessages: 10,
+		},
+		simulationSettings: SimulationSettings{
+			Enabled:       true,
+			GasMultiplier: 1.5,
+		},
+	}
+
+	var liquidations []pendingLiquidation
+	for _, liquidation := range createPendingLiquidations("a", "b", "c") {
+		_, gasLimit, err := liquidatorService.simulateLiquidation(liquidation)
+		if err != nil {
+			assert.Equal(t, txtracker.OutcomeAlreadyLiquidated, txtracker.ResultFromError(err).Outcome)
+			continue
+		}
+		liquidation.gasLimit = gasLimit
+		liquidations = append(liquidations, liquidation)
+	}
+
+	liquidatorService.broadcastLiquidations(context.Background(), liquidations)
+	liquidatorService.broadcastLiquidations(context.Background(), liquidations[:1])
+	liquidatorService.pipeline.wait()
+
+	assert.Len(t, mockChain.SignedTxs, 2)
+	assert.Equal(t, uint64(300000), mockChain.SignedTxs[0].GasLimit)
+	assert.Len(t, mockChain.SignedTxs[0].Msgs, 2)
+	// the sequence is increased locally after each accepted transaction
+	assert.Equal(t, uint64(2), mockChain.SignedTxs[0].AccSeq)
+	assert.Equal(t, uint64(3), mockChain.SignedTxs[1].AccSeq)
+	assert.Equal(t, int64(3), liquidatorService.summary.liquidated.Load())
+}
+
+func TestDryRunRecordsTheLiquidationInsteadOfBroadcasting(t *testing.T) {
+	granteePublicAddress := "inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku"
+	address, _ := types.AccAddressFromBech32(granteePublicAddress)
+
+	mockChain := LocalMockChainClient{FromAddresses: []types.AccAddress{address, address}}
+	mockExchange := exchange.MockExchangeClient{}
+
+	btcUsdtDerivativeMarketInfo := createBTCUSDTDerivativeMarketInfo()
+	mockExchange.SpotMarketsResponses = append(mockExchange.SpotMarketsResponses, &spotExchangePB.MarketsResponse{
+		Markets: []*spotExchangePB.SpotMarketInfo{},
+	})
+	mockExchange.DerivativeMarketsResponses = append(mockExchange.DerivativeMarketsResponses, &derivativeExchangePB.MarketsResponse{
+		Markets: []*derivativeExchangePB.DerivativeMarketInfo{btcUsdtDerivativeMarketInfo},
+	})
+
+	marketAssistant, err := chain.NewMarketsAssistantInitializedFromChain(context.Background(), &mockExchange)
+	assert.NoError(t, err)
+
+	output := bytes.Buffer{}
+	liquidatorService := liquidatorSvc{
+		chainClient:      &mockChain,
+		marketsAssistant: marketAssistant,
+		logger:           log.WithField("svc", "liquidator"),
+		dryRunRecorder:   NewDryRunRecorder(&output),
+		defaultLimits: MarketLimits{
+			MaxOrderAmount:   math.LegacyMaxSortableDec,
+			MaxOrderNotional: math.LegacyMaxSortableDec,
+		},
+	}
+
+	market := marketAssistant.AllDerivativeMarkets()[btcUsdtDerivativeMarketInfo.MarketId]
+	position := derivativeExchangePB.DerivativePosition{
+		MarketId:     market.Id,
+		SubaccountId: "positionSubaccountID",
+		Direction:    "long",
+		Quantity:     "0.5",
+		MarkPrice:    "30000000000",
+	}
+
+	liquidation := liquidatorService.createPendingLiquidation(&position, market, math.LegacyMustNewDecFromStr("29900000000"), math.LegacyMustNewDecFromStr("0.5"))
+	liquidatorService.recordDryRun(liquidation, "mark_buffer:33", profitability.Estimate{
+		Reward:     math.LegacyMustNewDecFromStr("10000000"),
+		FillPnl:    math.LegacyMustNewDecFromStr("50000000"),
+		TradingFee: math.LegacyMustNewDecFromStr("14950000"),
+		GasCost:    math.LegacyMustNewDecFromStr("1600"),
+		Profit:     math.LegacyMustNewDecFromStr("45048400"),
+	}, "", 0)
+
+	record := DryRunRecord{}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &record))
+	assert.Equal(t, "BTC/USDT PERP", record.Ticker)
+	assert.Equal(t, "0.5", record.Quantity)
+	assert.Equal(t, "29900", record.Price)
+	assert.Equal(t, "14950", record.Notional)
+	assert.Equal(t, "45.0484", record.ExpectedProfit)
+	assert.Equal(t, "/injective.exchange.v1beta1.MsgLiquidatePosition", record.MessageType)
+	assert.Equal(t, "positionSubaccountID", record.Liquidation.SubaccountId)
+	assert.Empty(t, mockChain.BroadcastedTxs)
+	assert.Equal(t, int64(1), liquidatorService.summary.dryRun.Load())
+}
+
+func TestFundableQuantity(t *testing.T) {
+	price := math.LegacyMustNewDecFromStr("30000000000")
+	feeRate := math.LegacyMustNewDecFromStr("0.001")
+	tick := math.LegacyMustNewDecFromStr("0.001")
+
+	// 0.5 * 30000 USDT plus the fee is fully funded
+	fundable := fundableQuantity(math.LegacyMustNewDecFromStr("0.5"), price, feeRate, math.LegacyMustNewDecFromStr("20000000000"), tick)
+	assert.Equal(t, "0.500000000000000000", fundable.String())
+
+	// 10000 USDT funds 0.333 BTC after rounding down to the tick
+	fundable = fundableQuantity(math.LegacyMustNewDecFromStr("0.5"), price, feeRate, math.LegacyMustNewDecFromStr("10000000000"), tick)
+	assert.Equal(t, "0.333000000000000000", fundable.String())
+
+	// 20 USDT does not fund the minimum quantity
+	fundable = fundableQuantity(math.LegacyMustNewDecFromStr("0.5"), price, feeRate, math.LegacyMustNewDecFromStr("20000000"), tick)
+	assert.True(t, fundable.IsZero())
+}
+
+func TestLoadCollateralFromTheGranterSubaccount(t *testing.T) {
+	granterSubaccountID := eth.HexToHash("0xbdaedec95d563fb05240d6e01821008454c24c36000000000000000000000001")
+	mockChain := LocalMockChainClient{
+		Deposits: map[string]*exchangetypes.Deposit{
+			"peggy0xdAC17F958D2ee523a2206206994597C13D831ec7": {
+				AvailableBalance: math.LegacyMustNewDecFromStr("10000000000"),
+				TotalBalance:     math.LegacyMustNewDecFromStr("15000000000"),
+			},
+		},
+	}
+
+	liquidatorService := liquidatorSvc{
+		chainClient:          &mockChain,
+		subaccountID:         eth.HexToHash("0x01"),
+		granterPublicAddress: "inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r",
+		granterSubaccountID:  granterSubaccountID,
+	}
+
+	balances, err := liquidatorService.loadCollateral(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, granterSubaccountID.Hex(), mockChain.QueriedSubaccountID)
+	assert.Equal(t, "10000000000.000000000000000000", balances.available("peggy0xdAC17F958D2ee523a2206206994597C13D831ec7").String())
+	assert.True(t, balances.available("inj").IsZero())
+
+	balances.consume("peggy0xdAC17F958D2ee523a2206206994597C13D831ec7", math.LegacyMustNewDecFromStr("4000000000"))
+	assert.Equal(t, "6000000000.000000000000000000", balances.available("peggy0xdAC17F958D2ee523a2206206994597C13D831ec7").String())
+}
+
+func TestExecutedLiquidationsUpdateTheInventory(t *testing.T) {
+	granteePublicAddress := "inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku"
+	address, _ := types.AccAddressFromBech32(granteePublicAddress)
+
+	mockChain := LocalMockChainClient{
+		FromAddresses:        []types.AccAddress{address, address},
+		FailingSubaccountIDs: map[string]bool{"failingSubaccountID": true},
+	}
+	mockExchange := exchange.MockExchangeClient{}
+
+	btcUsdtDerivativeMarketInfo := createBTCUSDTDerivativeMarketInfo()
+	mockExchange.SpotMarketsResponses = append(mockExchange.SpotMarketsResponses, &spotExchangePB.MarketsResponse{
+		Markets: []*spotExchangePB.SpotMarketInfo{},
+	})
+	mockExchange.DerivativeMarketsResponses = append(mockExchange.DerivativeMarketsResponses, &derivativeExchangePB.MarketsResponse{
+		Markets: []*derivativeExchangePB.DerivativeMarketInfo{btcUsdtDerivativeMarketInfo},
+	})
+
+	marketAssistant, err := chain.NewMarketsAssistantInitializedFromChain(context.Background(), &mockExchange)
+	assert.NoError(t, err)
+
+	liquidatorService := liquidatorSvc{
+		chainClient:      &mockChain,
+		marketsAssistant: marketAssistant,
+		logger:           log.WithField("svc", "liquidator"),
+		txTracker:        txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+		inventory:        inventory.New(inventory.Limits{}),
+		batchSettings: BatchSettings{
+			MaxMessages: 10,
+		},
+	}
+
+	market := marketAssistant.AllDerivativeMarkets()[btcUsdtDerivativeMarketInfo.MarketId]
+	price := math.LegacyMustNewDecFromStr("29900000000")
+	var liquidations []pendingLiquidation
+	for _, subaccountID := range []string{"positionSubaccountID", "failingSubaccountID"} {
+		position := derivativeExchangePB.DerivativePosition{
+			MarketId:     market.Id,
+			SubaccountId: subaccountID,
+			Direction:    "short",
+			Quantity:     "0.5",
+			MarkPrice:    "30000000000",
+		}
+		liquidations = append(liquidations, liquidatorService.createPendingLiquidation(&position, market, price, math.LegacyMustNewDecFromStr("0.5")))
+	}
+
+	liquidatorService.broadcastLiquidations(context.Background(), liquidations)
+	liquidatorService.pipeline.wait()
+
+	// only the executed liquidation sells 0.5 BTC
+	position := liquidatorService.inventory.Position(liquidatorService.tradingSubaccountID().Hex(), market.Id)
+	assert.Equal(t, "-0.500000000000000000", position.Quantity.String())
+	assert.Equal(t, "29900.000000000000000000", position.Price.String())
+	assert.Equal(t, "14950.000000000000000000", liquidatorService.inventory.TotalExposure().String())
+}
+
+func TestUnwindMessagesAreReduceOnlyOrdersOfTheGranter(t *testing.T) {
+	mockChain := LocalMockChainClient{}
+	mockExchange := exchange.MockExchangeClient{}
+
+	btcUsdtDerivativeMarketInfo := createBTCUSDTDerivativeMarketInfo()
+	mockExchange.SpotMarketsResponses = append(mockExchange.SpotMarketsResponses, &spotExchangePB.MarketsResponse{
+		Markets: []*spotExchangePB.SpotMarketInfo{},
+	})
+	mockExchange.DerivativeMarketsResponses = append(mockExchange.DerivativeMarketsResponses, &derivativeExchangePB.MarketsResponse{
+		Markets: []*derivativeExchangePB.DerivativeMarketInfo{btcUsdtDerivativeMarketInfo},
+	})
+
+	marketAssistant, err := chain.NewMarketsAssistantInitializedFromChain(context.Background(), &mockExchange)
+	assert.NoError(t, err)
+
+	granterSubaccountID := eth.HexToHash("0xbdaedec95d563fb05240d6e01821008454c24c36000000000000000000000001")
+	liquidatorService := liquidatorSvc{
+		chainClient:          &mockChain,
+		marketsAssistant:     marketAssistant,
+		granterPublicAddress: "inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r",
+		granterSubaccountID:  granterSubaccountID,
+	}
+	market := marketAssistant.AllDerivativeMarkets()[btcUsdtDerivativeMarketInfo.MarketId]
+
+	marketOrder := liquidatorService.createUnwindMessage(market, unwind.Order{
+		IsBuy:    false,
+		Quantity: math.LegacyMustNewDecFromStr("0.5"),
+		Price:    math.LegacyMustNewDecFromStr("29700000000"),
+		IsMarket: true,
+	}).(*exchangetypes.MsgCreateDerivativeMarketOrder)
+
+	assert.Equal(t, "inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r", marketOrder.Sender)
+	assert.Equal(t, exchangetypes.OrderType_SELL, marketOrder.Order.OrderType)
+	assert.Equal(t, granterSubaccountID.Hex(), marketOrder.Order.OrderInfo.SubaccountId)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("0.5"), marketOrder.Order.OrderInfo.Quantity)
+	assert.True(t, marketOrder.Order.Margin.IsZero())
+
+	limitOrder := liquidatorService.createUnwindMessage(market, unwind.Order{
+		IsBuy:    true,
+		Quantity: math.LegacyMustNewDecFromStr("0.5"),
+		Price:    math.LegacyMustNewDecFromStr("29940000000"),
+	}).(*exchangetypes.MsgBatchUpdateOrders)
+
+	assert.Equal(t, []string{market.Id}, limitOrder.DerivativeMarketIdsToCancelAll)
+	assert.Len(t, limitOrder.DerivativeOrdersToCreate, 1)
+	assert.Equal(t, exchangetypes.OrderType_BUY, limitOrder.DerivativeOrdersToCreate[0].OrderType)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("29940000000"), limitOrder.DerivativeOrdersToCreate[0].OrderInfo.Price)
+	assert.True(t, limitOrder.DerivativeOrdersToCreate[0].Margin.IsZero())
+}
+
+func TestUnwindQuantity(t *testing.T) {
+	tick := math.LegacyMustNewDecFromStr("0.001")
+	position := math.LegacyMustNewDecFromStr("0.5")
+
+	assert.Equal(t, "0.123000000000000000", unwindQuantity(math.LegacyMustNewDecFromStr("0.1234"), position, tick).String())
+	assert.Equal(t, "0.001000000000000000", unwindQuantity(math.LegacyMustNewDecFromStr("0.0004"), position, tick).String())
+	assert.Equal(t, "0.000500000000000000", unwindQuantity(math.LegacyMustNewDecFromStr("0.0004"), math.LegacyMustNewDecFromStr("0.0005"), tick).String())
+}
+
+func TestExecutedLiquidationsAreHedged(t *testing.T) {
+	granteePublicAddress := "inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku"
+	address, _ := types.AccAddressFromBech32(granteePublicAddress)
+
+	btcUsdtDerivativeMarketInfo := createBTCUSDTDerivativeMarketInfo()
+	ethUsdtDerivativeMarketInfo := createETHUSDTExpiryDerivativeMarketInfo()
+
+	mockChain := LocalMockChainClient{
+		FromAddresses: []types.AccAddress{address, address, address},
+		MarkPrices: map[string]math.LegacyDec{
+			ethUsdtDerivativeMarketInfo.MarketId: math.LegacyMustNewDecFromStr("2000000000"),
+		},
+	}
+	mockExchange := exchange.MockExchangeClient{}
+	mockExchange.SpotMarketsResponses = append(mockExchange.SpotMarketsResponses, &spotExchangePB.MarketsResponse{
+		Markets: []*spotExchangePB.SpotMarketInfo{},
+	})
+	mockExchange.DerivativeMarketsResponses = append(mockExchange.DerivativeMarketsResponses, &derivativeExchangePB.MarketsResponse{
+		Markets: []*derivativeExchangePB.DerivativeMarketInfo{btcUsdtDerivativeMarketInfo, ethUsdtDerivativeMarketInfo},
+	})
+
+	marketAssistant, err := chain.NewMarketsAssistantInitializedFromChain(context.Background(), &mockExchange)
+	assert.NoError(t, err)
+
+	liquidatorService := liquidatorSvc{
+		chainClient:      &mockChain,
+		marketsAssistant: marketAssistant,
+		logger:           log.WithField("svc", "liquidator"),
+		txTracker:        txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+		batchSettings: BatchSettings{
+			MaxMessages: 10,
+		},
+		hedgeSettings: HedgeSettings{
+			Markets: map[string]Hedge{
+				btcUsdtDerivativeMarketInfo.MarketId: {
+					MarketID: ethUsdtDerivativeMarketInfo.MarketId,
+					Ratio:    math.LegacyMustNewDecFromStr("15"),
+				},
+			},
+			MaxSlippageBps: math.LegacyNewDec(50),
+		},
+	}
+
+	market := marketAssistant.AllDerivativeMarkets()[btcUsdtDerivativeMarketInfo.MarketId]
+	position := derivativeExchangePB.DerivativePosition{
+		MarketId:     market.Id,
+		SubaccountId: "positionSubaccountID",
+		Direction:    "long",
+		Quantity:     "0.5",
+		MarkPrice:    "30000000000",
+	}
+	liquidation := liquidatorService.createPendingLiquidation(&position, market, math.LegacyMustNewDecFromStr("29900000000"), math.LegacyMustNewDecFromStr("0.5"))
+
+	liquidatorService.broadcastLiquidations(context.Background(), []pendingLiquidation{liquidation})
+	liquidatorService.pipeline.wait()
+
+	assert.Len(t, mockChain.BroadcastedTxs, 2)
+	hedgeMsg := mockChain.BroadcastedTxs[1][0].(*exchangetypes.MsgCreateDerivativeMarketOrder)
+	// the acquired 0.5 BTC long is hedged selling 7.5 ETH, accepting a price down to 1990
+	assert.Equal(t, ethUsdtDerivativeMarketInfo.MarketId, hedgeMsg.Order.MarketId)
+	assert.Equal(t, exchangetypes.OrderType_SELL, hedgeMsg.Order.OrderType)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("7.5"), hedgeMsg.Order.OrderInfo.Quantity)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("1990000000"), hedgeMsg.Order.OrderInfo.Price)
+}
+
+func TestBroadcastPipelineKeepsSeveralTransactionsInFlight(t *testing.T) {
+	mockChain := LocalMockChainClient{HoldConfirmations: true}
+	liquidatorService := liquidatorSvc{
+		chainClient: &mockChain,
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(&mockChain, 10*time.Second, time.Millisecond),
+		batchSettings: BatchSettings{
+			MaxMessages: 1,
+			MaxInFlight: 3,
+		},
+	}
+
+	broadcastDone := make(chan struct{})
+	go func() {
+		defer close(broadcastDone)
+		liquidatorService.broadcastLiquidations(context.Background(), createPendingLiquidations("a", "b", "c", "d", "e"))
+	}()
+
+	// no more than MaxInFlight transactions are broadcast while none is confirmed
+	assert.Eventually(t, func() bool { return mockChain.SignedTxsCount() == 3 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 3, mockChain.SignedTxsCount())
+	assert.True(t, liquidatorService.pipeline.isInFlight("/a"))
+	assert.True(t, liquidatorService.pipeline.isInFlight("/e"))
+
+	mockChain.ConfirmTxs()
+	<-broadcastDone
+	liquidatorService.pipeline.wait()
+
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d", "e"}, broadcastedSubaccounts(&mockChain))
+	// each transaction is signed with the next sequence, without waiting for the previous confirmation
+	for i, signedTx := range mockChain.SignedTxs {
+		assert.Equal(t, uint64(2+i), signedTx.AccSeq)
+	}
+	assert.Equal(t, int64(5), liquidatorService.summary.liquidated.Load())
+	assert.Empty(t, liquidatorService.pipeline.inFlight())
+}
+
+func TestAccountSequenceIsResyncedOnMismatch(t *testing.T) {
+	// the chain client nonce is 2 while the chain expects 7
+	mockChain := LocalMockChainClient{ExpectedSequence: 7}
+	liquidatorService := liquidatorSvc{
+		chainClient: &mockChain,
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+	}
+
+	for _, subaccountID := range []string{"a", "b"} {
+		result := liquidatorService.broadcastAndWait(context.Background(), 0, &exchangetypes.MsgLiquidatePosition{SubaccountId: subaccountID})
+		assert.Equal(t, txtracker.OutcomeSuccess, result.Outcome)
+	}
+
+	var sequences []uint64
+	for _, signedTx := range mockChain.SignedTxs {
+		sequences = append(sequences, signedTx.AccSeq)
+	}
+	assert.Equal(t, []uint64{2, 7, 8}, sequences)
+	assert.ElementsMatch(t, []string{"a", "b"}, broadcastedSubaccounts(&mockChain))
+}
+
+func TestSimulationFollowingABroadcastUsesTheLocalSequence(t *testing.T) {
+	// the chain client nonce is 2, and is not advanced by the transactions signed with the local sequence
+	mockChain := LocalMockChainClient{ExpectedSequence: 2, SimulatedGas: 100000}
+	liquidatorService := liquidatorSvc{
+		chainClient: &mockChain,
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+		simulationSettings: SimulationSettings{
+			Enabled:       true,
+			GasMultiplier: 1.5,
+		},
+	}
+
+	result := liquidatorService.broadcastAndWait(context.Background(), 0, &exchangetypes.MsgLiquidatePosition{SubaccountId: "a"})
+	assert.Equal(t, txtracker.OutcomeSuccess, result.Outcome)
+
+	_, gasLimit, err := liquidatorService.simulateLiquidation(createPendingLiquidations("b")[0])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(150000), gasLimit)
+
+	// a node expecting another sequence is simulated again with its sequence
+	mockChain.ExpectedSequence = 9
+	_, _, err = liquidatorService.simulateLiquidation(createPendingLiquidations("c")[0])
+	assert.NoError(t, err)
+
+	assert.Equal(t, []uint64{3, 9}, mockChain.SimulatedSequences)
+}
+
+func TestLiquidationAttemptsAreSuppressedUntilResolvedAndCooledDown(t *testing.T) {
+	mockChain := LocalMockChainClient{HoldConfirmations: true}
+	liquidatorService := liquidatorSvc{
+		chainClient: &mockChain,
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(&mockChain, 10*time.Second, time.Millisecond),
+		dedup:       dedup.New(time.Minute, 5*time.Minute),
+	}
+
+	liquidations := createPendingLiquidations("a")
+	liquidatorService.beginAttempts(liquidations...)
+	liquidatorService.broadcastLiquidations(context.Background(), liquidations)
+
+	state, suppressed := liquidatorService.suppressedAttempt(liquidations[0].position)
+	assert.True(t, suppressed)
+	assert.Equal(t, dedup.StatePending, state)
+
+	mockChain.ConfirmTxs()
+	liquidatorService.pipeline.wait()
+
+	// the indexer still returns the liquidated position during the cool-down
+	state, suppressed = liquidatorService.suppressedAttempt(liquidations[0].position)
+	assert.True(t, suppressed)
+	assert.Equal(t, dedup.StateCoolDown, state)
+	_, suppressed = liquidatorService.suppressedAttempt(createPendingLiquidations("b")[0].position)
+	assert.False(t, suppressed)
+}
+
+func createSigningClients(t *testing.T, addresses ...string) []*LocalMockChainClient {
+	clients := make([]*LocalMockChainClient, 0, len(addresses))
+	for _, bech32Address := range addresses {
+		address, err := types.AccAddressFromBech32(bech32Address)
+		assert.NoError(t, err)
+		clients = append(clients, &LocalMockChainClient{FromAddresses: []types.AccAddress{address}})
+	}
+	return clients
+}
+
+func newSignersOf(clients []*LocalMockChainClient) *SignerPool {
+	chainClients := make([]chain.ChainClient, 0, len(clients))
+	for _, client := range clients {
+		chainClients = append(chainClients, client)
+	}
+	return NewSignerPool(chainClients...)
+}
+
+func TestTransactionsAreSignedByTheKeysInTurn(t *testing.T) {
+	clients := createSigningClients(t,
+		"inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku",
+		"inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r",
+		"inj1cml96vmptgw99syqrrz8az79xer2pcgp0a885r",
+	)
+	liquidatorService := liquidatorSvc{
+		chainClient:    clients[0],
+		logger:         log.WithField("svc", "liquidator"),
+		txTracker:      txtracker.NewTracker(clients[0], time.Second, time.Millisecond),
+		signers:        newSignersOf(clients),
+		signerSettings: SignerSettings{Selection: SignerSelectionRoundRobin},
+	}
+
+	for _, subaccountID := range []string{"a", "b", "c", "d", "e", "f"} {
+		result := liquidatorService.broadcastAndWait(context.Background(), 0, &exchangetypes.MsgLiquidatePosition{SubaccountId: subaccountID})
+		assert.Equal(t, txtracker.OutcomeSuccess, result.Outcome)
+	}
+
+	assert.Equal(t, []string{"a", "d"}, broadcastedSubaccounts(clients[0]))
+	assert.Equal(t, []string{"b", "e"}, broadcastedSubaccounts(clients[1]))
+	assert.Equal(t, []string{"c", "f"}, broadcastedSubaccounts(clients[2]))
+	// each key signs with its own account sequence
+	for _, client := range clients {
+		assert.Equal(t, uint64(2), client.SignedTxs[0].AccSeq)
+		assert.Equal(t, uint64(3), client.SignedTxs[1].AccSeq)
+	}
+}
+
+func TestTransactionsAreSignedByTheKeyWithTheFewestPending(t *testing.T) {
+	clients := createSigningClients(t,
+		"inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku",
+		"inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r",
+	)
+	liquidatorService := liquidatorSvc{
+		chainClient:    clients[0],
+		logger:         log.WithField("svc", "liquidator"),
+		txTracker:      txtracker.NewTracker(clients[0], time.Second, time.Millisecond),
+		signers:        newSignersOf(clients),
+		signerSettings: SignerSettings{Selection: SignerSelectionLeastPending},
+	}
+
+	first, _ := liquidatorService.broadcast(0, &exchangetypes.MsgLiquidatePosition{SubaccountId: "a"})
+	second, _ := liquidatorService.broadcast(0, &exchangetypes.MsgLiquidatePosition{SubaccountId: "b"})
+	assert.NotNil(t, first)
+	assert.NotNil(t, second)
+
+	// the second key transaction is confirmed while the first key one is still pending
+	assert.Equal(t, txtracker.OutcomeSuccess, liquidatorService.waitTx(context.Background(), second).Outcome)
+	for _, subaccountID := range []string{"c", "d"} {
+		tx, _ := liquidatorService.broadcast(0, &exchangetypes.MsgLiquidatePosition{SubaccountId: subaccountID})
+		assert.Same(t, liquidatorService.signers.signers[1], tx.signer)
+	}
+
+	assert.Equal(t, []string{"a"}, broadcastedSubaccounts(clients[0]))
+	assert.Equal(t, []string{"b", "c", "d"}, broadcastedSubaccounts(clients[1]))
+	assert.Equal(t, int64(1), liquidatorService.signers.signers[0].pending.Load())
+	assert.Equal(t, int64(2), liquidatorService.signers.signers[1].pending.Load())
+}
+
+func TestKeysBelowTheMinimumBalanceAreTakenOutOfRotation(t *testing.T) {
+	clients := createSigningClients(t,
+		"inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku",
+		"inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r",
+	)
+	clients[0].Balances = map[string]math.Int{
+		"inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku": math.NewInt(1e18),
+		"inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r": math.NewInt(1e16),
+	}
+	liquidatorService := liquidatorSvc{
+		chainClient: clients[0],
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(clients[0], time.Second, time.Millisecond),
+		signers:     newSignersOf(clients),
+		signerSettings: SignerSettings{
+			Selection:  SignerSelectionRoundRobin,
+			MinBalance: math.NewInt(1e17),
+		},
+	}
+
+	liquidatorService.checkSignerBalances(context.Background())
+	for _, subaccountID := range []string{"a", "b"} {
+		result := liquidatorService.broadcastAndWait(context.Background(), 0, &exchangetypes.MsgLiquidatePosition{SubaccountId: subaccountID})
+		assert.Equal(t, txtracker.OutcomeSuccess, result.Outcome)
+	}
+	assert.Equal(t, []string{"a", "b"}, broadcastedSubaccounts(clients[0]))
+	assert.Empty(t, broadcastedSubaccounts(clients[1]))
+
+	// no transaction is sent once every key is below the minimum
+	clients[0].Balances["inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku"] = math.NewInt(1e16)
+	liquidatorService.checkSignerBalances(context.Background())
+	result := liquidatorService.broadcastAndWait(context.Background(), 0, &exchangetypes.MsgLiquidatePosition{SubaccountId: "c"})
+	assert.Equal(t, txtracker.OutcomeFailed, result.Outcome)
+
+	// the funded key is back in rotation
+	clients[0].Balances["inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r"] = math.NewInt(1e18)
+	liquidatorService.checkSignerBalances(context.Background())
+	result = liquidatorService.broadcastAndWait(context.Background(), 0, &exchangetypes.MsgLiquidatePosition{SubaccountId: "d"})
+	assert.Equal(t, txtracker.OutcomeSuccess, result.Outcome)
+	assert.Equal(t, []string{"d"}, broadcastedSubaccounts(clients[1]))
+}
+
+func TestGrantsOfEverySigningKeyAreChecked(t *testing.T) {
+	clients := createSigningClients(t,
+		"inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku",
+		"inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r",
+	)
+	expiration := time.Now().Add(100 * time.Hour)
+	clients[0].Grants = map[string][]*authz.Grant{
+		"inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku" + grants.MsgLiquidatePosition: {{Expiration: &expiration}},
+	}
+	monitor := grants.NewMonitor([]time.Duration{720 * time.Hour, 24 * time.Hour})
+	liquidatorService := liquidatorSvc{
+		chainClient:          clients[0],
+		granterPublicAddress: "inj1cml96vmptgw99syqrrz8az79xer2pcgp0a885r",
+		logger:               log.WithField("svc", "liquidator"),
+		signers:              newSignersOf(clients),
+		grantSettings:        GrantSettings{Monitor: monitor},
+	}
+
+	// the second key has no grant
+	err := liquidatorService.checkGrants(context.Background())
+	assert.ErrorContains(t, err, "1 grants from inj1cml96vmptgw99syqrrz8az79xer2pcgp0a885r missing or expired")
+
+	clients[0].Grants["inj1hkhdaj2a2clmq5jq6mspsggqs32vynpk228q3r"+grants.MsgLiquidatePosition] = []*authz.Grant{{}}
+	assert.NoError(t, liquidatorService.checkGrants(context.Background()))
+
+	// the expiration closer than the 720h threshold was already warned
+	_, crossed := monitor.Crossed(grants.Grant{
+		Grantee:    "inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku",
+		MsgType:    grants.MsgLiquidatePosition,
+		Found:      true,
+		Expiration: &expiration,
+	})
+	assert.False(t, crossed)
+
+	// the unwind orders need their own grants
+	expired := time.Now().Add(-time.Hour)
+	clients[0].Grants["inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku"+grants.MsgLiquidatePosition] = []*authz.Grant{{Expiration: &expired}}
+	liquidatorService.unwindSettings.Policy, _ = unwind.ParsePolicy("market", math.LegacyNewDec(100))
+	err = liquidatorService.checkGrants(context.Background())
+	assert.ErrorContains(t, err, "5 grants")
+}
+
+func TestStartFailsWhenTheGrantsCannotBeChecked(t *testing.T) {
+	mockExchange := exchange.MockExchangeClient{}
+	btcUsdtDerivativeMarketInfo := createBTCUSDTDerivativeMarketInfo()
+	mockExchange.SpotMarketsResponses = append(mockExchange.SpotMarketsResponses, &spotExchangePB.MarketsResponse{
+		Markets: []*spotExchangePB.SpotMarketInfo{},
+	})
+	mockExchange.DerivativeMarketsResponses = append(mockExchange.DerivativeMarketsResponses, &derivativeExchangePB.MarketsResponse{
+		Markets: []*derivativeExchangePB.DerivativeMarketInfo{btcUsdtDerivativeMarketInfo},
+	})
+	marketAssistant, err := chain.NewMarketsAssistantInitializedFromChain(context.Background(), &mockExchange)
+	assert.NoError(t, err)
+
+	clients := createSigningClients(t, "inj14au322k9munkmx5wrchz9q30juf5wjgz2cfqku")
+	clients[0].GrantsErr = errors.New("connection refused")
+	liquidatorService := NewService(clients[0], &mockExchange, marketAssistant, nil, nil, nil, nil, nil, newSignersOf(clients), Config{
+		Parameters:           Parameters{MarketIDs: []string{btcUsdtDerivativeMarketInfo.MarketId}},
+		GranterPublicAddress: "inj1cml96vmptgw99syqrrz8az79xer2pcgp0a885r",
+	}).(*liquidatorSvc)
+
+	err = liquidatorService.Start(context.Background())
+	assert.ErrorIs(t, err, errGrantsUnchecked)
+	assert.ErrorContains(t, err, "refusing to start")
+	assert.False(t, liquidatorService.Status().GrantsValid)
+}
+
+func TestMarketMinProfitOverridesTheDefault(t *testing.T) {
+	liquidatorService := &liquidatorSvc{
+		minProfit: decimal.NullDecimal{Decimal: decimal.RequireFromString("10"), Valid: true},
+		marketMinProfit: map[string]decimal.Decimal{
+			"btcMarket": decimal.RequireFromString("1"),
+		},
+	}
+
+	usdt := core.Token{Decimals: 6}
+	estimate := profitability.Estimate{Profit: math.LegacyMustNewDecFromStr("5000000")}
+
+	// 5 USDT passes the 1 USDT override, not the 10 USDT default
+	assert.True(t, liquidatorService.isProfitable(estimate, core.DerivativeMarket{Id: "btcMarket", QuoteToken: usdt}))
+	assert.False(t, liquidatorService.isProfitable(estimate, core.DerivativeMarket{Id: "ethMarket", QuoteToken: usdt}))
+
+	// the override enables the gate of its market only
+	liquidatorService.minProfit = decimal.NullDecimal{}
+	estimate.Profit = math.LegacyZeroDec()
+	assert.False(t, liquidatorService.isProfitable(estimate, core.DerivativeMarket{Id: "btcMarket", QuoteToken: usdt}))
+	assert.True(t, liquidatorService.isProfitable(estimate, core.DerivativeMarket{Id: "ethMarket", QuoteToken: usdt}))
+}
+
+func TestReloadedParametersAreAppliedWithTheirChanges(t *testing.T) {
+	mockExchange := exchange.MockExchangeClient{}
+
+	btcUsdtDerivativeMarketInfo := createBTCUSDTDerivativeMarketInfo()
+	ethUsdtDerivativeMarketInfo := createETHUSDTExpiryDerivativeMarketInfo()
+
+	mockExchange.SpotMarketsResponses = append(mockExchange.SpotMarketsResponses, &spotExchangePB.MarketsResponse{
+		Markets: []*spotExchangePB.SpotMarketInfo{},
+	})
+	mockExchange.DerivativeMarketsResponses = append(mockExchange.DerivativeMarketsResponses, &derivativeExchangePB.MarketsResponse{
+		Markets: []*derivativeExchangePB.DerivativeMarketInfo{btcUsdtDerivativeMarketInfo, ethUsdtDerivativeMarketInfo},
+	})
+
+	marketAssistant, err := chain.NewMarketsAssistantInitializedFromChain(context.Background(), &mockExchange)
+	assert.NoError(t, err)
+
+	markPolicy, err := pricing.ParsePolicy(pricing.PolicyMark, &mockExchange)
+	assert.NoError(t, err)
+	unlimited := MarketLimits{
+		MaxOrderAmount:   math.LegacyMaxSortableDec,
+		MaxOrderNotional: math.LegacyMaxSortableDec,
+	}
+
+	sharedInventory := inventory.New(inventory.Limits{})
+	liquidatorService := NewService(nil, &mockExchange, marketAssistant, nil, nil, nil, sharedInventory, nil, nil, Config{
+		Parameters: Parameters{
+			MarketIDs:      []string{btcUsdtDerivativeMarketInfo.MarketId},
+			DefaultLimits:  unlimited,
+			DefaultPricing: markPolicy,
+		},
+	}).(*liquidatorSvc)
+
+	// unknown markets are rejected, the current parameters stay active
+	err = liquidatorService.Reload(Parameters{MarketIDs: []string{"0xunknown"}, DefaultLimits: unlimited, DefaultPricing: markPolicy})
+	assert.Error(t, err)
+
+	err = liquidatorService.Reload(Parameters{
+		MarketIDs:     []string{btcUsdtDerivativeMarketInfo.MarketId, ethUsdtDerivativeMarketInfo.MarketId},
+		DefaultLimits: unlimited,
+		MarketLimits: map[string]MarketLimits{
+			ethUsdtDerivativeMarketInfo.MarketId: {
+				MaxOrderAmount:   math.LegacyMaxSortableDec,
+				MaxOrderNotional: math.LegacyMustNewDecFromStr("1000"),
+			},
+		},
+		DefaultPricing: markPolicy,
+		MinProfit:      decimal.NullDecimal{Decimal: decimal.RequireFromString("5"), Valid: true},
+		MarketMinProfit: map[string]decimal.Decimal{
+			btcUsdtDerivativeMarketInfo.MarketId: decimal.RequireFromString("1"),
+		},
+		ExposureLimits: inventory.Limits{MaxTotalExposure: math.LegacyNewDec(50000)},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, liquidatorService.reloads, 1)
+
+	// the changes are only applied by the service loop
+	assert.Len(t, liquidatorService.marketIDs, 1)
+
+	changes := liquidatorService.parameters().diff(*liquidatorService.pendingParams.Load())
+	assert.Equal(t, []string{
+		"market IDs: " + btcUsdtDerivativeMarketInfo.MarketId + " -> " + btcUsdtDerivativeMarketInfo.MarketId + "," + ethUsdtDerivativeMarketInfo.MarketId,
+		"min profit: none -> 5",
+		"max total exposure: none -> 50000.000000000000000000",
+		"max order notional of " + ethUsdtDerivativeMarketInfo.MarketId + ": none -> 1000.000000000000000000",
+		"min profit of " + ethUsdtDerivativeMarketInfo.MarketId + ": none -> 5",
+		"min profit of " + btcUsdtDerivativeMarketInfo.MarketId + ": none -> 1",
+	}, changes)
+
+	markets, marketsChanged, err := liquidatorService.applyParameters()
+	assert.NoError(t, err)
+	assert.True(t, marketsChanged)
+	assert.Len(t, markets, 2)
+	assert.Equal(t, "1000.000000000000000000", liquidatorService.limitsForMarket(ethUsdtDerivativeMarketInfo.MarketId).MaxOrderNotional.String())
+	assert.Nil(t, liquidatorService.pendingParams.Load())
+	// the exposure limits apply to the inventory shared by the services
+	assert.Equal(t, "50000.000000000000000000", sharedInventory.Limits().MaxTotalExposure.String())
+	minProfit, enabled := liquidatorService.minProfitForMarket(btcUsdtDerivativeMarketInfo.MarketId)
+	assert.True(t, enabled)
+	assert.Equal(t, "1", minProfit.String())
+
+	statuses := liquidatorService.marketStatuses(markets)
+	assert.Equal(t, "none", statuses[0].MaxOrderNotional)
+	assert.Equal(t, "1000.000000000000000000", statuses[1].MaxOrderNotional)
+	assert.Equal(t, "mark", statuses[1].PricingPolicy)
+}
+
+func TestStatusReportsTheRecentOutcomes(t *testing.T) {
+	mockChain := LocalMockChainClient{
+		FailingSubaccountIDs: map[string]bool{"c": true},
+	}
+	liquidatorService := liquidatorSvc{
+		chainClient: &mockChain,
+		logger:      log.WithField("svc", "liquidator"),
+		txTracker:   txtracker.NewTracker(&mockChain, time.Second, time.Millisecond),
+		batchSettings: BatchSettings{
+			MaxMessages: 10,
+		},
+	}
+
+	liquidatorService.broadcastLiquidations(context.Background(), createPendingLiquidations("a", "b", "c", "d", "e"))
+	liquidatorService.pipeline.wait()
+
+	status := liquidatorService.Status()
+	assert.Zero(t, status.PendingTxs)
+	assert.Empty(t, status.PendingLiquidations)
+
+	// the failed liquidation is reported first, the retried batch after
+	var subaccountIDs []string
+	for _, outcome := range status.RecentOutcomes {
+		subaccountIDs = append(subaccountIDs, outcome.SubaccountID)
+	}
+	assert.Equal(t, []string{"e", "d", "b", "a", "c"}, subaccountIDs)
+	assert.Equal(t, txtracker.OutcomeSuccess, status.RecentOutcomes[0].Outcome)
+	assert.NotEqual(t, txtracker.OutcomeSuccess, status.RecentOutcomes[4].Outcome)
+
+	// only the most recent outcomes are kept
+	for i := 0; i < recentOutcomesSize; i++ {
+		liquidatorService.status.recordOutcome(OutcomeStatus{SubaccountID: "f", Outcome: txtracker.OutcomeSuccess})
+	}
+	liquidatorService.status.recordOutcome(OutcomeStatus{SubaccountID: "g", Outcome: txtracker.OutcomeSuccess})
+
+	status = liquidatorService.Status()
+	assert.Len(t, status.RecentOutcomes, recentOutcomesSize)
+	assert.Equal(t, "g", status.RecentOutcomes[0].SubaccountID)
+	assert.Equal(t, "f", status.RecentOutcomes[recentOutcomesSize-1].SubaccountID)
+}
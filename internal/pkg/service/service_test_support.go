@@ -1,14 +1,29 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
 	"cosmossdk.io/math"
 	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
 	"github.com/InjectiveLabs/sdk-go/client/chain"
 	derivativeExchangePB "github.com/InjectiveLabs/sdk-go/exchange/derivative_exchange_rpc/pb"
+	"github.com/cosmos/cosmos-sdk/client"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/tx"
+	signingtypes "github.com/cosmos/cosmos-sdk/types/tx/signing"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	eth "github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func createUSDTPerpTokenMeta() derivativeExchangePB.TokenMeta {
@@ -63,18 +78,111 @@ func createBTCUSDTDerivativeMarketInfo() *derivativeExchangePB.DerivativeMarketI
 	return &marketInfo
 }
 
+func createETHUSDTExpiryDerivativeMarketInfo() *derivativeExchangePB.DerivativeMarketInfo {
+	usdtPerpTokenMeta := createUSDTPerpTokenMeta()
+
+	expiryMarketInfo := derivativeExchangePB.ExpiryFuturesMarketInfo{
+		ExpirationTimestamp: 1735689600,
+		SettlementPrice:     "0",
+	}
+
+	marketInfo := derivativeExchangePB.DerivativeMarketInfo{
+		MarketId:                "0x1c79dac019f73e4060494ab1b4fcba734350656d6fc4d474f6a238c13c6f9ced",
+		MarketStatus:            "active",
+		Ticker:                  "ETH/USDT 31DEC24",
+		OracleBase:              "ETH",
+		OracleQuote:             "USDT",
+		OracleType:              "bandibc",
+		OracleScaleFactor:       6,
+		InitialMarginRatio:      "0.095",
+		MaintenanceMarginRatio:  "0.05",
+		QuoteDenom:              "peggy0xdAC17F958D2ee523a2206206994597C13D831ec7",
+		QuoteTokenMeta:          &usdtPerpTokenMeta,
+		MakerFeeRate:            "-0.0001",
+		TakerFeeRate:            "0.001",
+		ServiceProviderFee:      "0.4",
+		IsPerpetual:             false,
+		MinPriceTickSize:        "100000",
+		MinQuantityTickSize:     "0.01",
+		ExpiryFuturesMarketInfo: &expiryMarketInfo,
+	}
+
+	return &marketInfo
+}
+
 type LocalMockChainClient struct {
 	chain.MockChainClient
+	// mu guards the broadcast state, the transactions are confirmed concurrently
+	mu                  sync.Mutex
 	FromAddresses       []sdk.AccAddress
 	BroadcastedMessages []sdk.Msg
+	BroadcastedTxs      [][]sdk.Msg
+	// FailingSubaccountIDs makes the transactions liquidating any of these subaccounts fail
+	FailingSubaccountIDs map[string]bool
+	// FailWithoutMessageIndex hides the index of the failing message in the broadcast errors
+	FailWithoutMessageIndex bool
+	SimulatedGas            uint64
+	SignedTxs               []SignedTx
+	// Deposits are the subaccount deposits returned by FetchSubaccountDeposits
+	Deposits            map[string]*exchangetypes.Deposit
+	QueriedSubaccountID string
+	// MarkPrices are the markets mark price returned by FetchChainDerivativeMarket
+	MarkPrices map[string]math.LegacyDec
+	// ExpectedSequence rejects the signed transactions with another sequence, like the mempool does (not checked when zero)
+	ExpectedSequence uint64
+	// HoldConfirmations keeps the broadcast transactions out of the blocks until ConfirmTxs is called
+	HoldConfirmations bool
+	// Balances are the INJ balances by address returned by GetBankBalance
+	Balances map[string]math.Int
+	// Grants are the authz grants by grantee and message type returned by GetAuthzGrants
+	Grants map[string][]*authz.Grant
+	// GrantsErr fails GetAuthzGrants when set
+	GrantsErr error
+	// SimulatedSequences are the sequences of the simulated transactions
+	SimulatedSequences []uint64
+
+	txServiceOnce sync.Once
+	txServiceConn *grpc.ClientConn
 }
 
+// SignedTx records the parameters of the transactions built with BuildSignedTx.
+type SignedTx struct {
+	AccSeq   uint64
+	GasLimit uint64
+	Msgs     []sdk.Msg
+}
+
+// FromAddress returns the next address of FromAddresses, the last one is kept once the others are used.
 func (c *LocalMockChainClient) FromAddress() sdk.AccAddress {
+	if len(c.FromAddresses) == 0 {
+		return nil
+	}
 	address := c.FromAddresses[0]
-	c.FromAddresses = c.FromAddresses[1:len(c.FromAddresses)]
+	if len(c.FromAddresses) > 1 {
+		c.FromAddresses = c.FromAddresses[1:]
+	}
 	return address
 }
 
+func (c *LocalMockChainClient) GetBankBalance(_ context.Context, address, denom string) (*banktypes.QueryBalanceResponse, error) {
+	balance, found := c.Balances[address]
+	if !found {
+		return nil, errors.Errorf("no %s balance for %s", denom, address)
+	}
+	return &banktypes.QueryBalanceResponse{Balance: &sdk.Coin{Denom: denom, Amount: balance}}, nil
+}
+
+func (c *LocalMockChainClient) GetAuthzGrants(_ context.Context, req authz.QueryGrantsRequest) (*authz.QueryGrantsResponse, error) {
+	if c.GrantsErr != nil {
+		return nil, c.GrantsErr
+	}
+	grants, found := c.Grants[req.Grantee+req.MsgTypeUrl]
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "no authorization found for %s type", req.MsgTypeUrl)
+	}
+	return &authz.QueryGrantsResponse{Grants: grants}, nil
+}
+
 func (c *LocalMockChainClient) CreateDerivativeOrder(defaultSubaccountID eth.Hash, d *chain.DerivativeOrderData, marketAssistant chain.MarketsAssistant) *exchangetypes.DerivativeOrder {
 	market, isPresent := marketAssistant.AllDerivativeMarkets()[d.MarketId]
 	if !isPresent {
@@ -103,7 +211,191 @@ func (c *LocalMockChainClient) CreateDerivativeOrder(defaultSubaccountID eth.Has
 	}
 }
 
-func (c *LocalMockChainClient) SyncBroadcastMsg(msgs ...sdk.Msg) (*tx.BroadcastTxResponse, error) {
-	c.BroadcastedMessages = append(c.BroadcastedMessages, msgs...)
-	return &tx.BroadcastTxResponse{}, nil
+func (c *LocalMockChainClient) GetTx(_ context.Context, txHash string) (*tx.GetTxResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.HoldConfirmations {
+		return nil, errors.Errorf("tx %s not found", txHash)
+	}
+	return &tx.GetTxResponse{TxResponse: &sdk.TxResponse{TxHash: txHash, Height: 1}}, nil
+}
+
+// ConfirmTxs includes the held transactions, and the next ones, in a block.
+func (c *LocalMockChainClient) ConfirmTxs() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.HoldConfirmations = false
+}
+
+// SignedTxsCount returns the number of transactions signed so far.
+func (c *LocalMockChainClient) SignedTxsCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.SignedTxs)
+}
+
+func (c *LocalMockChainClient) FetchSubaccountDeposits(_ context.Context, subaccountID string) (*exchangetypes.QuerySubaccountDepositsResponse, error) {
+	c.QueriedSubaccountID = subaccountID
+	return &exchangetypes.QuerySubaccountDepositsResponse{Deposits: c.Deposits}, nil
+}
+
+func (c *LocalMockChainClient) FetchChainDerivativeMarket(_ context.Context, marketID string) (*exchangetypes.QueryDerivativeMarketResponse, error) {
+	markPrice, found := c.MarkPrices[marketID]
+	if !found {
+		return &exchangetypes.QueryDerivativeMarketResponse{}, nil
+	}
+	return &exchangetypes.QueryDerivativeMarketResponse{
+		Market: &exchangetypes.FullDerivativeMarket{
+			Market:    &exchangetypes.DerivativeMarket{MarketId: marketID},
+			MarkPrice: markPrice,
+		},
+	}, nil
+}
+
+var (
+	mockTxConfigOnce sync.Once
+	mockTxConfig     client.TxConfig
+)
+
+func (c *LocalMockChainClient) ClientContext() client.Context {
+	mockTxConfigOnce.Do(func() {
+		mockTxConfig = chain.NewTxConfig([]signingtypes.SignMode{signingtypes.SignMode_SIGN_MODE_DIRECT})
+	})
+	return client.Context{ChainID: "injective-mock", TxConfig: mockTxConfig}
+}
+
+// QueryClient returns a connection to an in-memory tx service simulating the transactions.
+func (c *LocalMockChainClient) QueryClient() *grpc.ClientConn {
+	c.txServiceOnce.Do(func() {
+		listener := bufconn.Listen(1 << 20)
+		server := grpc.NewServer()
+		tx.RegisterServiceServer(server, &mockTxService{chainClient: c})
+		go func() { _ = server.Serve(listener) }()
+
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			panic(err)
+		}
+		c.txServiceConn = conn
+	})
+	return c.txServiceConn
+}
+
+// mockTxService simulates the transactions like the chain: the sequence must be the expected one, and the
+// transactions including a failing liquidation fail.
+type mockTxService struct {
+	tx.UnimplementedServiceServer
+	chainClient *LocalMockChainClient
+}
+
+func (s *mockTxService) Simulate(_ context.Context, req *tx.SimulateRequest) (*tx.SimulateResponse, error) {
+	c := s.chainClient
+	decodedTx, err := mockTxConfig.TxDecoder()(req.TxBytes)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	signatures, err := decodedTx.(authsigning.SigVerifiableTx).GetSignaturesV2()
+	if err != nil || len(signatures) != 1 {
+		return nil, status.Error(codes.InvalidArgument, "expected one signature")
+	}
+	sequence := signatures[0].Sequence
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ExpectedSequence != 0 && sequence != c.ExpectedSequence {
+		return nil, status.Errorf(codes.Unknown, "account sequence mismatch, expected %d, got %d: incorrect account sequence", c.ExpectedSequence, sequence)
+	}
+	c.SimulatedSequences = append(c.SimulatedSequences, sequence)
+
+	for index, msg := range decodedTx.GetMsgs() {
+		if err := c.executionError(msg); err != nil {
+			return nil, status.Errorf(codes.Unknown, "failed to execute message; message index: %d: %s", index, err.Error())
+		}
+	}
+	return &tx.SimulateResponse{GasInfo: &sdk.GasInfo{GasUsed: c.SimulatedGas}}, nil
+}
+
+// executionError returns the error of a failing liquidation, reported like the authz module (without the inner
+// message index) when the liquidation is wrapped in an authz execution.
+func (c *LocalMockChainClient) executionError(msg sdk.Msg) error {
+	subaccountID, isLiquidation := liquidatedSubaccount(msg)
+	if !isLiquidation || !c.FailingSubaccountIDs[subaccountID] {
+		return nil
+	}
+	if execMsg, isExec := msg.(*authz.MsgExec); isExec {
+		return errors.Errorf("failed to execute message; message %v: %s", execMsg.Msgs[0], exchangetypes.ErrPositionNotLiquidable.Error())
+	}
+	return exchangetypes.ErrPositionNotLiquidable
+}
+
+// liquidatedSubaccount returns the subaccount of a liquidation message, unwrapping it from an authz execution.
+func liquidatedSubaccount(msg sdk.Msg) (string, bool) {
+	switch typedMsg := msg.(type) {
+	case *exchangetypes.MsgLiquidatePosition:
+		return typedMsg.SubaccountId, true
+	case *authz.MsgExec:
+		if len(typedMsg.Msgs) != 1 || typedMsg.Msgs[0].TypeUrl != sdk.MsgTypeURL(&exchangetypes.MsgLiquidatePosition{}) {
+			return "", false
+		}
+		liquidationMsg := exchangetypes.MsgLiquidatePosition{}
+		if err := liquidationMsg.Unmarshal(typedMsg.Msgs[0].Value); err != nil {
+			return "", false
+		}
+		return liquidationMsg.SubaccountId, true
+	}
+	return "", false
+}
+
+// BuildSignedTx fails like the chain client gas estimation when the simulated transaction includes a failing liquidation.
+func (c *LocalMockChainClient) BuildSignedTx(clientCtx client.Context, _, accSeq, initialGas uint64, msgs ...sdk.Msg) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if clientCtx.Simulate {
+		for index, msg := range msgs {
+			if err := c.executionError(msg); err != nil {
+				c.BroadcastedTxs = append(c.BroadcastedTxs, msgs)
+				if c.FailWithoutMessageIndex {
+					subaccountID, _ := liquidatedSubaccount(msg)
+					return nil, errors.Errorf("failed to liquidate subaccount %s", subaccountID)
+				}
+				return nil, errors.Errorf("failed to CalculateGas: failed to execute message; message index: %d: %s", index, err.Error())
+			}
+		}
+	}
+
+	c.SignedTxs = append(c.SignedTxs, SignedTx{AccSeq: accSeq, GasLimit: initialGas, Msgs: msgs})
+	return []byte(fmt.Sprintf("tx%d", len(c.SignedTxs))), nil
+}
+
+func (c *LocalMockChainClient) AsyncBroadcastSignedTx(txBytes []byte) (*tx.BroadcastTxResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var index int
+	if _, err := fmt.Sscanf(string(txBytes), "tx%d", &index); err != nil {
+		return nil, err
+	}
+	signedTx := c.SignedTxs[index-1]
+
+	if c.ExpectedSequence != 0 {
+		if signedTx.AccSeq != c.ExpectedSequence {
+			return &tx.BroadcastTxResponse{TxResponse: &sdk.TxResponse{
+				Code:   32,
+				RawLog: fmt.Sprintf("account sequence mismatch, expected %d, got %d: incorrect account sequence", c.ExpectedSequence, signedTx.AccSeq),
+			}}, nil
+		}
+		c.ExpectedSequence++
+	}
+
+	c.BroadcastedTxs = append(c.BroadcastedTxs, signedTx.Msgs)
+	c.BroadcastedMessages = append(c.BroadcastedMessages, signedTx.Msgs...)
+	return &tx.BroadcastTxResponse{TxResponse: &sdk.TxResponse{TxHash: string(txBytes)}}, nil
 }
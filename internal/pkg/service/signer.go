@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/metrics"
+	"github.com/pkg/errors"
+	log "github.com/xlab/suplog"
+
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+)
+
+const (
+	// SignerSelectionRoundRobin sends the transactions with each key in turn
+	SignerSelectionRoundRobin = "round_robin"
+	// SignerSelectionLeastPending sends the transactions with the key having the fewest unconfirmed transactions
+	SignerSelectionLeastPending = "least_pending"
+)
+
+const gasDenom = "inj"
+
+// SignerSettings configures the pool of grantee keys signing the transactions.
+type SignerSettings struct {
+	// Selection is the strategy distributing the transactions over the keys
+	Selection string
+	// MinBalance is the INJ balance (in chain format) under which a key is taken out of rotation
+	// (not checked when nil or zero)
+	MinBalance math.Int
+	// BalanceCheckInterval is the time between two checks of the keys balance
+	BalanceCheckInterval time.Duration
+}
+
+// signer is a key signing the transactions, with its own chain client and account sequence.
+type signer struct {
+	client   chainclient.ChainClient
+	address  string
+	sequence accountSequence
+	logger   log.Logger
+
+	// pending counts the transactions broadcast and not confirmed yet
+	pending atomic.Int64
+	// lowBalance takes the key out of rotation
+	lowBalance atomic.Bool
+}
+
+// sentTx is a transaction accepted in the mempool.
+type sentTx struct {
+	hash   string
+	signer *signer
+}
+
+// SignerPool is the keys signing the transactions. It is shared by the services signing with the same keys,
+// so each key keeps a single account sequence.
+type SignerPool struct {
+	signers []*signer
+	// turn is the next key in turn
+	turn atomic.Uint64
+}
+
+// NewSignerPool returns the pool of the keys of the chain clients.
+func NewSignerPool(clients ...chainclient.ChainClient) *SignerPool {
+	logger := log.WithField("svc", "liquidator")
+
+	pool := &SignerPool{signers: make([]*signer, 0, len(clients))}
+	for _, client := range clients {
+		address := client.FromAddress().String()
+		pool.signers = append(pool.signers, &signer{
+			client:  client,
+			address: address,
+			logger:  logger.WithField("signer", address),
+		})
+	}
+	return pool
+}
+
+// Primary returns a pool of the first key only, sharing its account sequence with this pool. It signs for the
+// services without a granter, their messages being sent by the first key account.
+func (p *SignerPool) Primary() *SignerPool {
+	return &SignerPool{signers: p.signers[:1]}
+}
+
+// signerPool returns the signing keys. The service chain client key is the only one when no pool is configured.
+func (s *liquidatorSvc) signerPool() *SignerPool {
+	s.signersOnce.Do(func() {
+		if s.signers == nil || len(s.signers.signers) == 0 {
+			s.signers = NewSignerPool(s.chainClient)
+		}
+	})
+	return s.signers
+}
+
+// pickSigner returns the key signing the next transaction, among the keys in rotation.
+func (s *liquidatorSvc) pickSigner() (*signer, error) {
+	pool := s.signerPool()
+	signers := pool.signers
+	// the search starts at the next key in turn, which also breaks the least pending ties
+	start := int((pool.turn.Add(1) - 1) % uint64(len(signers)))
+
+	var picked *signer
+	for i := range signers {
+		candidate := signers[(start+i)%len(signers)]
+		if candidate.lowBalance.Load() {
+			continue
+		}
+		if s.signerSettings.Selection != SignerSelectionLeastPending {
+			return candidate, nil
+		}
+		if picked == nil || candidate.pending.Load() < picked.pending.Load() {
+			picked = candidate
+		}
+	}
+
+	if picked == nil {
+		return nil, errors.New("no signing key in rotation, every key balance is below the minimum")
+	}
+	return picked, nil
+}
+
+// checkSignerBalances fetches the INJ balance of every key, and takes the keys below the minimum balance
+// out of rotation until they are funded again.
+func (s *liquidatorSvc) checkSignerBalances(ctx context.Context) {
+	for _, sg := range s.signerPool().signers {
+		address := sg.address
+		tags := metrics.Tags{"signer": address}
+		for key, value := range s.svcTags {
+			tags[key] = value
+		}
+
+		resp, err := s.chainClient.GetBankBalance(ctx, address, gasDenom)
+		if err != nil || resp.Balance == nil {
+			metrics.ReportClosureFuncError("CheckSignerBalance", tags)
+			s.logger.WithError(err).Warningf("Failed to fetch the balance of the signing key %s", address)
+			continue
+		}
+
+		balance := resp.Balance.Amount
+		metrics.CustomReport(func(statter metrics.Statter, tagSpec []string) {
+			_ = statter.Gauge("signer.balance", math.LegacyNewDecFromInt(balance).QuoInt64(1e18).MustFloat64(), tagSpec, 1)
+			_ = statter.Gauge("signer.pending", float64(sg.pending.Load()), tagSpec, 1)
+		}, tags)
+
+		isLow := !s.signerSettings.MinBalance.IsNil() && balance.LT(s.signerSettings.MinBalance)
+		if wasLow := sg.lowBalance.Swap(isLow); wasLow == isLow {
+			continue
+		}
+		if isLow {
+			s.logger.Warningf("Taking the signing key %s out of rotation, its balance of %s%s is below the minimum of %s%s",
+				address, balance.String(), gasDenom, s.signerSettings.MinBalance.String(), gasDenom)
+		} else {
+			s.logger.Infof("Signing key %s back in rotation with a balance of %s%s", address, balance.String(), gasDenom)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"github.com/InjectiveLabs/metrics"
+	"github.com/pkg/errors"
+
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+)
+
+// SimulationSettings configures the pre-flight simulation of the liquidations.
+type SimulationSettings struct {
+	// Enabled simulates every liquidation before broadcasting it, and sets the transactions gas limit
+	// from the simulated gas instead of relying on the chain client gas estimation
+	Enabled bool
+	// GasMultiplier is applied to the simulated gas to set the gas limit
+	GasMultiplier float64
+}
+
+// simulateLiquidation simulates the liquidation alone and returns the gas limit to use for it.
+func (s *liquidatorSvc) simulateLiquidation(liquidation pendingLiquidation) (gasUsed uint64, gasLimit uint64, err error) {
+	return s.simulateMessages(s.createBatchMessages([]pendingLiquidation{liquidation})...)
+}
+
+// simulateMessages simulates the messages in one transaction and returns the gas limit to use for it. The
+// transaction is simulated with the local sequence of the service chain client key, the first of the pool.
+func (s *liquidatorSvc) simulateMessages(msgs ...sdktypes.Msg) (gasUsed uint64, gasLimit uint64, err error) {
+	metrics.ReportClosureFuncCall("SimulateMsg", s.svcTags)
+	defer metrics.ReportClosureFuncTiming("SimulateMsg", s.svcTags)()
+
+	resp, err := s.signerPool().signers[0].simulateTx(msgs...)
+	if err != nil {
+		return 0, 0, err
+	}
+	if resp.GasInfo == nil {
+		return 0, 0, errors.New("simulation did not return the gas info")
+	}
+
+	gasUsed = resp.GasInfo.GasUsed
+	gasLimit = uint64(float64(gasUsed) * s.simulationSettings.GasMultiplier)
+
+	return gasUsed, gasLimit, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/InjectiveLabs/sdk-go/client/core"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/txtracker"
+)
+
+// recentOutcomesSize is the number of liquidation outcomes kept for the status.
+const recentOutcomesSize = 50
+
+// Status is a snapshot of the service state.
+type Status struct {
+	// SubaccountID is the trading subaccount, the granter one when using a granter account
+	SubaccountID string `json:"subaccount_id"`
+	Granter      string `json:"granter,omitempty"`
+	// Markets are the markets checked, with their active limits and pricing policy
+	Markets []MarketStatus `json:"markets"`
+	// GrantsValid is false when the last check found a missing or expired grant
+	GrantsValid bool `json:"grants_valid"`
+	// LastCheck is when the detector last completed a check of every market
+	LastCheck time.Time `json:"last_check"`
+	// LastCycle is the last processing of liquidation candidates (nil before the first one)
+	LastCycle *CycleStatus `json:"last_cycle,omitempty"`
+	// PendingTxs is the number of liquidation transactions awaiting their confirmation
+	PendingTxs          int                 `json:"pending_txs"`
+	PendingLiquidations []LiquidationStatus `json:"pending_liquidations"`
+	// RecentOutcomes are the last liquidation outcomes, the most recent first
+	RecentOutcomes []OutcomeStatus `json:"recent_outcomes"`
+}
+
+// MarketStatus is a checked market and its active parameters.
+type MarketStatus struct {
+	ID               string `json:"id"`
+	Ticker           string `json:"ticker"`
+	MaxOrderAmount   string `json:"max_order_amount"`
+	MaxOrderNotional string `json:"max_order_notional"`
+	PricingPolicy    string `json:"pricing_policy"`
+}
+
+// CycleStatus is a processing of liquidation candidates.
+type CycleStatus struct {
+	Start      time.Time     `json:"start"`
+	Duration   time.Duration `json:"duration"`
+	Candidates int           `json:"candidates"`
+}
+
+// LiquidationStatus is a liquidation in flight.
+type LiquidationStatus struct {
+	MarketID     string `json:"market_id"`
+	SubaccountID string `json:"subaccount_id"`
+}
+
+// OutcomeStatus is the outcome of a broadcast liquidation.
+type OutcomeStatus struct {
+	Time         time.Time         `json:"time"`
+	MarketID     string            `json:"market_id"`
+	SubaccountID string            `json:"subaccount_id"`
+	TxHash       string            `json:"tx_hash,omitempty"`
+	Outcome      txtracker.Outcome `json:"outcome"`
+}
+
+// statusState holds the parts of the status updated by the service loop and the transaction handlers.
+type statusState struct {
+	mu          sync.Mutex
+	markets     []MarketStatus
+	grantsValid bool
+	lastCheck   time.Time
+	lastCycle   *CycleStatus
+	// outcomes is a ring of the recent outcomes, next being the position of the next one
+	outcomes []OutcomeStatus
+	next     int
+}
+
+func (st *statusState) setMarkets(markets []MarketStatus) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.markets = markets
+}
+
+func (st *statusState) setGrantsValid(valid bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.grantsValid = valid
+}
+
+func (st *statusState) checked(at time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.lastCheck = at
+}
+
+func (st *statusState) cycled(cycle CycleStatus) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.lastCycle = &cycle
+}
+
+func (st *statusState) recordOutcome(outcome OutcomeStatus) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if len(st.outcomes) < recentOutcomesSize {
+		st.outcomes = append(st.outcomes, outcome)
+		return
+	}
+	st.outcomes[st.next] = outcome
+	st.next = (st.next + 1) % recentOutcomesSize
+}
+
+// recentOutcomes returns the outcomes, the most recent first.
+func (st *statusState) recentOutcomes() []OutcomeStatus {
+	outcomes := make([]OutcomeStatus, 0, len(st.outcomes))
+	for i := len(st.outcomes) - 1; i >= 0; i-- {
+		outcomes = append(outcomes, st.outcomes[(st.next+i)%len(st.outcomes)])
+	}
+	return outcomes
+}
+
+// Status returns a snapshot of the service state. It is safe to call while the service runs.
+func (s *liquidatorSvc) Status() Status {
+	inFlight := s.pipeline.inFlight()
+	pending := make([]LiquidationStatus, 0, len(inFlight))
+	for _, liquidation := range inFlight {
+		pending = append(pending, LiquidationStatus{
+			MarketID:     liquidation.msg.MarketId,
+			SubaccountID: liquidation.msg.SubaccountId,
+		})
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].MarketID != pending[j].MarketID {
+			return pending[i].MarketID < pending[j].MarketID
+		}
+		return pending[i].SubaccountID < pending[j].SubaccountID
+	})
+
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+
+	return Status{
+		SubaccountID:        s.tradingSubaccountID().Hex(),
+		Granter:             s.granterPublicAddress,
+		Markets:             append([]MarketStatus{}, s.status.markets...),
+		GrantsValid:         s.status.grantsValid,
+		LastCheck:           s.status.lastCheck,
+		LastCycle:           s.status.lastCycle,
+		PendingTxs:          s.pipeline.pendingTxs(),
+		PendingLiquidations: pending,
+		RecentOutcomes:      s.status.recentOutcomes(),
+	}
+}
+
+// marketStatuses returns the status of the markets with the active parameters. Only used by the service loop.
+func (s *liquidatorSvc) marketStatuses(markets []core.DerivativeMarket) []MarketStatus {
+	statuses := make([]MarketStatus, 0, len(markets))
+	for _, market := range markets {
+		limits := s.limitsForMarket(market.Id)
+		statuses = append(statuses, MarketStatus{
+			ID:               market.Id,
+			Ticker:           market.Ticker,
+			MaxOrderAmount:   FormatLimit(limits.MaxOrderAmount),
+			MaxOrderNotional: FormatLimit(limits.MaxOrderNotional),
+			PricingPolicy:    formatPolicy(s.pricingForMarket(market.Id)),
+		})
+	}
+	return statuses
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/InjectiveLabs/metrics"
+	"github.com/InjectiveLabs/sdk-go/client/core"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	log "github.com/xlab/suplog"
+
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/eligibility"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/txtracker"
+	"github.com/InjectiveLabs/injective-liquidator-bot/internal/pkg/unwind"
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+	chainclient "github.com/InjectiveLabs/sdk-go/client/chain"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+)
+
+// UnwindSettings configures the closing of the positions acquired through the liquidations.
+type UnwindSettings struct {
+	// Policy decides the unwind orders, the positions are not unwound when nil
+	Policy unwind.Policy
+	// Interval is the time between two checks of the positions to unwind
+	Interval time.Duration
+}
+
+// unwindInventory sends the unwind orders for the positions held in the liquidated markets.
+// It runs in the service loop, so the unwind transactions never race with the liquidation ones.
+func (s *liquidatorSvc) unwindInventory(ctx context.Context, marketsByID map[string]core.DerivativeMarket) {
+	states, err := s.fetchTradingPositions(ctx)
+	if err != nil {
+		metrics.ReportClosureFuncError("UnwindInventory", s.svcTags)
+		s.logger.WithError(err).Warningln("Failed to fetch the positions to unwind")
+		return
+	}
+	s.updateInventory(states)
+
+	held := make(map[string]bool)
+	for _, state := range states {
+		market, found := marketsByID[state.MarketId]
+		if !found || state.Position == nil || !state.Position.Quantity.IsPositive() {
+			continue
+		}
+		held[market.Id] = true
+
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.unwindPosition(ctx, market, state.Position); err != nil {
+			metrics.ReportClosureFuncError("UnwindPosition", marketTags(s.svcTags, market.Id))
+			s.logger.WithError(err).Warningf("Failed to unwind the %s position", market.Ticker)
+		}
+	}
+
+	for marketID := range marketsByID {
+		if !held[marketID] {
+			s.unwindSettings.Policy.Reset(marketID)
+			delete(s.unwindOrders, marketID)
+		}
+	}
+}
+
+// unwindPosition sends the reduce only order decided by the unwind policy for the position.
+func (s *liquidatorSvc) unwindPosition(ctx context.Context, market core.DerivativeMarket, position *exchangetypes.Position) error {
+	marketResp, err := s.chainClient.FetchChainDerivativeMarket(ctx, market.Id)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch the chain market")
+	}
+	if marketResp.Market == nil || marketResp.Market.Market == nil {
+		return errors.Errorf("market %s not found in chain", market.Id)
+	}
+
+	order, send := s.unwindSettings.Policy.Next(unwind.Position{
+		MarketID:   market.Id,
+		IsLong:     position.IsLong,
+		Quantity:   position.Quantity,
+		EntryPrice: position.EntryPrice,
+		MarkPrice:  eligibility.MarketStateFromChain(marketResp.Market).MarkPrice,
+	}, time.Now())
+	if !send {
+		return nil
+	}
+
+	order.Quantity = unwindQuantity(order.Quantity, position.Quantity, math.LegacyMustNewDecFromStr(market.MinQuantityTickSize.String()))
+	if !order.IsMarket {
+		if placed, found := s.unwindOrders[market.Id]; found && placed.Quantity.Equal(order.Quantity) && placed.Price.Equal(order.Price) {
+			// the resting order is still up to date
+			return nil
+		}
+	}
+
+	unwindLog := s.logger.WithFields(log.Fields{
+		"market":    market.Ticker,
+		"policy":    s.unwindSettings.Policy.String(),
+		"is_buy":    order.IsBuy,
+		"is_market": order.IsMarket,
+		"quantity":  order.Quantity.String(),
+		"price":     order.Price.String(),
+		"position":  position.Quantity.String(),
+	})
+
+	msg := s.createUnwindMessage(market, order)
+
+	if s.dryRunRecorder != nil {
+		unwindLog.Infoln("Dry run: unwind order not broadcast")
+		return nil
+	}
+
+	var gasLimit uint64
+	if s.simulationSettings.Enabled {
+		if _, gasLimit, err = s.simulateMessages(s.wrapForGranter([]sdktypes.Msg{msg})...); err != nil {
+			return errors.Wrap(err, "unwind order failed in simulation")
+		}
+	}
+
+	result := s.broadcastAndWait(ctx, gasLimit, msg)
+	metrics.ReportClosureFuncStatus("UnwindOrder", marketTags(s.svcTags, market.Id).With("outcome", string(result.Outcome)))
+	if result.Outcome != txtracker.OutcomeSuccess {
+		unwindLog.WithFields(log.Fields{
+			"outcome": result.Outcome,
+			"log":     result.Log,
+		}).Warningln("Unwind order failed")
+		return nil
+	}
+
+	if !order.IsMarket {
+		s.unwindOrders[market.Id] = order
+	}
+	unwindLog.WithField("tx_hash", result.TxHash).Infoln("Unwind order sent")
+
+	return nil
+}
+
+// createUnwindMessage builds the reduce only order of the trading subaccount. Market orders are sent with
+// MsgCreateDerivativeMarketOrder, limit orders replace the subaccount orders in the market with MsgBatchUpdateOrders.
+func (s *liquidatorSvc) createUnwindMessage(market core.DerivativeMarket, order unwind.Order) sdktypes.Msg {
+	senderAddress := s.tradingAccountAddress()
+
+	orderType := exchangetypes.OrderType_SELL
+	if order.IsBuy {
+		orderType = exchangetypes.OrderType_BUY
+	}
+
+	derivativeOrder := s.chainClient.CreateDerivativeOrder(
+		s.tradingSubaccountID(),
+		&chainclient.DerivativeOrderData{
+			OrderType:    orderType,
+			Quantity:     market.QuantityFromChainFormat(order.Quantity),
+			Price:        market.PriceFromChainFormat(order.Price),
+			Leverage:     decimal.RequireFromString("1"),
+			FeeRecipient: senderAddress,
+			MarketId:     market.Id,
+			IsReduceOnly: true,
+			Cid:          uuid.NewString(),
+		},
+		s.marketsAssistant,
+	)
+
+	if order.IsMarket {
+		return &exchangetypes.MsgCreateDerivativeMarketOrder{
+			Sender: senderAddress,
+			Order:  *derivativeOrder,
+		}
+	}
+
+	return &exchangetypes.MsgBatchUpdateOrders{
+		Sender:                         senderAddress,
+		SubaccountId:                   s.tradingSubaccountID().Hex(),
+		DerivativeMarketIdsToCancelAll: []string{market.Id},
+		DerivativeOrdersToCreate:       []*exchangetypes.DerivativeOrder{derivativeOrder},
+	}
+}
+
+// unwindQuantity rounds the order quantity down to the market tick. Orders smaller than one tick
+// are sent for one tick, without exceeding the position quantity.
+func unwindQuantity(quantity, positionQuantity, minQuantityTick math.LegacyDec) math.LegacyDec {
+	rounded := quantity.Quo(minQuantityTick).TruncateDec().Mul(minQuantityTick)
+	if rounded.IsPositive() {
+		return math.LegacyMinDec(rounded, positionQuantity)
+	}
+	return math.LegacyMinDec(minQuantityTick, positionQuantity)
+}
@@ -0,0 +1,182 @@
+// Package telemetry holds the liquidation metrics exported in the Prometheus format. The metrics are
+// always recorded, and only served when the Prometheus endpoint is enabled.
+package telemetry
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/xlab/suplog"
+)
+
+const namespace = "liquidator"
+
+// shutdownTimeout bounds the wait for the scrapes being served when the server closes.
+const shutdownTimeout = 5 * time.Second
+
+var (
+	registry = prometheus.NewRegistry()
+
+	candidates = newCounterVec("candidates_total",
+		"Liquidable positions reported by the detector.", "market_id")
+	skipped = newCounterVec("liquidations_skipped_total",
+		"Liquidation candidates discarded before broadcasting, by reason.", "market_id", "reason")
+	attempted = newCounterVec("liquidations_attempted_total",
+		"Liquidations broadcast.", "market_id")
+	outcomes = newCounterVec("liquidations_total",
+		"Broadcast liquidations by outcome, success or failure reason.", "market_id", "outcome")
+	notional = newCounterVec("liquidated_notional_total",
+		"Notional of the executed liquidation orders, in quote asset.", "market_id")
+	gasUsed = newCounterVec("gas_used_total",
+		"Gas used by the transactions sent by the bot.")
+
+	inclusionLatency = newHistogramVec("detection_to_inclusion_seconds",
+		"Time from the detection of a liquidable position to the inclusion of its liquidation in a block.",
+		[]float64{0.5, 1, 2, 3, 5, 10, 20, 30, 60}, "market_id")
+	profit = newHistogramVec("liquidation_expected_profit",
+		"Expected profit of the executed liquidations, in quote asset.",
+		[]float64{-10, 0, 1, 5, 10, 50, 100, 500, 1000, 5000}, "market_id")
+
+	inventoryQuantity = newGaugeVec("inventory_net_quantity",
+		"Net position acquired through the liquidations, in base asset.", "market_id")
+	inventoryExposure = newGaugeVec("inventory_exposure",
+		"Notional of the net position acquired through the liquidations, in quote asset.", "market_id")
+	totalExposure = newGaugeVec("inventory_total_exposure",
+		"Sum of the net position notionals of the markets of a subaccount, in quote asset.", "subaccount_id")
+	grantExpiry = newGaugeVec("grant_time_to_expiry_seconds",
+		"Time left before an authz grant expires.", "granter", "grantee", "msg_type")
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+func newCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help}, labels)
+	registry.MustRegister(counter)
+	return counter
+}
+
+func newGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help}, labels)
+	registry.MustRegister(gauge)
+	return gauge
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: namespace, Name: name, Help: help, Buckets: buckets}, labels)
+	registry.MustRegister(histogram)
+	return histogram
+}
+
+// CandidatesSeen counts the liquidable positions reported by the detector in a market.
+func CandidatesSeen(marketID string, count int) {
+	candidates.WithLabelValues(marketID).Add(float64(count))
+}
+
+// LiquidationSkipped counts a liquidation candidate discarded for the reason.
+func LiquidationSkipped(marketID string, reason string) {
+	skipped.WithLabelValues(marketID, reason).Inc()
+}
+
+// LiquidationAttempted counts a broadcast liquidation.
+func LiquidationAttempted(marketID string) {
+	attempted.WithLabelValues(marketID).Inc()
+}
+
+// LiquidationOutcome counts the outcome of a broadcast liquidation.
+func LiquidationOutcome(marketID string, outcome string) {
+	outcomes.WithLabelValues(marketID, outcome).Inc()
+}
+
+// LiquidationExecuted records the notional and expected profit (in quote asset) of an executed liquidation,
+// and the time from the detection of the position to the inclusion of the liquidation.
+func LiquidationExecuted(marketID string, orderNotional, expectedProfit float64, detectionToInclusion time.Duration) {
+	notional.WithLabelValues(marketID).Add(orderNotional)
+	profit.WithLabelValues(marketID).Observe(expectedProfit)
+	inclusionLatency.WithLabelValues(marketID).Observe(detectionToInclusion.Seconds())
+}
+
+// GasUsed counts the gas used by a transaction included in a block.
+func GasUsed(gas int64) {
+	if gas > 0 {
+		gasUsed.WithLabelValues().Add(float64(gas))
+	}
+}
+
+// MarketInventory records the net position (in base asset) and exposure (in quote asset) of a market.
+func MarketInventory(marketID string, quantity, exposure float64) {
+	inventoryQuantity.WithLabelValues(marketID).Set(quantity)
+	inventoryExposure.WithLabelValues(marketID).Set(exposure)
+}
+
+// TotalExposure records the exposure (in quote asset) of the markets liquidated by a subaccount.
+func TotalExposure(subaccountID string, exposure float64) {
+	totalExposure.WithLabelValues(subaccountID).Set(exposure)
+}
+
+// GrantTimeToExpiry records the time left before a grant expires.
+func GrantTimeToExpiry(granter, grantee, msgType string, left time.Duration) {
+	grantExpiry.WithLabelValues(granter, grantee, msgType).Set(left.Seconds())
+}
+
+// Handler returns the handler serving the metrics in the Prometheus format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Server serves the metrics on /metrics.
+type Server struct {
+	server *http.Server
+	logger log.Logger
+}
+
+// NewServer returns a server listening on listenAddr once started.
+func NewServer(listenAddr string) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	return &Server{
+		server: &http.Server{
+			Addr:              listenAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		logger: log.WithField("svc", "telemetry"),
+	}
+}
+
+// Start listens on the server address and serves the scrapes in the background.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", s.server.Addr)
+	}
+
+	s.logger.Infof("Serving the Prometheus metrics on %s/metrics", listener.Addr().String())
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.WithError(err).Errorln("Prometheus metrics server stopped")
+		}
+	}()
+	return nil
+}
+
+// Close stops the server, waiting for the scrapes being served.
+func (s *Server) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.WithError(err).Warningln("Failed to stop the Prometheus metrics server")
+	}
+}
@@ -0,0 +1,45 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsAreServedInThePrometheusFormat(t *testing.T) {
+	CandidatesSeen("0xmarket", 2)
+	LiquidationSkipped("0xmarket", "unprofitable")
+	LiquidationAttempted("0xmarket")
+	LiquidationOutcome("0xmarket", "success")
+	LiquidationExecuted("0xmarket", 1500, 12.5, 2500*time.Millisecond)
+	GasUsed(250000)
+	MarketInventory("0xmarket", -0.5, 1500)
+	TotalExposure("0xsubaccount", 1500)
+	GrantTimeToExpiry("inj1granter", "inj1grantee", "/injective.exchange.v1beta1.MsgLiquidatePosition", time.Hour)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	body := recorder.Body.String()
+	for _, sample := range []string{
+		`liquidator_candidates_total{market_id="0xmarket"} 2`,
+		`liquidator_liquidations_skipped_total{market_id="0xmarket",reason="unprofitable"} 1`,
+		`liquidator_liquidations_attempted_total{market_id="0xmarket"} 1`,
+		`liquidator_liquidations_total{market_id="0xmarket",outcome="success"} 1`,
+		`liquidator_liquidated_notional_total{market_id="0xmarket"} 1500`,
+		`liquidator_detection_to_inclusion_seconds_bucket{market_id="0xmarket",le="3"} 1`,
+		`liquidator_detection_to_inclusion_seconds_bucket{market_id="0xmarket",le="2"} 0`,
+		`liquidator_liquidation_expected_profit_sum{market_id="0xmarket"} 12.5`,
+		`liquidator_gas_used_total 250000`,
+		`liquidator_inventory_net_quantity{market_id="0xmarket"} -0.5`,
+		`liquidator_inventory_total_exposure{subaccount_id="0xsubaccount"} 1500`,
+		`liquidator_grant_time_to_expiry_seconds{grantee="inj1grantee",granter="inj1granter",msg_type="/injective.exchange.v1beta1.MsgLiquidatePosition"} 3600`,
+		`go_goroutines`,
+	} {
+		assert.Contains(t, body, sample)
+	}
+}
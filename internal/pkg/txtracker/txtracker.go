@@ -0,0 +1,174 @@
+// Package txtracker follows the broadcast transactions until they are included in a block
+// and classifies their outcome.
+package txtracker
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	errorsmod "cosmossdk.io/errors"
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+)
+
+// Outcome is the classified result of a liquidation transaction.
+type Outcome string
+
+const (
+	OutcomeSuccess             Outcome = "success"
+	OutcomeAlreadyLiquidated   Outcome = "already_liquidated"
+	OutcomeInsufficientBalance Outcome = "insufficient_balance"
+	OutcomeAuthzMissing        Outcome = "authz_missing"
+	OutcomePriceOutOfBounds    Outcome = "price_out_of_bounds"
+	OutcomeOutOfGas            Outcome = "out_of_gas"
+	OutcomeTimeout             Outcome = "timeout"
+	OutcomeFailed              Outcome = "failed"
+)
+
+// knownErrors maps the chain errors to the outcome they are classified as.
+var knownErrors = []struct {
+	err     *errorsmod.Error
+	outcome Outcome
+}{
+	{exchangetypes.ErrPositionNotLiquidable, OutcomeAlreadyLiquidated},
+	{exchangetypes.ErrPositionNotFound, OutcomeAlreadyLiquidated},
+	{exchangetypes.ErrInsufficientDeposit, OutcomeInsufficientBalance},
+	{exchangetypes.ErrInsufficientMargin, OutcomeInsufficientBalance},
+	{sdkerrors.ErrInsufficientFunds, OutcomeInsufficientBalance},
+	{sdkerrors.ErrInsufficientFee, OutcomeInsufficientBalance},
+	{authz.ErrNoAuthorizationFound, OutcomeAuthzMissing},
+	{authz.ErrAuthorizationExpired, OutcomeAuthzMissing},
+	{exchangetypes.ErrPriceSurpassesBankruptcyPrice, OutcomePriceOutOfBounds},
+	{exchangetypes.ErrSlippageExceedsWorstPrice, OutcomePriceOutOfBounds},
+	{exchangetypes.ErrInvalidPrice, OutcomePriceOutOfBounds},
+	{sdkerrors.ErrOutOfGas, OutcomeOutOfGas},
+}
+
+var messageIndexRegexp = regexp.MustCompile(`message index: (\d+)`)
+
+// Result is the outcome of a transaction.
+type Result struct {
+	TxHash  string
+	Outcome Outcome
+	Height  int64
+	GasUsed int64
+	Log     string
+	// MessageIndex is the index of the failed message in the transaction when reported by the chain, -1
+	// otherwise. The authz module does not report the index of a failed message inside an execution.
+	MessageIndex int
+}
+
+// Classify returns the outcome of a transaction result. The codespace and code are used when the chain
+// reports them, otherwise the known errors are looked for in the log (simulation errors only have a log).
+func Classify(codespace string, code uint32, log string) Outcome {
+	if code == 0 && codespace == "" && log == "" {
+		return OutcomeSuccess
+	}
+
+	for _, known := range knownErrors {
+		if codespace != "" && known.err.Codespace() == codespace && known.err.ABCICode() == code {
+			return known.outcome
+		}
+	}
+
+	for _, known := range knownErrors {
+		if strings.Contains(log, known.err.Error()) {
+			return known.outcome
+		}
+	}
+
+	return OutcomeFailed
+}
+
+// ResultFromError classifies an error returned when broadcasting (e.g. a simulation failure).
+func ResultFromError(err error) Result {
+	return Result{
+		Outcome:      Classify("", 0, err.Error()),
+		Log:          err.Error(),
+		MessageIndex: messageIndex(err.Error()),
+	}
+}
+
+// ResultFromResponse classifies the transaction response returned by the chain.
+func ResultFromResponse(resp *sdktypes.TxResponse) Result {
+	result := Result{
+		TxHash:       resp.TxHash,
+		Outcome:      OutcomeSuccess,
+		Height:       resp.Height,
+		GasUsed:      resp.GasUsed,
+		Log:          resp.RawLog,
+		MessageIndex: -1,
+	}
+
+	if resp.Code != 0 {
+		result.Outcome = Classify(resp.Codespace, resp.Code, resp.RawLog)
+		result.MessageIndex = messageIndex(resp.RawLog)
+	}
+
+	return result
+}
+
+// messageIndex returns the index of the failed transaction message reported in the log, or -1.
+func messageIndex(log string) int {
+	match := messageIndexRegexp.FindStringSubmatch(log)
+	if match == nil {
+		return -1
+	}
+
+	index, err := strconv.Atoi(match[1])
+	if err != nil {
+		return -1
+	}
+
+	return index
+}
+
+// TxSource provides the transactions included in the chain (implemented by the chain client).
+type TxSource interface {
+	GetTx(ctx context.Context, txHash string) (*txtypes.GetTxResponse, error)
+}
+
+// Tracker waits for the broadcast transactions to be included in a block.
+type Tracker struct {
+	txs          TxSource
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+func NewTracker(txs TxSource, timeout, pollInterval time.Duration) *Tracker {
+	return &Tracker{
+		txs:          txs,
+		timeout:      timeout,
+		pollInterval: pollInterval,
+	}
+}
+
+// Wait polls the transaction until it is included in a block, the timeout elapses or ctx is cancelled.
+// Transactions not found in time have the timeout outcome, their state is unknown.
+func (t *Tracker) Wait(ctx context.Context, txHash string) Result {
+	waitCtx, cancelFn := context.WithTimeout(ctx, t.timeout)
+	defer cancelFn()
+
+	for {
+		resp, err := t.txs.GetTx(waitCtx, txHash)
+		if err == nil && resp.TxResponse != nil && resp.TxResponse.Height > 0 {
+			return ResultFromResponse(resp.TxResponse)
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return Result{
+				TxHash:       txHash,
+				Outcome:      OutcomeTimeout,
+				MessageIndex: -1,
+			}
+		case <-time.After(t.pollInterval):
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package txtracker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	exchangetypes "github.com/InjectiveLabs/sdk-go/chain/exchange/types"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		codespace string
+		code      uint32
+		log       string
+		expected  Outcome
+	}{
+		{"success", "", 0, "", OutcomeSuccess},
+		{"already liquidated by code", exchangetypes.ModuleName, exchangetypes.ErrPositionNotLiquidable.ABCICode(), "", OutcomeAlreadyLiquidated},
+		{"insufficient deposit", exchangetypes.ModuleName, exchangetypes.ErrInsufficientDeposit.ABCICode(), "", OutcomeInsufficientBalance},
+		{"authz missing in log", "", 0, "failed to execute message; message index: 0: authorization not found", OutcomeAuthzMissing},
+		{"price out of bounds", exchangetypes.ModuleName, exchangetypes.ErrPriceSurpassesBankruptcyPrice.ABCICode(), "", OutcomePriceOutOfBounds},
+		{"out of gas", "sdk", sdkerrors.ErrOutOfGas.ABCICode(), "out of gas in location: WriteFlat", OutcomeOutOfGas},
+		{"unknown", "wasm", 999, "something else", OutcomeFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Classify(tt.codespace, tt.code, tt.log))
+		})
+	}
+
+	assert.Equal(t, authz.ModuleName, authz.ErrNoAuthorizationFound.Codespace())
+}
+
+func TestResultFromErrorFindsTheTransactionMessageIndex(t *testing.T) {
+	err := errors.New("failed to execute message; message index: 3: Position not liquidable")
+	result := ResultFromError(err)
+	assert.Equal(t, OutcomeAlreadyLiquidated, result.Outcome)
+	assert.Equal(t, 3, result.MessageIndex)
+
+	// the authz execution reports the failed message itself, not its index
+	err = errors.New("failed to execute message; message index: 2: failed to execute message; message {subaccount_id:\"0x01\"}: Position not liquidable")
+	result = ResultFromError(err)
+	assert.Equal(t, OutcomeAlreadyLiquidated, result.Outcome)
+	assert.Equal(t, 2, result.MessageIndex)
+}
+
+type pendingTxs struct {
+	calls         int
+	includedAfter int
+}
+
+func (p *pendingTxs) GetTx(_ context.Context, txHash string) (*txtypes.GetTxResponse, error) {
+	p.calls++
+	if p.calls < p.includedAfter {
+		return nil, errors.Errorf("tx %s not found", txHash)
+	}
+	return &txtypes.GetTxResponse{TxResponse: &sdktypes.TxResponse{TxHash: txHash, Height: 10, GasUsed: 150000}}, nil
+}
+
+func TestTrackerWaitsForInclusion(t *testing.T) {
+	txs := &pendingTxs{includedAfter: 3}
+	tracker := NewTracker(txs, time.Second, time.Millisecond)
+
+	result := tracker.Wait(context.Background(), "ABCD")
+
+	assert.Equal(t, OutcomeSuccess, result.Outcome)
+	assert.Equal(t, int64(10), result.Height)
+	assert.Equal(t, 3, txs.calls)
+}
+
+func TestTrackerTimesOut(t *testing.T) {
+	tracker := NewTracker(&pendingTxs{includedAfter: 1000000}, 20*time.Millisecond, time.Millisecond)
+
+	result := tracker.Wait(context.Background(), "ABCD")
+
+	assert.Equal(t, OutcomeTimeout, result.Outcome)
+	assert.Equal(t, "ABCD", result.TxHash)
+}
@@ -0,0 +1,204 @@
+// Package unwind defines the policies used to close the positions acquired through the liquidations.
+package unwind
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/pkg/errors"
+)
+
+const (
+	PolicyMarket = "market"
+	PolicyTWAP   = "twap"
+	PolicyLimit  = "limit"
+)
+
+var bpsDenominator = math.LegacyNewDec(10000)
+
+// Position is a position held by the liquidator. All values are in chain format.
+type Position struct {
+	MarketID   string
+	IsLong     bool
+	Quantity   math.LegacyDec
+	EntryPrice math.LegacyDec
+	MarkPrice  math.LegacyDec
+}
+
+// Order is a reduce only order closing the position, or part of it. All values are in chain format.
+type Order struct {
+	// IsBuy is true when closing a short position
+	IsBuy    bool
+	Quantity math.LegacyDec
+	// Price is the worst price of market orders, or the limit order price
+	Price math.LegacyDec
+	// IsMarket orders are matched immediately, otherwise the order rests in the orderbook
+	IsMarket bool
+}
+
+// Policy decides how the positions are closed.
+type Policy interface {
+	// Next returns the order to send now to reduce the position, or false if nothing has to be sent yet
+	Next(position Position, now time.Time) (Order, bool)
+	// Reset forgets the state kept for the market, once its position is closed
+	Reset(marketID string)
+	// String returns the policy specification, as accepted by ParsePolicy
+	String() string
+}
+
+// ParsePolicy creates the policy from its specification: market, twap:<minutes> or limit:<spread bps>.
+// The market orders worst price is the mark price moved by maxSlippageBps.
+func ParsePolicy(spec string, maxSlippageBps math.LegacyDec) (Policy, error) {
+	name, param, hasParam := strings.Cut(strings.TrimSpace(spec), ":")
+
+	var value int64
+	if hasParam {
+		parsed, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || parsed < 0 {
+			return nil, errors.Errorf("invalid parameter in unwind policy %s", spec)
+		}
+		value = parsed
+	}
+
+	switch name {
+	case PolicyMarket:
+		if hasParam {
+			return nil, errors.Errorf("unwind policy %s does not accept parameters", name)
+		}
+		return MarketPolicy{SlippageBps: maxSlippageBps}, nil
+	case PolicyTWAP:
+		if !hasParam || value == 0 {
+			return nil, errors.Errorf("unwind policy %s requires the duration in minutes (%s:<minutes>)", name, name)
+		}
+		return NewTWAPPolicy(time.Duration(value)*time.Minute, maxSlippageBps), nil
+	case PolicyLimit:
+		if !hasParam {
+			return nil, errors.Errorf("unwind policy %s requires the target spread (%s:<bps>)", name, name)
+		}
+		return LimitPolicy{SpreadBps: math.LegacyNewDec(value)}, nil
+	default:
+		return nil, errors.Errorf("unknown unwind policy %s", name)
+	}
+}
+
+// MarketPolicy closes the whole position at once with a market order.
+type MarketPolicy struct {
+	SlippageBps math.LegacyDec
+}
+
+func (p MarketPolicy) Next(position Position, _ time.Time) (Order, bool) {
+	return marketOrder(position, position.Quantity, p.SlippageBps), true
+}
+
+func (p MarketPolicy) Reset(string) {}
+
+func (p MarketPolicy) String() string {
+	return PolicyMarket
+}
+
+// TWAPPolicy closes the position with market orders spread evenly over the duration. The schedule starts
+// when the position is first seen and ends duration later, when the remaining quantity is closed.
+type TWAPPolicy struct {
+	Duration    time.Duration
+	SlippageBps math.LegacyDec
+
+	mux       sync.Mutex
+	schedules map[string]twapSchedule
+}
+
+type twapSchedule struct {
+	deadline  time.Time
+	lastOrder time.Time
+}
+
+func NewTWAPPolicy(duration time.Duration, slippageBps math.LegacyDec) *TWAPPolicy {
+	return &TWAPPolicy{
+		Duration:    duration,
+		SlippageBps: slippageBps,
+		schedules:   make(map[string]twapSchedule),
+	}
+}
+
+func (p *TWAPPolicy) Next(position Position, now time.Time) (Order, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	schedule, found := p.schedules[position.MarketID]
+	if !found {
+		p.schedules[position.MarketID] = twapSchedule{
+			deadline:  now.Add(p.Duration),
+			lastOrder: now,
+		}
+		return Order{}, false
+	}
+
+	quantity := position.Quantity
+	if remaining := schedule.deadline.Sub(schedule.lastOrder); now.Before(schedule.deadline) && remaining > 0 {
+		// the slice covers the time elapsed since the last order, out of the time left
+		fraction := math.LegacyNewDec(int64(now.Sub(schedule.lastOrder))).QuoInt64(int64(remaining))
+		quantity = position.Quantity.Mul(fraction)
+	}
+	if !quantity.IsPositive() {
+		return Order{}, false
+	}
+
+	schedule.lastOrder = now
+	p.schedules[position.MarketID] = schedule
+
+	return marketOrder(position, quantity, p.SlippageBps), true
+}
+
+func (p *TWAPPolicy) Reset(marketID string) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	delete(p.schedules, marketID)
+}
+
+func (p *TWAPPolicy) String() string {
+	return PolicyTWAP + ":" + strconv.FormatInt(int64(p.Duration/time.Minute), 10)
+}
+
+// LimitPolicy rests a limit order for the whole position at the entry price plus the target spread.
+type LimitPolicy struct {
+	SpreadBps math.LegacyDec
+}
+
+func (p LimitPolicy) Next(position Position, _ time.Time) (Order, bool) {
+	spread := p.SpreadBps.Quo(bpsDenominator)
+	if !position.IsLong {
+		spread = spread.Neg()
+	}
+
+	return Order{
+		IsBuy:    !position.IsLong,
+		Quantity: position.Quantity,
+		Price:    position.EntryPrice.Mul(math.LegacyOneDec().Add(spread)),
+	}, true
+}
+
+func (p LimitPolicy) Reset(string) {}
+
+func (p LimitPolicy) String() string {
+	return PolicyLimit + ":" + p.SpreadBps.TruncateInt().String()
+}
+
+// marketOrder returns the market order closing quantity of the position, with a worst price
+// slippage bps away from the mark price.
+func marketOrder(position Position, quantity math.LegacyDec, slippageBps math.LegacyDec) Order {
+	slippage := slippageBps.Quo(bpsDenominator)
+	if position.IsLong {
+		// selling, accept prices below the mark price
+		slippage = slippage.Neg()
+	}
+
+	return Order{
+		IsBuy:    !position.IsLong,
+		Quantity: quantity,
+		Price:    position.MarkPrice.Mul(math.LegacyOneDec().Add(slippage)),
+		IsMarket: true,
+	}
+}
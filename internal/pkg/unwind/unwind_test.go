@@ -0,0 +1,96 @@
+package unwind
+
+import (
+	"testing"
+	"time"
+
+	"cosmossdk.io/math"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePolicy(t *testing.T) {
+	slippage := math.LegacyNewDec(50)
+
+	policy, err := ParsePolicy("market", slippage)
+	assert.NoError(t, err)
+	assert.Equal(t, "market", policy.String())
+
+	policy, err = ParsePolicy("twap:30", slippage)
+	assert.NoError(t, err)
+	assert.Equal(t, "twap:30", policy.String())
+
+	policy, err = ParsePolicy("limit:20", slippage)
+	assert.NoError(t, err)
+	assert.Equal(t, "limit:20", policy.String())
+
+	for _, spec := range []string{"market:10", "twap", "twap:0", "limit", "limit:-1", "vwap"} {
+		_, err = ParsePolicy(spec, slippage)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestMarketPolicyClosesThePositionWithSlippage(t *testing.T) {
+	policy := MarketPolicy{SlippageBps: math.LegacyNewDec(100)}
+
+	order, send := policy.Next(Position{
+		MarketID:  "btc",
+		IsLong:    true,
+		Quantity:  math.LegacyMustNewDecFromStr("0.5"),
+		MarkPrice: math.LegacyMustNewDecFromStr("30000000000"),
+	}, time.Now())
+
+	assert.True(t, send)
+	assert.True(t, order.IsMarket)
+	assert.False(t, order.IsBuy)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("0.5"), order.Quantity)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("29700000000"), order.Price)
+}
+
+func TestTWAPPolicySpreadsTheOrdersOverTheDuration(t *testing.T) {
+	policy := NewTWAPPolicy(10*time.Minute, math.LegacyNewDec(100))
+	position := Position{
+		MarketID:  "btc",
+		Quantity:  math.LegacyMustNewDecFromStr("1"),
+		MarkPrice: math.LegacyMustNewDecFromStr("30000000000"),
+	}
+	start := time.Now()
+
+	_, send := policy.Next(position, start)
+	assert.False(t, send)
+
+	order, send := policy.Next(position, start.Add(time.Minute))
+	assert.True(t, send)
+	assert.True(t, order.IsBuy)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("0.1"), order.Quantity)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("30300000000"), order.Price)
+
+	position.Quantity = math.LegacyMustNewDecFromStr("0.9")
+	order, _ = policy.Next(position, start.Add(4*time.Minute))
+	assert.Equal(t, math.LegacyMustNewDecFromStr("0.3"), order.Quantity)
+
+	// the remaining quantity is closed once the duration elapsed
+	position.Quantity = math.LegacyMustNewDecFromStr("0.6")
+	order, _ = policy.Next(position, start.Add(11*time.Minute))
+	assert.Equal(t, math.LegacyMustNewDecFromStr("0.6"), order.Quantity)
+
+	policy.Reset("btc")
+	_, send = policy.Next(position, start.Add(12*time.Minute))
+	assert.False(t, send)
+}
+
+func TestLimitPolicyTargetsTheSpreadFromTheEntryPrice(t *testing.T) {
+	policy := LimitPolicy{SpreadBps: math.LegacyNewDec(20)}
+
+	order, send := policy.Next(Position{
+		MarketID:   "btc",
+		IsLong:     false,
+		Quantity:   math.LegacyMustNewDecFromStr("2"),
+		EntryPrice: math.LegacyMustNewDecFromStr("30000000000"),
+	}, time.Now())
+
+	assert.True(t, send)
+	assert.False(t, order.IsMarket)
+	assert.True(t, order.IsBuy)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("2"), order.Quantity)
+	assert.Equal(t, math.LegacyMustNewDecFromStr("29940000000"), order.Price)
+}
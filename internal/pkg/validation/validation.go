@@ -0,0 +1,110 @@
+// Package validation reports the checks of the bot configuration against the network.
+package validation
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"cosmossdk.io/math"
+	"github.com/pkg/errors"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	// StatusSkip is a check not run, because a check it depends on failed
+	StatusSkip Status = "SKIP"
+)
+
+// Check is a named check and its outcome.
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Report collects the outcome of the checks.
+type Report struct {
+	Checks []Check
+}
+
+// Pass records a passed check.
+func (r *Report) Pass(name string, detail string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: StatusPass, Detail: detail})
+}
+
+// Fail records a failed check with its error.
+func (r *Report) Fail(name string, err error) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: StatusFail, Detail: err.Error()})
+}
+
+// Skip records a check not run.
+func (r *Report) Skip(name string, reason string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: StatusSkip, Detail: reason})
+}
+
+// Failed reports whether a check failed.
+func (r *Report) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the checks as a table followed by the summary.
+func (r *Report) Print(w io.Writer) error {
+	failed, skipped := 0, 0
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "STATUS\tCHECK\tDETAIL")
+	for _, check := range r.Checks {
+		switch check.Status {
+		case StatusFail:
+			failed++
+		case StatusSkip:
+			skipped++
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", check.Status, check.Name, check.Detail)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	outcome := "PASSED"
+	if failed > 0 {
+		outcome = "FAILED"
+	}
+	_, err := fmt.Fprintf(w, "\nValidation %s: %d checks, %d failed, %d skipped\n", outcome, len(r.Checks), failed, skipped)
+	return err
+}
+
+// CheckOrderAmount checks that a max order amount is a positive multiple of the market min quantity tick,
+// so the liquidation orders it caps keep a valid quantity.
+func CheckOrderAmount(amount, minQuantityTick math.LegacyDec) error {
+	if !amount.IsPositive() {
+		return errors.Errorf("max order amount %s is not positive", amount)
+	}
+	if !minQuantityTick.IsPositive() {
+		return nil
+	}
+	if amount.LT(minQuantityTick) {
+		return errors.Errorf("max order amount %s is below the min quantity tick %s", amount, minQuantityTick)
+	}
+	if !amount.Quo(minQuantityTick).TruncateDec().Mul(minQuantityTick).Equal(amount) {
+		return errors.Errorf("max order amount %s is not a multiple of the min quantity tick %s", amount, minQuantityTick)
+	}
+	return nil
+}
+
+// CheckOrderNotional checks that a max order notional is positive.
+func CheckOrderNotional(notional math.LegacyDec) error {
+	if !notional.IsPositive() {
+		return errors.Errorf("max order notional %s is not positive", notional)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package validation
+
+import (
+	"bytes"
+	"testing"
+
+	"cosmossdk.io/math"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportFailsWhenACheckFails(t *testing.T) {
+	report := &Report{}
+	report.Pass("key", "inj1key")
+	report.Skip("grants", "the key did not resolve")
+	assert.False(t, report.Failed())
+
+	report.Fail("markets", errors.New("derivative market 0xunknown not found"))
+	assert.True(t, report.Failed())
+
+	var out bytes.Buffer
+	assert.NoError(t, report.Print(&out))
+	assert.Equal(t, "STATUS  CHECK    DETAIL\n"+
+		"PASS    key      inj1key\n"+
+		"SKIP    grants   the key did not resolve\n"+
+		"FAIL    markets  derivative market 0xunknown not found\n"+
+		"\nValidation FAILED: 3 checks, 1 failed, 1 skipped\n", out.String())
+}
+
+func TestCheckOrderAmount(t *testing.T) {
+	tick := math.LegacyMustNewDecFromStr("0.001")
+
+	assert.NoError(t, CheckOrderAmount(math.LegacyMustNewDecFromStr("0.5"), tick))
+	assert.NoError(t, CheckOrderAmount(tick, tick))
+	assert.Error(t, CheckOrderAmount(math.LegacyMustNewDecFromStr("0.0005"), tick))
+	assert.Error(t, CheckOrderAmount(math.LegacyMustNewDecFromStr("0.5005"), tick))
+	assert.Error(t, CheckOrderAmount(math.LegacyZeroDec(), tick))
+
+	assert.NoError(t, CheckOrderNotional(math.LegacyMustNewDecFromStr("1000")))
+	assert.Error(t, CheckOrderNotional(math.LegacyMustNewDecFromStr("-1")))
+}